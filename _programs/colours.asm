@@ -0,0 +1,69 @@
+.include "lib/io.asm"
+
+%COLOUR-BUFFER-ADDR = 0x4000
+
+%COLOUR-BUFFER-END-ADDR = 0x6580
+
+%BAND-SIZE = 0x258
+
+%NEXT-COLOUR = 0x1111
+
+MACRO palette-entry colour ; R0 is the address of the entry, it is moved on to the next one
+	OUT Data, R0
+	DATA R1, \colour
+	OUT Data, R1
+	INC R0
+ENDM
+
+main:
+	select-adapter R0, %DISPLAY-ADAPTER-ADDR
+	DATA R0, %PALETTE-ADDR ; the colours of the 16 colour CGA palette
+	palette-entry 0x000
+	palette-entry 0x00A
+	palette-entry 0x0A0
+	palette-entry 0x0AA
+	palette-entry 0xA00
+	palette-entry 0xA0A
+	palette-entry 0xA50
+	palette-entry 0xAAA
+	palette-entry 0x555
+	palette-entry 0x55F
+	palette-entry 0x5F5
+	palette-entry 0x5FF
+	palette-entry 0xF55
+	palette-entry 0xF5F
+	palette-entry 0xFF5
+	palette-entry 0xFFF
+	set-display-mode R0, %DISPLAY-MODE-COLOUR-4BPP
+	DATA R0, %COLOUR-BUFFER-ADDR
+	DATA R1, 0x0000 ; every pixel of a band is the same colour, 10 lines of 60 words
+	DATA R2, %COLOUR-BUFFER-ADDR
+	DATA R3, %BAND-SIZE
+	ADD R3, R2
+
+main-loop:
+	OUT Data, R0
+	OUT Data, R1
+	CLF
+	INC R0
+	CLF
+	CMP R0, R2
+	JMPE main-next-band
+	JMP main-loop
+
+main-next-band:
+	DATA R3, %NEXT-COLOUR
+	CLF
+	ADD R3, R1
+	DATA R3, %BAND-SIZE
+	CLF
+	ADD R3, R2
+	DATA R3, %COLOUR-BUFFER-END-ADDR
+	CLF
+	CMP R0, R3
+	JMPE main-end
+	JMP main-loop
+
+main-end:
+	deselect-io R0
+	HALT
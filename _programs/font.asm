@@ -0,0 +1,27 @@
+.include "lib/io.asm"
+
+%FIRST-CELL-ADDR = 0x131E
+
+%FIRST-CHAR = 0x20
+
+%END-CHAR = 0x80
+main:
+	select-adapter R0, %DISPLAY-ADAPTER-ADDR
+	set-display-mode R0, %DISPLAY-MODE-TEXT
+	DATA R0, %FIRST-CELL-ADDR ; the first column of the second row
+	DATA R1, %FIRST-CHAR
+	DATA R3, %END-CHAR
+
+main-loop:
+	OUT Data, R0
+	OUT Data, R1
+	INC R0
+	INC R1
+	CLF
+	CMP R1, R3
+	JMPE main-end
+	JMP main-loop
+
+main-end:
+	deselect-io R0
+	HALT
@@ -0,0 +1,41 @@
+; the IO addresses of the peripherals
+%INTERRUPT-CONTROLLER-ADDR = 0x0001
+%TIMER-ADDR = 0x0002
+%DISK-ADDR = 0x0003
+%SERIAL-ADAPTER-ADDR = 0x0004
+%DISPLAY-ADAPTER-ADDR = 0x0007
+%KEY-ADAPTER-ADDR = 0x000F
+
+; the display modes, written to the display RAM
+%DISPLAY-MODE-ADDR = 0x12C0
+%DISPLAY-MODE-PIXEL = 0x0000
+%DISPLAY-MODE-TEXT = 0x0001
+%DISPLAY-MODE-COLOUR-2BPP = 0x0002
+%DISPLAY-MODE-COLOUR-4BPP = 0x0003
+
+%PALETTE-ADDR = 0x12D0
+
+; the handler of interrupt line n is stored at %INTERRUPT-TABLE-ADDR + n
+%INTERRUPT-TABLE-ADDR = 0x0480
+%KEYBOARD-INTERRUPT-LINE = 0x0001
+%TIMER-INTERRUPT-LINE = 0x0002
+%SERIAL-INTERRUPT-LINE = 0x0003
+
+; select the peripheral at address for OUT Data and IN Data
+MACRO select-adapter reg, address
+	DATA \reg, \address
+	OUT Addr, \reg
+ENDM
+
+MACRO deselect-io reg
+	XOR \reg, \reg
+	OUT Addr, \reg
+ENDM
+
+; the display adapter has to be selected
+MACRO set-display-mode reg, mode
+	DATA \reg, %DISPLAY-MODE-ADDR
+	OUT Data, \reg
+	DATA \reg, \mode
+	OUT Data, \reg
+ENDM
@@ -0,0 +1,41 @@
+.include "lib/io.asm"
+
+%TIMER-VECTOR = %INTERRUPT-TABLE-ADDR + %TIMER-INTERRUPT-LINE
+
+%TIMER-LINE-MASK = 1 << %TIMER-INTERRUPT-LINE
+
+%TIMER-RELOAD = 0x100
+
+%BAR-END-ADDR = 0x400
+	JMP main
+
+timer-handler:
+	select-adapter R0, %TIMER-ADDR
+	IN Data, R0 ; reading the status clears the expired bit
+	select-adapter R0, %DISPLAY-ADAPTER-ADDR
+	DATA R2, %BAR-END-ADDR
+	LD R2, R1
+	OUT Data, R1
+	DATA R3, 0xFFFF
+	OUT Data, R3
+	INC R1
+	ST R2, R1
+	IRET
+
+main:
+	DATA R0, %TIMER-VECTOR
+	DATA R1, timer-handler ; the timer's entry in the vector table points to the handler
+	ST R0, R1
+	DATA R0, %BAR-END-ADDR
+	DATA R1, 0x0000
+	ST R0, R1
+	select-adapter R0, %INTERRUPT-CONTROLLER-ADDR
+	DATA R0, %TIMER-LINE-MASK
+	OUT Data, R0
+	select-adapter R0, %TIMER-ADDR
+	DATA R0, %TIMER-RELOAD
+	OUT Data, R0
+	EI
+
+main-wait:
+	JMP main-wait
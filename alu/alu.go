@@ -29,6 +29,8 @@ type ALU struct {
 
 	Op      [3]circuit.Wire
 	CarryIn circuit.Wire
+	// turns the adder into a subtractor, the carry in and carry out become a borrow
+	Subtract circuit.Wire
 
 	carryOut  circuit.Wire
 	aIsLarger circuit.Wire
@@ -47,6 +49,10 @@ type ALU struct {
 	isZero      components.IsZero
 	enablers    [7]components.Enabler
 	andGates    [3]circuit.ANDGate
+
+	subtractXORGates [BUS_WIDTH]circuit.XORGate
+	carryInXORGate   circuit.XORGate
+	carryOutXORGate  circuit.XORGate
 }
 
 func NewALU(inputABus, inputBBus, outputBus, flagsOutputBus *components.Bus) *ALU {
@@ -75,6 +81,12 @@ func NewALU(inputABus, inputBBus, outputBus, flagsOutputBus *components.Bus) *AL
 		a.enablers[i] = *components.NewEnabler()
 	}
 
+	for i := range a.subtractXORGates {
+		a.subtractXORGates[i] = *circuit.NewXORGate()
+	}
+	a.carryInXORGate = *circuit.NewXORGate()
+	a.carryOutXORGate = *circuit.NewXORGate()
+
 	return a
 }
 
@@ -134,7 +146,16 @@ func (a *ALU) updateRightShifter() {
 
 func (a *ALU) updateAdder() {
 	a.setWireOnComponent(&a.adder)
-	a.adder.Update(a.CarryIn.Get())
+
+	// A - B - borrow is the same as A + NOT B + NOT borrow, and there is a borrow out when there is no carry out
+	for i := (BUS_WIDTH * 2) - 1; i >= BUS_WIDTH; i-- {
+		a.subtractXORGates[i-BUS_WIDTH].Update(a.inputBBus.GetOutputWire(i-BUS_WIDTH), a.Subtract.Get())
+		a.adder.SetInputWire(i, a.subtractXORGates[i-BUS_WIDTH].Output())
+	}
+	a.carryInXORGate.Update(a.CarryIn.Get(), a.Subtract.Get())
+
+	a.adder.Update(a.carryInXORGate.Output())
+	a.carryOutXORGate.Update(a.adder.Carry(), a.Subtract.Get())
 	a.wireToEnabler(&a.adder, 0)
 }
 
@@ -189,11 +210,12 @@ func (a *ALU) String() string {
 		x++
 	}
 	return fmt.Sprintf(
-		"ALU OP: %s, A: %s, B: %s, OUT: %s, carryin: %v, carryout: %v, larger: %v, eq: %v, zero: %v",
+		"ALU OP: %s, A: %s, B: %s, OUT: %s, subtract: %v, carryin: %v, carryout: %v, larger: %v, eq: %v, zero: %v",
 		s,
 		utils.ValueToString(inputA),
 		utils.ValueToString(inputB),
 		utils.ValueToString(output),
+		a.Subtract.Get(),
 		a.CarryIn.Get(),
 		a.flagsOutputBus.GetOutputWire(0),
 		a.flagsOutputBus.GetOutputWire(1),
@@ -230,7 +252,7 @@ func (a *ALU) Update() {
 
 		switch enabler {
 		case ADD:
-			a.andGates[0].Update(a.adder.Carry(), a.opDecoder.GetOutputWire(ADD))
+			a.andGates[0].Update(a.carryOutXORGate.Output(), a.opDecoder.GetOutputWire(ADD))
 			a.carryOut.Update(a.andGates[0].Output())
 		case SHR:
 			a.andGates[1].Update(a.rightShifer.ShiftOut(), a.opDecoder.GetOutputWire(SHR))
@@ -60,6 +60,29 @@ func TestAluAdd(t *testing.T) {
 	}
 }
 
+func TestAluSubtract(t *testing.T) {
+	alu := NewALU(inputABus, inputBBus, outputBus, flagsBus)
+	alu.Subtract.Update(true)
+	defer alu.Subtract.Update(false)
+
+	testOp(alu, ADD, 0x0000, 0x0000, false, 0x0000, true, false, false, true, t)
+	testOp(alu, ADD, 0x0003, 0x0001, false, 0x0002, false, true, false, false, t)
+	testOp(alu, ADD, 0x0001, 0x0003, false, 0xFFFE, false, false, true, false, t)
+	testOp(alu, ADD, 0x00FF, 0x00FF, false, 0x0000, true, false, false, true, t)
+	testOp(alu, ADD, 0x0000, 0x0001, false, 0xFFFF, false, false, true, false, t)
+	testOp(alu, ADD, 0x8000, 0x7FFF, false, 0x0001, false, true, false, false, t)
+
+	// borrow in situations
+	testOp(alu, ADD, 0x0003, 0x0001, true, 0x0001, false, true, false, false, t)
+	testOp(alu, ADD, 0x0001, 0x0001, true, 0xFFFF, true, false, true, false, t)
+	testOp(alu, ADD, 0x0000, 0xFFFF, true, 0x0000, false, false, true, true, t)
+
+	for i := uint16(0); i < 1000; i++ {
+		j := i * 37
+		testOp(alu, ADD, j, i, false, j-i, i == j, j > i, i > j, i == j, t)
+	}
+}
+
 func TestAluSHR(t *testing.T) {
 	alu := NewALU(inputABus, inputBBus, outputBus, flagsBus)
 	for i := uint16(32768); i > 1; i /= 2 {
@@ -1,7 +1,9 @@
 package asm
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/djhworld/simple-computer/utils"
@@ -12,14 +14,28 @@ const (
 	NEXTINSTRUCTION    = "NEXTINSTRUCTION"
 )
 
+// the number of words of an instruction shown by ToString, the rest of the words of data are left out
+const MAX_WORDS_SHOWN = 4
+
 var reservedSymbols = map[string]interface{}{
 	NEXTINSTRUCTION:    new(interface{}),
 	CURRENTINSTRUCTION: new(interface{}),
 }
 
 type Assembler struct {
-	labels  map[string]uint16
-	symbols map[string]uint16
+	// the source position of each instruction as returned by Parser.Positions, errors are given at
+	// these positions when it is set
+	Positions []SourcePosition
+	labels    map[string]uint16
+	symbols   map[string]uint16
+	// the errors found by the last call to Process or ToString
+	errors []instructionError
+}
+
+// instructionError is an error about the instruction at an index of the instructions being assembled
+type instructionError struct {
+	index int
+	err   error
 }
 
 func (a *Assembler) ResolveLabel(label LABEL) (uint16, error) {
@@ -32,43 +48,66 @@ func (a *Assembler) ResolveLabel(label LABEL) (uint16, error) {
 
 func (a *Assembler) ResolveSymbol(symbol SYMBOL) (uint16, error) {
 	if v, ok := a.symbols[symbol.Name]; !ok {
-		fmt.Println(a.symbols)
 		return 0x0000, fmt.Errorf("Cannot find symbol: %s in symbol map", symbol.Name)
 	} else {
 		return v, nil
 	}
 }
 
+// Labels returns the address of every label found by the last call to Process
+func (a *Assembler) Labels() map[string]uint16 {
+	labels := make(map[string]uint16, len(a.labels))
+	for name, address := range a.labels {
+		labels[name] = address
+	}
+	return labels
+}
+
 func (a *Assembler) Process(codeStartOffset uint16, instructions []Instruction) ([]uint16, error) {
+	a.errors = nil
+	instructions = a.placeOrigins(codeStartOffset, instructions)
+
 	a.labels = make(map[string]uint16)
 	a.symbols = make(map[string]uint16)
+	expressions := []DEFEXPRESSION{}
+	definedAt := make(map[string]int)
 	position := uint16(0)
 
 	//calculate labels and symbols
-	for _, ins := range instructions {
+	for index, ins := range instructions {
 		position += uint16(ins.Size())
 
 		if label, ok := ins.(DEFLABEL); ok {
 			if _, ok := a.labels[label.Name]; ok {
-				return nil, fmt.Errorf("label '%s' already exists, all labels should be unique", label.Name)
+				a.fail(index, fmt.Errorf("label '%s' already exists, all labels should be unique", label.Name))
+				continue
 			}
 
 			a.labels[label.Name] = position + codeStartOffset
 		}
 
 		if symbol, ok := ins.(DEFSYMBOL); ok {
-			if _, ok := a.symbols[symbol.Name]; ok {
-				return nil, fmt.Errorf("symbol '%s' already exists, all symbols should be unique", symbol.Name)
+			if err := checkSymbolName(symbol.Name, a.symbols, expressions); err != nil {
+				a.fail(index, err)
+				continue
 			}
 
-			if isReservedSymbol(symbol.Name) {
-				return nil, fmt.Errorf("symbol '%s' is reserved for internal use, please use another symbol name", symbol.Name)
+			a.symbols[symbol.Name] = symbol.Value
+		}
+
+		if symbol, ok := ins.(DEFEXPRESSION); ok {
+			if err := checkSymbolName(symbol.Name, a.symbols, expressions); err != nil {
+				a.fail(index, err)
+				continue
 			}
 
-			a.symbols[symbol.Name] = symbol.Value
+			expressions = append(expressions, symbol)
+			definedAt[symbol.Name] = index
 		}
 	}
 
+	a.defineExpressions(expressions, definedAt)
+
 	emitted := []uint16{}
 
 	position = 0
@@ -79,28 +118,42 @@ func (a *Assembler) Process(codeStartOffset uint16, instructions []Instruction)
 		if _, ok := ins.(DEFSYMBOL); ok {
 			continue
 		}
+		if _, ok := ins.(DEFEXPRESSION); ok {
+			continue
+		}
+		if _, ok := ins.(MACROCALL); ok {
+			continue
+		}
 
 		a.symbols[CURRENTINSTRUCTION] = position + codeStartOffset
 		a.symbols[NEXTINSTRUCTION] = getNextExecutableInstructionLoc(a.symbols[CURRENTINSTRUCTION], index, instructions)
 		emit, err := ins.Emit(a.ResolveLabel, a.ResolveSymbol)
 		if err != nil {
-			return nil, err
+			a.fail(index, fmt.Errorf("%s: %v", ins, err))
 		}
 
 		emitted = append(emitted, emit...)
 		position += uint16(ins.Size())
 	}
 
+	if err := a.failure(); err != nil {
+		return nil, err
+	}
 	return emitted, nil
 }
 
 func (a *Assembler) ToString(codeStartOffset uint16, instructions []Instruction) (string, error) {
+	a.errors = nil
+	instructions = a.placeOrigins(codeStartOffset, instructions)
+
 	a.labels = make(map[string]uint16)
 	a.symbols = make(map[string]uint16)
+	expressions := []DEFEXPRESSION{}
+	definedAt := make(map[string]int)
 	position := uint16(0)
 
 	//calculate lengths
-	for _, ins := range instructions {
+	for index, ins := range instructions {
 		position += uint16(ins.Size())
 
 		if label, ok := ins.(DEFLABEL); ok {
@@ -110,8 +163,15 @@ func (a *Assembler) ToString(codeStartOffset uint16, instructions []Instruction)
 		if symbol, ok := ins.(DEFSYMBOL); ok {
 			a.symbols[symbol.Name] = symbol.Value
 		}
+
+		if symbol, ok := ins.(DEFEXPRESSION); ok {
+			expressions = append(expressions, symbol)
+			definedAt[symbol.Name] = index
+		}
 	}
 
+	a.defineExpressions(expressions, definedAt)
+
 	result := strings.Builder{}
 
 	position = 0
@@ -124,6 +184,13 @@ func (a *Assembler) ToString(codeStartOffset uint16, instructions []Instruction)
 		} else if _, ok := ins.(DEFSYMBOL); ok {
 			s := ins.(DEFSYMBOL)
 			result.WriteString(s.String())
+		} else if _, ok := ins.(DEFEXPRESSION); ok {
+			s := ins.(DEFEXPRESSION)
+			result.WriteString(fmt.Sprintf("%s\t; 0x%X", s.String(), a.symbols[s.Name]))
+		} else if _, ok := ins.(MACROCALL); ok {
+			m := ins.(MACROCALL)
+			result.WriteString("\t; ")
+			result.WriteString(m.String())
 		} else {
 			a.symbols[CURRENTINSTRUCTION] = position + codeStartOffset
 			a.symbols[NEXTINSTRUCTION] = getNextExecutableInstructionLoc(a.symbols[CURRENTINSTRUCTION], index, instructions)
@@ -132,18 +199,23 @@ func (a *Assembler) ToString(codeStartOffset uint16, instructions []Instruction)
 
 			emit, err := ins.Emit(a.ResolveLabel, a.ResolveSymbol)
 			if err != nil {
-				return "", err
+				a.fail(index, fmt.Errorf("%s: %v", ins, err))
+				position += uint16(ins.Size())
+				continue
 			}
 			result.WriteString("{")
-			for i := 0; i < ins.Size(); i++ {
+			for i := 0; i < ins.Size() && i < MAX_WORDS_SHOWN; i++ {
 				result.WriteString(fmt.Sprintf("%s", utils.ValueToString(emit[i])))
 				if i < ins.Size()-1 {
 					result.WriteString(" ")
 				}
 			}
+			if ins.Size() > MAX_WORDS_SHOWN {
+				result.WriteString("...")
+			}
 			result.WriteString("}")
-			switch ins.Size() {
-			case 4:
+			switch {
+			case ins.Size() >= MAX_WORDS_SHOWN:
 				result.WriteString("\t")
 			default:
 				result.WriteString(strings.Repeat("\t", 3))
@@ -154,9 +226,116 @@ func (a *Assembler) ToString(codeStartOffset uint16, instructions []Instruction)
 		result.WriteString("\n")
 	}
 
+	if err := a.failure(); err != nil {
+		return "", err
+	}
 	return result.String(), nil
 }
 
+// fail records an error about the instruction at index
+func (a *Assembler) fail(index int, err error) {
+	a.errors = append(a.errors, instructionError{index, err})
+}
+
+// failure returns every error recorded by fail in the order of the instructions, nil if there are
+// none. They are Diagnostics at the source positions of their instructions when those are known
+func (a *Assembler) failure() error {
+	if len(a.errors) == 0 {
+		return nil
+	}
+	sort.SliceStable(a.errors, func(i, j int) bool {
+		return a.errors[i].index < a.errors[j].index
+	})
+
+	if a.Positions == nil {
+		messages := []string{}
+		for _, e := range a.errors {
+			messages = append(messages, e.err.Error())
+		}
+		return errors.New(strings.Join(messages, "\n"))
+	}
+
+	diagnostics := Diagnostics{}
+	for _, e := range a.errors {
+		position := SourcePosition{}
+		if e.index < len(a.Positions) {
+			position = a.Positions[e.index]
+		}
+		diagnostics = append(diagnostics, Diagnostic{position, 0, e.err.Error(), false, nil})
+	}
+	return diagnostics
+}
+
+func checkSymbolName(name string, symbols map[string]uint16, expressions []DEFEXPRESSION) error {
+	_, exists := symbols[name]
+	for _, expression := range expressions {
+		exists = exists || expression.Name == name
+	}
+	if exists {
+		return fmt.Errorf("symbol '%s' already exists, all symbols should be unique", name)
+	}
+
+	if isReservedSymbol(name) {
+		return fmt.Errorf("symbol '%s' is reserved for internal use, please use another symbol name", name)
+	}
+	return nil
+}
+
+// defineExpressions works out the values of the symbols defined with expressions, a symbol can use
+// symbols that are defined after it but not itself. definedAt is the index of the instruction that
+// defines each symbol, a symbol that can't be worked out is an error there and is given the value 0
+func (a *Assembler) defineExpressions(expressions []DEFEXPRESSION, definedAt map[string]int) {
+	pending := make(map[string]marker)
+	for _, expression := range expressions {
+		pending[expression.Name] = expression.Expression
+	}
+	resolving := make(map[string]bool)
+
+	var resolveSymbol SymbolResolver
+	resolveSymbol = func(symbol SYMBOL) (uint16, error) {
+		expression, ok := pending[symbol.Name]
+		if !ok {
+			return a.ResolveSymbol(symbol)
+		}
+		if resolving[symbol.Name] {
+			return 0x0000, fmt.Errorf("symbol '%s' is defined using itself", symbol.Name)
+		}
+
+		resolving[symbol.Name] = true
+		value, err := resolveMarker(expression, a.ResolveLabel, resolveSymbol)
+		if err != nil {
+			a.fail(definedAt[symbol.Name], fmt.Errorf("symbol '%s': %v", symbol.Name, err))
+		}
+		delete(pending, symbol.Name)
+		a.symbols[symbol.Name] = value
+		return value, nil
+	}
+
+	for _, expression := range expressions {
+		resolveSymbol(SYMBOL{expression.Name})
+	}
+}
+
+// placeOrigins works out the padding each ORG needs to put the next instruction at its address, an
+// ORG behind the address it is at is an error and gets no padding
+func (a *Assembler) placeOrigins(codeStartOffset uint16, instructions []Instruction) []Instruction {
+	placed := make([]Instruction, len(instructions))
+	address := int(codeStartOffset)
+	for n, ins := range instructions {
+		if org, ok := ins.(ORG); ok {
+			if int(org.Address) < address {
+				a.fail(n, fmt.Errorf(".org 0x%04X is behind the address it is at, 0x%04X", org.Address, address))
+			} else {
+				org.Padding = int(org.Address) - address
+			}
+			ins = org
+		}
+		placed[n] = ins
+		address += ins.Size()
+	}
+	return placed
+}
+
 func isReservedSymbol(name string) bool {
 	if _, ok := reservedSymbols[name]; ok {
 		return true
@@ -178,6 +357,10 @@ func getNextExecutableInstructionLoc(currentOffset uint16, currentInstrIndex int
 			continue
 		} else if _, ok := instruction.(DEFSYMBOL); ok {
 			continue
+		} else if _, ok := instruction.(DEFEXPRESSION); ok {
+			continue
+		} else if _, ok := instruction.(MACROCALL); ok {
+			continue
 		} else {
 			if currentInstrIndex == i {
 				nextInstructionPos += instruction.Size()
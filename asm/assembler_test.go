@@ -0,0 +1,124 @@
+package asm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToStringShowsMacroExpansion(t *testing.T) {
+	p := Parser{}
+	instructions, err := p.Parse(strings.NewReader(`
+	MACRO select-adapter reg, address
+		DATA \reg, \address
+		OUT Addr, \reg
+	ENDM
+	main:
+	select-adapter R1, 0x7
+	`))
+	if err != nil {
+		t.Logf("Error parsing input: %v", err)
+		t.FailNow()
+	}
+
+	a := Assembler{}
+	result, err := a.ToString(0x0500, instructions)
+	if err != nil {
+		t.Logf("Error assembling input: %v", err)
+		t.FailNow()
+	}
+
+	expected := "\nmain:\n" +
+		"\t; select-adapter R1, 0x7\n" +
+		"\t0x0500:\t{0x0021 0x0007}\t\t\tDATA R1, 0x0007\n" +
+		"\t0x0502:\t{0x007D}\t\t\tOUT Addr, R1\n"
+	if result != expected {
+		t.Logf("Expected %q but got %q", expected, result)
+		t.FailNow()
+	}
+}
+
+func TestProcessPlacesDataAndOrigins(t *testing.T) {
+	p := Parser{}
+	instructions, err := p.Parse(strings.NewReader(`
+	main:
+		DATA R0, message
+		HALT
+	message:
+		.string "Hi"
+		.org 0x0508
+	end:
+		.word end, message
+	`))
+	if err != nil {
+		t.Logf("Error parsing input: %v", err)
+		t.FailNow()
+	}
+
+	a := Assembler{}
+	result, err := a.Process(0x0500, instructions)
+	if err != nil {
+		t.Logf("Error assembling input: %v", err)
+		t.FailNow()
+	}
+
+	expected := []uint16{0x0020, 0x0503, 0x01B0, 'H', 'i', 0x0000, 0x0000, 0x0000, 0x0508, 0x0503}
+	if !reflect.DeepEqual(result, expected) {
+		t.Logf("Expected %v but got %v", expected, result)
+		t.FailNow()
+	}
+	if labels := a.Labels(); labels["message"] != 0x0503 || labels["end"] != 0x0508 {
+		t.Logf("Expected message at 0x0503 and end at 0x0508 but got %v", labels)
+		t.FailNow()
+	}
+}
+
+func TestProcessOriginBehindErrors(t *testing.T) {
+	a := Assembler{}
+	if _, err := a.Process(0x0500, []Instruction{FILL{4, NUMBER{0}}, ORG{0x0502, 0}}); err == nil {
+		t.Logf("Expected an error for an .org behind the address it is at")
+		t.FailNow()
+	}
+}
+
+func TestProcessReportsEveryErrorAtItsPosition(t *testing.T) {
+	p := Parser{Filename: "main.asm"}
+	instructions, err := p.Parse(strings.NewReader(`start:
+	JMP nowhere
+start:
+	%A = 1
+	%A = 2
+	%CURRENTINSTRUCTION = 3
+	.org 0x0400
+`))
+	if err != nil {
+		t.Logf("Error parsing input: %v", err)
+		t.FailNow()
+	}
+
+	a := Assembler{Positions: p.Positions()}
+	_, err = a.Process(0x0500, instructions)
+	diagnostics, ok := err.(Diagnostics)
+	if !ok {
+		t.Logf("Expected diagnostics but got %v", err)
+		t.FailNow()
+	}
+
+	expected := []string{
+		"main.asm:2: error: JMP nowhere: Cannot find label: nowhere in label map",
+		"main.asm:3: error: label 'start' already exists, all labels should be unique",
+		"main.asm:5: error: symbol 'A' already exists, all symbols should be unique",
+		"main.asm:6: error: symbol 'CURRENTINSTRUCTION' is reserved for internal use, please use another symbol name",
+		"main.asm:7: error: .org 0x0400 is behind the address it is at, 0x0502",
+	}
+	if len(diagnostics) != len(expected) {
+		t.Logf("Expected %d errors but got %d:\n%v", len(expected), len(diagnostics), diagnostics)
+		t.FailNow()
+	}
+	for n := range expected {
+		if diagnostics[n].String() != expected[n] {
+			t.Logf("Expected %q but got %q", expected[n], diagnostics[n].String())
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package asm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the first line of a debug info file, bump the version if the format changes
+const DEBUG_INFO_HEADER = "simple-computer debug info v1"
+
+// SourcePosition is where an instruction came from in the source
+type SourcePosition struct {
+	File string
+	Line int
+}
+
+func (s SourcePosition) String() string {
+	return fmt.Sprintf("%s:%d", s.File, s.Line)
+}
+
+// SourceLine is an instruction in the assembled program
+type SourceLine struct {
+	Address  uint16
+	Size     int
+	Position SourcePosition
+}
+
+// DebugInfo maps an assembled program back to its source
+type DebugInfo struct {
+	Labels  map[string]uint16
+	Symbols map[string]uint16
+	// sorted by address
+	Lines []SourceLine
+}
+
+// DebugInfo assembles the instructions and returns the debug info for the program, positions are
+// the source positions of the instructions as returned by Parser.Positions and can be nil
+func (a *Assembler) DebugInfo(codeStartOffset uint16, instructions []Instruction, positions []SourcePosition) (*DebugInfo, error) {
+	if _, err := a.Process(codeStartOffset, instructions); err != nil {
+		return nil, err
+	}
+
+	d := new(DebugInfo)
+	d.Labels = a.Labels()
+	d.Symbols = make(map[string]uint16)
+	for name, value := range a.symbols {
+		if !isReservedSymbol(name) {
+			d.Symbols[name] = value
+		}
+	}
+
+	if positions == nil {
+		return d, nil
+	}
+	if len(positions) != len(instructions) {
+		return nil, fmt.Errorf("got %d source positions for %d instructions", len(positions), len(instructions))
+	}
+
+	// Process has already found any ORG that is out of place
+	instructions = a.placeOrigins(codeStartOffset, instructions)
+
+	address := codeStartOffset
+	for i, ins := range instructions {
+		if ins.Size() == 0 {
+			continue
+		}
+		d.Lines = append(d.Lines, SourceLine{address, ins.Size(), positions[i]})
+		address += uint16(ins.Size())
+	}
+
+	return d, nil
+}
+
+// Line returns the source line of the instruction that address is part of
+func (d *DebugInfo) Line(address uint16) (SourceLine, bool) {
+	i := sort.Search(len(d.Lines), func(i int) bool {
+		return d.Lines[i].Address > address
+	})
+	if i == 0 {
+		return SourceLine{}, false
+	}
+
+	line := d.Lines[i-1]
+	if int(address) >= int(line.Address)+line.Size {
+		return SourceLine{}, false
+	}
+	return line, true
+}
+
+// Describe formats an address relative to the closest label before it along with
+// its source line, e.g. main-loop+3 (ascii.asm:42). Returns "" if nothing is known about the address
+func (d *DebugInfo) Describe(address uint16) string {
+	name := ""
+	closest := uint16(0)
+	for label, labelAddress := range d.Labels {
+		if labelAddress > address {
+			continue
+		}
+		if name == "" || labelAddress > closest || (labelAddress == closest && label < name) {
+			name = label
+			closest = labelAddress
+		}
+	}
+
+	description := name
+	if name != "" && closest != address {
+		description = fmt.Sprintf("%s+%d", name, address-closest)
+	}
+
+	if line, ok := d.Line(address); ok {
+		if description != "" {
+			description += " "
+		}
+		description += fmt.Sprintf("(%s)", line.Position)
+	}
+	return description
+}
+
+// Write writes the debug info in a line based text format
+//
+//	simple-computer debug info v1
+//	label <address> <name>
+//	symbol <value> <name>
+//	line <address> <size> <file>:<line>
+func (d *DebugInfo) Write(writer io.Writer) error {
+	w := bufio.NewWriter(writer)
+	fmt.Fprintln(w, DEBUG_INFO_HEADER)
+
+	for _, name := range sortedNames(d.Labels) {
+		fmt.Fprintf(w, "label 0x%04X %s\n", d.Labels[name], name)
+	}
+	for _, name := range sortedNames(d.Symbols) {
+		fmt.Fprintf(w, "symbol 0x%04X %s\n", d.Symbols[name], name)
+	}
+	for _, line := range d.Lines {
+		fmt.Fprintf(w, "line 0x%04X %d %s\n", line.Address, line.Size, line.Position)
+	}
+
+	return w.Flush()
+}
+
+// ReadDebugInfo reads debug info written by DebugInfo.Write
+func ReadDebugInfo(reader io.Reader) (*DebugInfo, error) {
+	d := new(DebugInfo)
+	d.Labels = make(map[string]uint16)
+	d.Symbols = make(map[string]uint16)
+
+	scanner := bufio.NewScanner(reader)
+	if !scanner.Scan() || scanner.Text() != DEBUG_INFO_HEADER {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("not a debug info file, expected the first line to be '%s'", DEBUG_INFO_HEADER)
+	}
+
+	lineNo := 1
+	for scanner.Scan() {
+		lineNo++
+		fields := strings.SplitN(scanner.Text(), " ", 4)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: expected at least 3 fields but got '%s'", lineNo, scanner.Text())
+		}
+
+		value, err := strconv.ParseUint(fields[1], 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid address or value '%s'", lineNo, fields[1])
+		}
+
+		switch {
+		case fields[0] == "label" && len(fields) == 3:
+			d.Labels[fields[2]] = uint16(value)
+		case fields[0] == "symbol" && len(fields) == 3:
+			d.Symbols[fields[2]] = uint16(value)
+		case fields[0] == "line" && len(fields) == 4:
+			line, err := parseSourceLine(uint16(value), fields[2], fields[3])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			d.Lines = append(d.Lines, line)
+		default:
+			return nil, fmt.Errorf("line %d: unknown entry '%s'", lineNo, scanner.Text())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(d.Lines, func(i, j int) bool {
+		return d.Lines[i].Address < d.Lines[j].Address
+	})
+	return d, nil
+}
+
+// ReadDebugInfoFile is ReadDebugInfo for a file on disk
+func ReadDebugInfoFile(filename string) (*DebugInfo, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadDebugInfo(f)
+}
+
+func parseSourceLine(address uint16, size string, position string) (SourceLine, error) {
+	s, err := strconv.Atoi(size)
+	if err != nil {
+		return SourceLine{}, fmt.Errorf("invalid instruction size '%s'", size)
+	}
+
+	// file names can contain colons, the line number is always after the last one
+	separator := strings.LastIndex(position, ":")
+	if separator < 0 {
+		return SourceLine{}, fmt.Errorf("expected <file>:<line> but got '%s'", position)
+	}
+	lineNo, err := strconv.Atoi(position[separator+1:])
+	if err != nil {
+		return SourceLine{}, fmt.Errorf("invalid line number in '%s'", position)
+	}
+
+	return SourceLine{address, s, SourcePosition{position[:separator], lineNo}}, nil
+}
+
+func sortedNames(values map[string]uint16) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,155 @@
+package asm
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDebugInfo(t *testing.T) {
+	d := assembleDebugInfo(t, `
+	%LINE-WIDTH = 0x1E
+	DATA R0, %LINE-WIDTH
+main-loop:
+	ADD R0, R1
+
+	JMP main-loop
+	`)
+
+	expected := &DebugInfo{
+		Labels:  map[string]uint16{"main-loop": 0x0502},
+		Symbols: map[string]uint16{"LINE-WIDTH": 0x1E},
+		Lines: []SourceLine{
+			{0x0500, 2, SourcePosition{"test.asm", 3}},
+			{0x0502, 1, SourcePosition{"test.asm", 5}},
+			{0x0503, 2, SourcePosition{"test.asm", 7}},
+		},
+	}
+
+	if !reflect.DeepEqual(d, expected) {
+		t.Logf("Expected %+v but got %+v", expected, d)
+		t.FailNow()
+	}
+}
+
+func TestDebugInfoOfMacroIsTheCallingLine(t *testing.T) {
+	d := assembleDebugInfo(t, `
+	MACRO select-adapter address
+		DATA R0, \address
+		OUT Addr, R0
+	ENDM
+	select-adapter 0x7
+	HALT
+	`)
+
+	expected := []SourceLine{
+		{0x0500, 2, SourcePosition{"test.asm", 6}},
+		{0x0502, 1, SourcePosition{"test.asm", 6}},
+		{0x0503, 1, SourcePosition{"test.asm", 7}},
+	}
+
+	if !reflect.DeepEqual(d.Lines, expected) {
+		t.Logf("Expected %+v but got %+v", expected, d.Lines)
+		t.FailNow()
+	}
+}
+
+func TestDebugInfoDescribe(t *testing.T) {
+	d := assembleDebugInfo(t, `
+	DATA R0, 0x0001
+main-loop:
+	ADD R0, R1
+	JMP main-loop
+	`)
+
+	for address, expected := range map[uint16]string{
+		0x0500: "(test.asm:2)",
+		0x0501: "(test.asm:2)",
+		0x0502: "main-loop (test.asm:4)",
+		0x0504: "main-loop+2 (test.asm:5)",
+		0x0505: "main-loop+3",
+	} {
+		if description := d.Describe(address); description != expected {
+			t.Logf("Expected 0x%04X to be described as '%s' but got '%s'", address, expected, description)
+			t.FailNow()
+		}
+	}
+
+	if description := d.Describe(0x04FF); description != "" {
+		t.Logf("Expected no description before the program but got '%s'", description)
+		t.FailNow()
+	}
+}
+
+func TestDebugInfoWriteAndRead(t *testing.T) {
+	d := assembleDebugInfo(t, `
+	%ONE = 0x1
+	DATA R0, %ONE
+main-loop:
+	ADD R0, R1
+	JMP main-loop
+	`)
+
+	var buf bytes.Buffer
+	if err := d.Write(&buf); err != nil {
+		t.Logf("Error writing debug info: %v", err)
+		t.FailNow()
+	}
+
+	expected := `simple-computer debug info v1
+label 0x0502 main-loop
+symbol 0x0001 ONE
+line 0x0500 2 test.asm:3
+line 0x0502 1 test.asm:5
+line 0x0503 2 test.asm:6
+`
+	if buf.String() != expected {
+		t.Logf("Expected\n%s\nbut got\n%s", expected, buf.String())
+		t.FailNow()
+	}
+
+	read, err := ReadDebugInfo(&buf)
+	if err != nil {
+		t.Logf("Error reading debug info: %v", err)
+		t.FailNow()
+	}
+
+	if !reflect.DeepEqual(read, d) {
+		t.Logf("Expected %+v but got %+v", d, read)
+		t.FailNow()
+	}
+}
+
+func TestReadDebugInfoErrors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"not debug info\n",
+		DEBUG_INFO_HEADER + "\nlabel 0x0502\n",
+		DEBUG_INFO_HEADER + "\nlabel 0x10000 big\n",
+		DEBUG_INFO_HEADER + "\nline 0x0500 2 test.asm\n",
+		DEBUG_INFO_HEADER + "\nbogus 0x0500 foo\n",
+	} {
+		if _, err := ReadDebugInfo(strings.NewReader(input)); err == nil {
+			t.Logf("Expected an error reading %q", input)
+			t.FailNow()
+		}
+	}
+}
+
+func assembleDebugInfo(t *testing.T, input string) *DebugInfo {
+	p := Parser{Filename: "test.asm"}
+	instructions, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Logf("Error parsing input: %v", err)
+		t.FailNow()
+	}
+
+	a := Assembler{}
+	d, err := a.DebugInfo(0x0500, instructions, p.Positions())
+	if err != nil {
+		t.Logf("Error creating debug info: %v", err)
+		t.FailNow()
+	}
+	return d
+}
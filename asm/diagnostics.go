@@ -0,0 +1,46 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Diagnostic is an error or a warning about a line of the source
+type Diagnostic struct {
+	Position SourcePosition
+	// the column of the line it is about, counting from 1, or 0 if it is about the whole line
+	Column  int
+	Message string
+	Warning bool
+	// the .include lines that led to the file, the closest first
+	IncludedFrom []SourcePosition
+}
+
+// String gives the diagnostic the way compilers do, e.g. main.asm:3:9: error: unknown instruction 'foo'
+func (d Diagnostic) String() string {
+	kind := "error"
+	if d.Warning {
+		kind = "warning"
+	}
+
+	where := d.Position.String()
+	if d.Column > 0 {
+		where = fmt.Sprintf("%s:%d", where, d.Column)
+	}
+	s := fmt.Sprintf("%s: %s: %s", where, kind, d.Message)
+	for _, from := range d.IncludedFrom {
+		s += fmt.Sprintf(", included from %s", from)
+	}
+	return s
+}
+
+// Diagnostics are the errors found by Parse, one a line
+type Diagnostics []Diagnostic
+
+func (d Diagnostics) Error() string {
+	lines := []string{}
+	for _, diagnostic := range d {
+		lines = append(lines, diagnostic.String())
+	}
+	return strings.Join(lines, "\n")
+}
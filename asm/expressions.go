@@ -0,0 +1,318 @@
+package asm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// the operators of expressions from the lowest to the highest precedence, the unary operators - and ~
+// and the functions lo() and hi() are above all of them
+var OPERATOR_PRECEDENCE = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/"},
+}
+
+// the largest value an expression can have while it is worked out, the result has to fit in 16 bits
+const MAX_INTERMEDIATE_VALUE = 0xFFFFFFFF
+
+// EXPRESSION is a value worked out by the assembler, Operator is applied to Left and Right or only to
+// Right for the unary operators - and ~ (the 16 bit complement) and the functions lo and hi, which
+// are the low and high byte of a value
+type EXPRESSION struct {
+	Operator string
+	Left     marker
+	Right    marker
+}
+
+func (e EXPRESSION) placeholder() {
+}
+
+func (e EXPRESSION) String() string {
+	switch {
+	case e.Operator == "lo" || e.Operator == "hi":
+		return fmt.Sprintf("%s(%s)", e.Operator, markerToString(e.Right))
+	case e.Left == nil:
+		return fmt.Sprintf("%s%s", e.Operator, operandToString(e.Right))
+	}
+	return fmt.Sprintf("%s %s %s", operandToString(e.Left), e.Operator, operandToString(e.Right))
+}
+
+// operandToString puts an expression that is the operand of another in parentheses
+func operandToString(m marker) string {
+	if e, ok := m.(EXPRESSION); ok && e.Left != nil {
+		return fmt.Sprintf("(%s)", e)
+	}
+	return markerToString(m)
+}
+
+// resolve works out the value of the expression, it is an error if it does not fit in 16 bits. A negative
+// value down to -0x8000 is the two's complement of the value
+func (e EXPRESSION) resolve(labelResolver LabelResolver, symbolResolver SymbolResolver) (uint16, error) {
+	value, err := e.evaluate(labelResolver, symbolResolver)
+	if err != nil {
+		return 0x0000, err
+	}
+	if value < -0x8000 || value > 0xFFFF {
+		return 0x0000, fmt.Errorf("%s is 0x%X which does not fit in 16 bits", e, value)
+	}
+	return uint16(value), nil
+}
+
+func (e EXPRESSION) evaluate(labelResolver LabelResolver, symbolResolver SymbolResolver) (int64, error) {
+	right, err := evaluateOperand(e.Right, labelResolver, symbolResolver)
+	if err != nil {
+		return 0, err
+	}
+	var left int64
+	if e.Left != nil {
+		if left, err = evaluateOperand(e.Left, labelResolver, symbolResolver); err != nil {
+			return 0, err
+		}
+	}
+
+	// the operators are applied to values of up to 32 bits, so that they can't overflow an int64
+	if !fitsIntermediate(left) || !fitsIntermediate(right) {
+		return 0, fmt.Errorf("%s overflows", e)
+	}
+
+	var value int64
+	switch e.Operator {
+	case "lo":
+		value = right & 0xFF
+	case "hi":
+		value = (right >> 8) & 0xFF
+	case "~":
+		value = ^right & 0xFFFF
+	case "-":
+		if e.Left == nil {
+			value = -right
+		} else {
+			value = left - right
+		}
+	case "+":
+		value = left + right
+	case "*":
+		if left != 0 && abs(right) > MAX_INTERMEDIATE_VALUE/abs(left) {
+			return 0, fmt.Errorf("%s overflows", e)
+		}
+		value = left * right
+	case "/":
+		if right == 0 {
+			return 0, fmt.Errorf("%s divides by 0", e)
+		}
+		value = left / right
+	case "<<", ">>":
+		if right < 0 || right > 31 {
+			return 0, fmt.Errorf("%s shifts by %d, it can only shift by 0 - 31", e, right)
+		}
+		if e.Operator == "<<" {
+			if abs(left) > MAX_INTERMEDIATE_VALUE>>uint(right) {
+				return 0, fmt.Errorf("%s overflows", e)
+			}
+			value = left << uint(right)
+		} else {
+			value = left >> uint(right)
+		}
+	case "&":
+		value = left & right
+	case "|":
+		value = left | right
+	case "^":
+		value = left ^ right
+	default:
+		return 0, fmt.Errorf("unknown operator '%s'", e.Operator)
+	}
+
+	if !fitsIntermediate(value) {
+		return 0, fmt.Errorf("%s overflows", e)
+	}
+	return value, nil
+}
+
+func fitsIntermediate(value int64) bool {
+	return value >= -MAX_INTERMEDIATE_VALUE && value <= MAX_INTERMEDIATE_VALUE
+}
+
+func abs(value int64) int64 {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+func evaluateOperand(m marker, labelResolver LabelResolver, symbolResolver SymbolResolver) (int64, error) {
+	if e, ok := m.(EXPRESSION); ok {
+		return e.evaluate(labelResolver, symbolResolver)
+	}
+	value, err := resolveMarker(m, labelResolver, symbolResolver)
+	return int64(value), err
+}
+
+// isConstant is true if the value does not depend on labels or symbols other than the constants
+func isConstant(m marker, constants map[string]uint16) bool {
+	switch v := m.(type) {
+	case NUMBER:
+		return true
+	case SYMBOL:
+		_, ok := constants[v.Name]
+		return ok
+	case EXPRESSION:
+		return (v.Left == nil || isConstant(v.Left, constants)) && isConstant(v.Right, constants)
+	}
+	return false
+}
+
+// constantValue works out the value of an expression that doesn't use labels or symbols other than the constants
+func constantValue(m marker, constants map[string]uint16) (uint16, error) {
+	noLabels := func(l LABEL) (uint16, error) {
+		return 0x0000, fmt.Errorf("label %s can't be used here, the value has to be a constant", l.Name)
+	}
+	constantSymbols := func(s SYMBOL) (uint16, error) {
+		if value, ok := constants[s.Name]; ok {
+			return value, nil
+		}
+		return 0x0000, fmt.Errorf("symbol %s can't be used here, it has to be defined before with a constant", s)
+	}
+	return resolveMarker(m, noLabels, constantSymbols)
+}
+
+// parseExpression parses a number, character, symbol, label or an expression made of them
+func parseExpression(text string) (marker, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	r := newTokenReader(tokens)
+	m, err := r.expression()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.end(fmt.Sprintf("the value %s", m)); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// expression reads an expression, up to the first token that can't be part of it. It is an error if
+// an expression that doesn't use labels or symbols does not fit in 16 bits
+func (r *tokenReader) expression() (marker, error) {
+	start := r.peek()
+	if start.kind == END_TOKEN {
+		return nil, errorAt(start.column, "missing value")
+	}
+
+	m, err := r.parseLevel(0)
+	if err != nil {
+		return nil, err
+	}
+	if !isConstant(m, nil) {
+		return m, nil
+	}
+	// find out about values that don't fit now, when the line they are on is known
+	if _, err := constantValue(m, nil); err != nil {
+		return nil, errorAt(start.column, "%v", err)
+	}
+	return m, nil
+}
+
+// parseLevel parses the operators of OPERATOR_PRECEDENCE from level on, left to right
+func (r *tokenReader) parseLevel(level int) (marker, error) {
+	if level == len(OPERATOR_PRECEDENCE) {
+		return r.parseUnary()
+	}
+
+	left, err := r.parseLevel(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator := r.peek()
+		found := false
+		for _, o := range OPERATOR_PRECEDENCE[level] {
+			found = found || operator.is(PUNCTUATION_TOKEN, o)
+		}
+		if !found {
+			return left, nil
+		}
+
+		r.next++
+		right, err := r.parseLevel(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = EXPRESSION{operator.text, left, right}
+	}
+}
+
+func (r *tokenReader) parseUnary() (marker, error) {
+	t := r.peek()
+	if t.is(PUNCTUATION_TOKEN, "-") || t.is(PUNCTUATION_TOKEN, "~") {
+		r.next++
+		operand, err := r.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return EXPRESSION{t.text, nil, operand}, nil
+	}
+
+	if (t.is(NAME_TOKEN, "lo") || t.is(NAME_TOKEN, "hi")) && r.next+1 < len(r.tokens) && r.tokens[r.next+1].is(PUNCTUATION_TOKEN, "(") {
+		r.next++
+		operand, err := r.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return EXPRESSION{t.text, nil, operand}, nil
+	}
+	return r.parsePrimary()
+}
+
+func (r *tokenReader) parsePrimary() (marker, error) {
+	t := r.take()
+
+	switch t.kind {
+	case PUNCTUATION_TOKEN:
+		if t.text != "(" {
+			break
+		}
+		m, err := r.parseLevel(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.punctuation(")", fmt.Sprintf("(%s", m)); err != nil {
+			return nil, err
+		}
+		return m, nil
+	case CHARACTER_TOKEN:
+		c, _, tail, err := strconv.UnquoteChar(t.text[1:len(t.text)-1], '\'')
+		if err != nil || tail != "" || c > 0xFFFF {
+			return nil, errorAt(t.column, "invalid character %s", t.text)
+		}
+		return NUMBER{uint16(c)}, nil
+	case SYMBOL_TOKEN:
+		return SYMBOL{t.text[1:]}, nil
+	case NUMBER_TOKEN:
+		var value uint64
+		var err error
+		if strings.HasPrefix(t.text, "0x") {
+			value, err = strconv.ParseUint(strings.TrimPrefix(t.text, "0x"), 16, 64)
+		} else {
+			value, err = strconv.ParseUint(t.text, 10, 64)
+		}
+		if err != nil || value > 0xFFFF {
+			return nil, errorAt(t.column, "%s does not fit in 16 bits", t.text)
+		}
+		return NUMBER{uint16(value)}, nil
+	case NAME_TOKEN:
+		return LABEL{t.text}, nil
+	case PARAMETER_TOKEN:
+		return nil, errorAt(t.column, "parameter %s can only be used in the body of a macro", t.text)
+	case END_TOKEN:
+		return nil, errorAt(t.column, "missing a value at the end of the line")
+	}
+	return nil, errorAt(t.column, "expected a value but got '%s'", t.text)
+}
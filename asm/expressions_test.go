@@ -0,0 +1,189 @@
+package asm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseExpression(t *testing.T) {
+	expected := map[string]uint16{
+		"1 + 2 * 3":          7,
+		"(1 + 2) * 3":        9,
+		"10 - 4 - 3":         3,
+		"0x100 / 2 / 4":      0x20,
+		"1 << 4 | 1":         0x11,
+		"0xFF & 0x0F ^ 0x03": 0x0C,
+		"-1":                 0xFFFF,
+		"~0x00FF":            0xFF00,
+		"-0x8000":            0x8000,
+		"'A'":                0x41,
+		"'a' - 'A'":          0x20,
+		"'\\n'":              0x0A,
+		"' ' | 0x80":         0xA0,
+		"lo(0x1234)":         0x34,
+		"hi(0x1234)":         0x12,
+		"hi(0x12 << 8) + 1":  0x13,
+		"0xFFFF + 1 - 1":     0xFFFF,
+		"(0x8000 * 2) >> 1":  0x8000,
+	}
+	for input, value := range expected {
+		m, err := parseExpression(input)
+		if err != nil {
+			t.Logf("%s: error parsing expression: %v", input, err)
+			t.FailNow()
+		}
+		actual, err := constantValue(m, nil)
+		if err != nil {
+			t.Logf("%s: error working out the value: %v", input, err)
+			t.FailNow()
+		}
+		if actual != value {
+			t.Logf("%s: expected 0x%04X but got 0x%04X", input, value, actual)
+			t.FailNow()
+		}
+	}
+}
+
+func TestParseExpressionWithNames(t *testing.T) {
+	expected := map[string]marker{
+		"start":             LABEL{"start"},
+		"%SIZE":             SYMBOL{"SIZE"},
+		"end-of-line":       LABEL{"end-of-line"},
+		"end - 1":           EXPRESSION{"-", LABEL{"end"}, NUMBER{1}},
+		"%BASE + %SIZE * 2": EXPRESSION{"+", SYMBOL{"BASE"}, EXPRESSION{"*", SYMBOL{"SIZE"}, NUMBER{2}}},
+		"hi(table)":         EXPRESSION{"hi", nil, LABEL{"table"}},
+	}
+	for input, m := range expected {
+		actual, err := parseExpression(input)
+		if err != nil {
+			t.Logf("%s: error parsing expression: %v", input, err)
+			t.FailNow()
+		}
+		if actual != m {
+			t.Logf("%s: expected %v but got %v", input, m, actual)
+			t.FailNow()
+		}
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"1 +",
+		"(1 + 2",
+		"1 + 2)",
+		"1 2",
+		"0x10000",
+		"65536",
+		"0xFFFF + 1",
+		"-0x8001",
+		"0x100 * 0x100",
+		"1 / 0",
+		"1 << 32",
+		"'AB'",
+		"'A",
+		"1 $ 2",
+		"%",
+	} {
+		if _, err := parseExpression(input); err == nil {
+			t.Logf("%s: expected an error", input)
+			t.FailNow()
+		}
+	}
+}
+
+func TestParseExpressionOverflowsBeforeTheOperatorIsApplied(t *testing.T) {
+	expected := map[string]string{
+		"(0xFFFF * 0xFFFF) * (0xFFFF * 0xFFFF) >> 31": "(0xFFFF * 0xFFFF) * (0xFFFF * 0xFFFF) overflows",
+		"(0xFFFF * 0xFFFF) << 31 >> 31":               "(0xFFFF * 0xFFFF) << 0x001F overflows",
+		"0x8000 << 17 >> 17":                          "0x8000 << 0x0011 overflows",
+		"1 << 40":                                     "0x0001 << 0x0028 shifts by 40, it can only shift by 0 - 31",
+	}
+	for input, message := range expected {
+		_, err := parseExpression(input)
+		if err == nil || err.Error() != message {
+			t.Logf("%s: expected the error %q but got %v", input, message, err)
+			t.FailNow()
+		}
+	}
+}
+
+func TestProcessResolvesExpressions(t *testing.T) {
+	p := Parser{}
+	instructions, err := p.Parse(strings.NewReader(`
+	%WIDTH = 30
+	%ROWS = %WIDTH / 10
+	%SIZE = end - table
+	%LAST = %SIZE - 1
+	start:
+		DATA R0, %SIZE * 2
+		DATA R1, hi(table) + %ROWS
+	table:
+		.fill %ROWS + 1, 'x'
+	end:
+		.word %LAST, end - start
+	`))
+	if err != nil {
+		t.Logf("Error parsing input: %v", err)
+		t.FailNow()
+	}
+
+	a := Assembler{}
+	result, err := a.Process(0x0500, instructions)
+	if err != nil {
+		t.Logf("Error assembling input: %v", err)
+		t.FailNow()
+	}
+
+	expected := []uint16{0x0020, 0x0008, 0x0021, 0x0008, 0x0078, 0x0078, 0x0078, 0x0078, 0x0003, 0x0008}
+	if len(result) != len(expected) {
+		t.Logf("Expected %v but got %v", expected, result)
+		t.FailNow()
+	}
+	for n := range expected {
+		if result[n] != expected[n] {
+			t.Logf("Expected %v but got %v", expected, result)
+			t.FailNow()
+		}
+	}
+}
+
+func TestProcessExpressionErrors(t *testing.T) {
+	for _, input := range []string{
+		"%A = %B + 1\n%B = %A + 1\nDATA R0, %A",
+		"%A = start * 0x100\nstart:\nDATA R0, %A",
+		"DATA R0, start * 0x100\nstart:",
+		"DATA R0, %UNKNOWN + 1",
+		"%A = start\n%A = 1",
+	} {
+		p := Parser{}
+		instructions, err := p.Parse(strings.NewReader(input))
+		if err != nil {
+			t.Logf("%q: error parsing input: %v", input, err)
+			t.FailNow()
+		}
+
+		a := Assembler{}
+		if _, err := a.Process(0x0500, instructions); err == nil {
+			t.Logf("%q: expected an error", input)
+			t.FailNow()
+		}
+	}
+}
+
+func TestProcessExpressionOverflowErrorIsAtItsInstruction(t *testing.T) {
+	p := Parser{Filename: "main.asm"}
+	instructions, err := p.Parse(strings.NewReader("start:\n\tDATA R3, end * 0x1000\nend:\n"))
+	if err != nil {
+		t.Logf("Error parsing input: %v", err)
+		t.FailNow()
+	}
+
+	a := Assembler{Positions: p.Positions()}
+	_, err = a.Process(0x0500, instructions)
+	expected := "main.asm:2: error: DATA R3, end * 0x1000: "
+	if err == nil || !strings.HasPrefix(err.Error(), expected) || !strings.HasSuffix(err.Error(), "does not fit in 16 bits") {
+		t.Logf("Expected an error starting with %q but got %v", expected, err)
+		t.FailNow()
+	}
+}
@@ -2,6 +2,7 @@ package asm
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/djhworld/simple-computer/utils"
@@ -60,28 +61,15 @@ func (d DATA) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) (
 		instruction = 0x0023
 	}
 
-	if v, ok := d.Data.(SYMBOL); ok {
-		//TODO get value from symbols map....
-		resolvedSymbol, err := symbolResolver(v)
-		if err != nil {
-			return nil, err
-		}
-		return []uint16{instruction, resolvedSymbol}, nil
-	} else if v, ok := d.Data.(NUMBER); ok {
-		return []uint16{instruction, v.Value}, nil
-	} else {
-		return nil, fmt.Errorf("Unsupported operand for Data %v", d.Data)
+	value, err := resolveMarker(d.Data, labelResolver, symbolResolver)
+	if err != nil {
+		return nil, err
 	}
+	return []uint16{instruction, value}, nil
 }
 
 func (d DATA) String() string {
-	if v, ok := d.Data.(NUMBER); ok {
-		return fmt.Sprintf("DATA R%d, %s", d.ToRegister, utils.ValueToString(v.Value))
-	} else if v, ok := d.Data.(SYMBOL); ok {
-		return fmt.Sprintf("DATA R%d, %s", d.ToRegister, v.String())
-	}
-
-	return fmt.Sprintf("DATA R%d, %v", d.ToRegister, d.Data)
+	return fmt.Sprintf("DATA R%d, %s", d.ToRegister, markerToString(d.Data))
 }
 
 // SHL
@@ -791,6 +779,304 @@ func (a ADD) String() string {
 	return result
 }
 
+// PUSH
+// ----------------------
+// 0x0100 = PUSH R0
+// 0x0101 = PUSH R1
+// 0x0102 = PUSH R2
+// 0x0103 = PUSH R3
+type PUSH struct {
+	Register REGISTER
+}
+
+func (p PUSH) Size() int {
+	return 1
+}
+
+func (p PUSH) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
+	var instruction uint16 = 0x0100 + uint16(p.Register)
+	return []uint16{instruction}, nil
+}
+
+func (p PUSH) String() string {
+	result := fmt.Sprintf("PUSH R%d", p.Register)
+	return result
+}
+
+// POP
+// ----------------------
+// 0x0110 = POP R0
+// 0x0111 = POP R1
+// 0x0112 = POP R2
+// 0x0113 = POP R3
+type POP struct {
+	Register REGISTER
+}
+
+func (p POP) Size() int {
+	return 1
+}
+
+func (p POP) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
+	var instruction uint16 = 0x0110 + uint16(p.Register)
+	return []uint16{instruction}, nil
+}
+
+func (p POP) String() string {
+	result := fmt.Sprintf("POP R%d", p.Register)
+	return result
+}
+
+// CALL
+// push the address of the next instruction to the stack and jump to the routine (2 byte instruction)
+// ----------------------
+// 0x0120 = CALL <value>
+type CALL struct {
+	Routine LABEL
+}
+
+func (c CALL) Size() int {
+	return 2
+}
+
+func (c CALL) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
+	var instruction uint16 = 0x0120
+	resolvedAddress, err := labelResolver(c.Routine)
+	if err != nil {
+		return nil, err
+	}
+	return []uint16{instruction, resolvedAddress}, nil
+}
+
+func (c CALL) String() string {
+	return fmt.Sprintf("CALL %s", c.Routine)
+}
+
+// RET
+// return to the address pushed by CALL
+// ----------------------
+// 0x0130 = RET
+type RET struct {
+}
+
+func (r RET) Size() int {
+	return 1
+}
+
+func (r RET) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
+	return []uint16{0x0130}, nil
+}
+
+func (r RET) String() string {
+	return "RET"
+}
+
+// EI
+// enable interrupts
+// ----------------------
+// 0x0140 = EI
+type EI struct {
+}
+
+func (e EI) Size() int {
+	return 1
+}
+
+func (e EI) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
+	return []uint16{0x0140}, nil
+}
+
+func (e EI) String() string {
+	return "EI"
+}
+
+// DI
+// disable interrupts
+// ----------------------
+// 0x0150 = DI
+type DI struct {
+}
+
+func (d DI) Size() int {
+	return 1
+}
+
+func (d DI) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
+	return []uint16{0x0150}, nil
+}
+
+func (d DI) String() string {
+	return "DI"
+}
+
+// IRET
+// return from an interrupt handler to the saved instruction address and flags
+// ----------------------
+// 0x0160 = IRET
+type IRET struct {
+}
+
+func (i IRET) Size() int {
+	return 1
+}
+
+func (i IRET) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
+	return []uint16{0x0160}, nil
+}
+
+func (i IRET) String() string {
+	return "IRET"
+}
+
+// SUB
+// subtract register B from register A, the result is stored in register B
+// ----------------------
+// 0x0170 = SUB R0, R0
+// 0x0171 = SUB R0, R1
+// 0x0172 = SUB R0, R2
+// 0x0173 = SUB R0, R3
+
+// 0x0174 = SUB R1, R0
+// 0x0175 = SUB R1, R1
+// 0x0176 = SUB R1, R2
+// 0x0177 = SUB R1, R3
+
+// 0x0178 = SUB R2, R0
+// 0x0179 = SUB R2, R1
+// 0x017A = SUB R2, R2
+// 0x017B = SUB R2, R3
+
+// 0x017C = SUB R3, R0
+// 0x017D = SUB R3, R1
+// 0x017E = SUB R3, R2
+// 0x017F = SUB R3, R3
+type SUB struct {
+	ARegister REGISTER
+	BRegister REGISTER
+}
+
+func (s SUB) Size() int {
+	return 1
+}
+
+func (s SUB) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
+	var instruction uint16 = 0x0170 + (uint16(s.ARegister) << 2) + uint16(s.BRegister)
+	return []uint16{instruction}, nil
+}
+
+func (s SUB) String() string {
+	result := fmt.Sprintf("SUB R%d, R%d", s.ARegister, s.BRegister)
+	return result
+}
+
+// MOV
+// copy register A into register B
+// ----------------------
+// 0x0180 = MOV R0, R0
+// 0x0181 = MOV R0, R1
+// 0x0182 = MOV R0, R2
+// 0x0183 = MOV R0, R3
+
+// 0x0184 = MOV R1, R0
+// 0x0185 = MOV R1, R1
+// 0x0186 = MOV R1, R2
+// 0x0187 = MOV R1, R3
+
+// 0x0188 = MOV R2, R0
+// 0x0189 = MOV R2, R1
+// 0x018A = MOV R2, R2
+// 0x018B = MOV R2, R3
+
+// 0x018C = MOV R3, R0
+// 0x018D = MOV R3, R1
+// 0x018E = MOV R3, R2
+// 0x018F = MOV R3, R3
+type MOV struct {
+	ARegister REGISTER
+	BRegister REGISTER
+}
+
+func (m MOV) Size() int {
+	return 1
+}
+
+func (m MOV) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
+	var instruction uint16 = 0x0180 + (uint16(m.ARegister) << 2) + uint16(m.BRegister)
+	return []uint16{instruction}, nil
+}
+
+func (m MOV) String() string {
+	result := fmt.Sprintf("MOV R%d, R%d", m.ARegister, m.BRegister)
+	return result
+}
+
+// INC
+// ----------------------
+// 0x0190 = INC R0
+// 0x0191 = INC R1
+// 0x0192 = INC R2
+// 0x0193 = INC R3
+type INC struct {
+	Register REGISTER
+}
+
+func (i INC) Size() int {
+	return 1
+}
+
+func (i INC) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
+	var instruction uint16 = 0x0190 + uint16(i.Register)
+	return []uint16{instruction}, nil
+}
+
+func (i INC) String() string {
+	result := fmt.Sprintf("INC R%d", i.Register)
+	return result
+}
+
+// DEC
+// ----------------------
+// 0x01A0 = DEC R0
+// 0x01A1 = DEC R1
+// 0x01A2 = DEC R2
+// 0x01A3 = DEC R3
+type DEC struct {
+	Register REGISTER
+}
+
+func (d DEC) Size() int {
+	return 1
+}
+
+func (d DEC) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
+	var instruction uint16 = 0x01A0 + uint16(d.Register)
+	return []uint16{instruction}, nil
+}
+
+func (d DEC) String() string {
+	result := fmt.Sprintf("DEC R%d", d.Register)
+	return result
+}
+
+// HALT
+// stop the computer, R0 is the exit code
+// ----------------------
+// 0x01B0 = HALT
+type HALT struct {
+}
+
+func (h HALT) Size() int {
+	return 1
+}
+
+func (h HALT) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
+	return []uint16{0x01B0}, nil
+}
+
+func (h HALT) String() string {
+	return "HALT"
+}
+
 // PLACEHOLDER INSTRUCTIONS - these are used by the assembler
 type DEFLABEL struct {
 	Name string
@@ -827,41 +1113,156 @@ func (s DEFSYMBOL) String() string {
 	return fmt.Sprintf("%%%s = 0x%X", s.Name, s.Value)
 }
 
-// PSUEDO INSTRUCTIONS - these are  composite instructions that may map to multiple opcodes
+// DEFEXPRESSION defines a symbol with an expression that uses labels or other symbols, the assembler works
+// out the value once it knows where the labels are
+type DEFEXPRESSION struct {
+	Name       string
+	Expression marker
+}
 
-type CALL struct {
-	Routine LABEL
+func (s DEFEXPRESSION) Size() int {
+	return 0
 }
 
-func (c CALL) Size() int {
-	return 4
+func (s DEFEXPRESSION) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
+	// noop
+	return nil, nil
 }
 
-func (c CALL) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
-	nextInsAddress, err := symbolResolver(SYMBOL{NEXTINSTRUCTION})
-	if err != nil {
-		return nil, err
-	}
+func (s DEFEXPRESSION) String() string {
+	return fmt.Sprintf("%%%s = %s", s.Name, markerToString(s.Expression))
+}
+
+// MACROCALL marks where the instructions of a macro call start, it is followed by the expanded instructions
+type MACROCALL struct {
+	Name      string
+	Arguments string
+}
+
+func (m MACROCALL) Size() int {
+	return 0
+}
 
-	compositeInstructions := []Instruction{
-		DATA{REG3, NUMBER{nextInsAddress}},
-		JMP{c.Routine},
+func (m MACROCALL) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
+	// noop
+	return nil, nil
+}
+
+func (m MACROCALL) String() string {
+	if m.Arguments == "" {
+		return m.Name
 	}
+	return fmt.Sprintf("%s %s", m.Name, m.Arguments)
+}
+
+// DATA DIRECTIVES - these put words into the program as they are, e.g. tables and strings for the program to read
 
-	emitted := []uint16{}
-	for _, ins := range compositeInstructions {
-		if e, err := ins.Emit(labelResolver, symbolResolver); err == nil {
-			emitted = append(emitted, e...)
-		} else {
+// WORDS puts each value into the program, a value can be a number, symbol or the address of a label
+type WORDS struct {
+	Values []marker
+}
+
+func (w WORDS) Size() int {
+	return len(w.Values)
+}
+
+func (w WORDS) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
+	words := []uint16{}
+	for _, v := range w.Values {
+		value, err := resolveMarker(v, labelResolver, symbolResolver)
+		if err != nil {
 			return nil, err
 		}
+		words = append(words, value)
+	}
+	return words, nil
+}
+
+func (w WORDS) String() string {
+	values := []string{}
+	for _, v := range w.Values {
+		values = append(values, markerToString(v))
 	}
+	return fmt.Sprintf(".word %s", strings.Join(values, ", "))
+}
 
-	return emitted, nil
+// STRING puts the characters of a string into the program one to a word, followed by a 0 word or
+// after a word with the number of characters when LengthPrefixed is set
+type STRING struct {
+	Text           string
+	LengthPrefixed bool
 }
 
-func (c CALL) String() string {
-	return fmt.Sprintf("CALL %s", c.Routine)
+func (s STRING) Size() int {
+	return len([]rune(s.Text)) + 1
+}
+
+func (s STRING) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
+	characters := []uint16{}
+	for _, r := range s.Text {
+		if r > 0xFFFF {
+			return nil, fmt.Errorf("character '%c' of string %q does not fit in a word", r, s.Text)
+		}
+		characters = append(characters, uint16(r))
+	}
+
+	if s.LengthPrefixed {
+		return append([]uint16{uint16(len(characters))}, characters...), nil
+	}
+	return append(characters, 0x0000), nil
+}
+
+func (s STRING) String() string {
+	if s.LengthPrefixed {
+		return fmt.Sprintf(".pstring %s", strconv.Quote(s.Text))
+	}
+	return fmt.Sprintf(".string %s", strconv.Quote(s.Text))
+}
+
+// FILL puts Count words of the same value into the program
+type FILL struct {
+	Count int
+	Value marker
+}
+
+func (f FILL) Size() int {
+	return f.Count
+}
+
+func (f FILL) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
+	value, err := resolveMarker(f.Value, labelResolver, symbolResolver)
+	if err != nil {
+		return nil, err
+	}
+
+	words := make([]uint16, f.Count)
+	for i := range words {
+		words[i] = value
+	}
+	return words, nil
+}
+
+func (f FILL) String() string {
+	return fmt.Sprintf(".fill %d, %s", f.Count, markerToString(f.Value))
+}
+
+// ORG moves the next instruction on to Address, the words in between are 0. The assembler works out
+// the Padding from where the ORG is
+type ORG struct {
+	Address uint16
+	Padding int
+}
+
+func (o ORG) Size() int {
+	return o.Padding
+}
+
+func (o ORG) Emit(labelResolver LabelResolver, symbolResolver SymbolResolver) ([]uint16, error) {
+	return make([]uint16, o.Padding), nil
+}
+
+func (o ORG) String() string {
+	return fmt.Sprintf(".org %s", utils.ValueToString(o.Address))
 }
 
 // Instructions - useful list data structure for convienience
@@ -126,6 +126,40 @@ func TestTwoRegInstructionsString(t *testing.T) {
 		CMP{REG3, REG1}: "CMP R3, R1",
 		CMP{REG3, REG2}: "CMP R3, R2",
 		CMP{REG3, REG3}: "CMP R3, R3",
+
+		SUB{REG0, REG0}: "SUB R0, R0",
+		SUB{REG0, REG1}: "SUB R0, R1",
+		SUB{REG0, REG2}: "SUB R0, R2",
+		SUB{REG0, REG3}: "SUB R0, R3",
+		SUB{REG1, REG0}: "SUB R1, R0",
+		SUB{REG1, REG1}: "SUB R1, R1",
+		SUB{REG1, REG2}: "SUB R1, R2",
+		SUB{REG1, REG3}: "SUB R1, R3",
+		SUB{REG2, REG0}: "SUB R2, R0",
+		SUB{REG2, REG1}: "SUB R2, R1",
+		SUB{REG2, REG2}: "SUB R2, R2",
+		SUB{REG2, REG3}: "SUB R2, R3",
+		SUB{REG3, REG0}: "SUB R3, R0",
+		SUB{REG3, REG1}: "SUB R3, R1",
+		SUB{REG3, REG2}: "SUB R3, R2",
+		SUB{REG3, REG3}: "SUB R3, R3",
+
+		MOV{REG0, REG0}: "MOV R0, R0",
+		MOV{REG0, REG1}: "MOV R0, R1",
+		MOV{REG0, REG2}: "MOV R0, R2",
+		MOV{REG0, REG3}: "MOV R0, R3",
+		MOV{REG1, REG0}: "MOV R1, R0",
+		MOV{REG1, REG1}: "MOV R1, R1",
+		MOV{REG1, REG2}: "MOV R1, R2",
+		MOV{REG1, REG3}: "MOV R1, R3",
+		MOV{REG2, REG0}: "MOV R2, R0",
+		MOV{REG2, REG1}: "MOV R2, R1",
+		MOV{REG2, REG2}: "MOV R2, R2",
+		MOV{REG2, REG3}: "MOV R2, R3",
+		MOV{REG3, REG0}: "MOV R3, R0",
+		MOV{REG3, REG1}: "MOV R3, R1",
+		MOV{REG3, REG2}: "MOV R3, R2",
+		MOV{REG3, REG3}: "MOV R3, R3",
 	}
 
 	for ins, expected := range TABLE {
@@ -256,6 +290,40 @@ func TestTwoRegInstructions(t *testing.T) {
 		CMP{REG3, REG1}: []uint16{0xFD},
 		CMP{REG3, REG2}: []uint16{0xFE},
 		CMP{REG3, REG3}: []uint16{0xFF},
+
+		SUB{REG0, REG0}: []uint16{0x0170},
+		SUB{REG0, REG1}: []uint16{0x0171},
+		SUB{REG0, REG2}: []uint16{0x0172},
+		SUB{REG0, REG3}: []uint16{0x0173},
+		SUB{REG1, REG0}: []uint16{0x0174},
+		SUB{REG1, REG1}: []uint16{0x0175},
+		SUB{REG1, REG2}: []uint16{0x0176},
+		SUB{REG1, REG3}: []uint16{0x0177},
+		SUB{REG2, REG0}: []uint16{0x0178},
+		SUB{REG2, REG1}: []uint16{0x0179},
+		SUB{REG2, REG2}: []uint16{0x017A},
+		SUB{REG2, REG3}: []uint16{0x017B},
+		SUB{REG3, REG0}: []uint16{0x017C},
+		SUB{REG3, REG1}: []uint16{0x017D},
+		SUB{REG3, REG2}: []uint16{0x017E},
+		SUB{REG3, REG3}: []uint16{0x017F},
+
+		MOV{REG0, REG0}: []uint16{0x0180},
+		MOV{REG0, REG1}: []uint16{0x0181},
+		MOV{REG0, REG2}: []uint16{0x0182},
+		MOV{REG0, REG3}: []uint16{0x0183},
+		MOV{REG1, REG0}: []uint16{0x0184},
+		MOV{REG1, REG1}: []uint16{0x0185},
+		MOV{REG1, REG2}: []uint16{0x0186},
+		MOV{REG1, REG3}: []uint16{0x0187},
+		MOV{REG2, REG0}: []uint16{0x0188},
+		MOV{REG2, REG1}: []uint16{0x0189},
+		MOV{REG2, REG2}: []uint16{0x018A},
+		MOV{REG2, REG3}: []uint16{0x018B},
+		MOV{REG3, REG0}: []uint16{0x018C},
+		MOV{REG3, REG1}: []uint16{0x018D},
+		MOV{REG3, REG2}: []uint16{0x018E},
+		MOV{REG3, REG3}: []uint16{0x018F},
 	}
 
 	for ins, expected := range TABLE {
@@ -292,6 +360,26 @@ func TestOneRegInstructionsString(t *testing.T) {
 		JR{REG1}: "JR R1",
 		JR{REG2}: "JR R2",
 		JR{REG3}: "JR R3",
+
+		PUSH{REG0}: "PUSH R0",
+		PUSH{REG1}: "PUSH R1",
+		PUSH{REG2}: "PUSH R2",
+		PUSH{REG3}: "PUSH R3",
+
+		POP{REG0}: "POP R0",
+		POP{REG1}: "POP R1",
+		POP{REG2}: "POP R2",
+		POP{REG3}: "POP R3",
+
+		INC{REG0}: "INC R0",
+		INC{REG1}: "INC R1",
+		INC{REG2}: "INC R2",
+		INC{REG3}: "INC R3",
+
+		DEC{REG0}: "DEC R0",
+		DEC{REG1}: "DEC R1",
+		DEC{REG2}: "DEC R2",
+		DEC{REG3}: "DEC R3",
 	}
 
 	for ins, expected := range TABLE {
@@ -323,6 +411,26 @@ func TestOneRegInstructions(t *testing.T) {
 		JR{REG1}: []uint16{0x31},
 		JR{REG2}: []uint16{0x32},
 		JR{REG3}: []uint16{0x33},
+
+		PUSH{REG0}: []uint16{0x0100},
+		PUSH{REG1}: []uint16{0x0101},
+		PUSH{REG2}: []uint16{0x0102},
+		PUSH{REG3}: []uint16{0x0103},
+
+		POP{REG0}: []uint16{0x0110},
+		POP{REG1}: []uint16{0x0111},
+		POP{REG2}: []uint16{0x0112},
+		POP{REG3}: []uint16{0x0113},
+
+		INC{REG0}: []uint16{0x0190},
+		INC{REG1}: []uint16{0x0191},
+		INC{REG2}: []uint16{0x0192},
+		INC{REG3}: []uint16{0x0193},
+
+		DEC{REG0}: []uint16{0x01A0},
+		DEC{REG1}: []uint16{0x01A1},
+		DEC{REG2}: []uint16{0x01A2},
+		DEC{REG3}: []uint16{0x01A3},
 	}
 
 	for ins, expected := range TABLE {
@@ -398,6 +506,58 @@ func TestDATAInstruction(t *testing.T) {
 	}
 }
 
+func TestDataDirectivesString(t *testing.T) {
+	var TABLE = []struct {
+		ins      Instruction
+		expected string
+	}{
+		{WORDS{[]marker{NUMBER{0x12}, SYMBOL{"foo"}, LABEL{"bar"}}}, ".word 0x0012, %foo, bar"},
+		{STRING{"Hi\n", false}, `.string "Hi\n"`},
+		{STRING{"Hi", true}, `.pstring "Hi"`},
+		{FILL{3, NUMBER{0xFFFF}}, ".fill 3, 0xFFFF"},
+		{ORG{0x0600, 0}, ".org 0x0600"},
+	}
+
+	for _, test := range TABLE {
+		if test.ins.String() != test.expected {
+			t.Logf("Expected %s got %s", test.expected, test.ins.String())
+			t.FailNow()
+		}
+	}
+}
+
+func TestDataDirectives(t *testing.T) {
+	var TABLE = []struct {
+		ins      Instruction
+		expected []uint16
+	}{
+		{WORDS{[]marker{NUMBER{0x12}, SYMBOL{"foo"}, LABEL{"bar"}}}, []uint16{0x0012, 0xA000, 0x0510}},
+		{STRING{"Hi\n", false}, []uint16{'H', 'i', '\n', 0x0000}},
+		{STRING{"Hi", true}, []uint16{0x0002, 'H', 'i'}},
+		{FILL{3, SYMBOL{"foo"}}, []uint16{0xA000, 0xA000, 0xA000}},
+		{ORG{0x0600, 2}, []uint16{0x0000, 0x0000}},
+	}
+
+	dummyLabelResolver := func(l LABEL) (uint16, error) {
+		return 0x0510, nil
+	}
+	dummySymbolResolver := func(s SYMBOL) (uint16, error) {
+		return 0xA000, nil
+	}
+
+	for _, test := range TABLE {
+		emit, err := test.ins.Emit(dummyLabelResolver, dummySymbolResolver)
+		if err != nil {
+			t.Logf("Got error %v when testing %s", err, test.ins)
+			t.FailNow()
+		}
+		if !reflect.DeepEqual(emit, test.expected) || len(emit) != test.ins.Size() {
+			t.Logf("Expected %v (size %d) got %v when testing %s", test.expected, test.ins.Size(), emit, test.ins)
+			t.FailNow()
+		}
+	}
+}
+
 func TestIOInstructionsString(t *testing.T) {
 	var TABLE map[Instruction]string = map[Instruction]string{
 		IN{DATA_MODE, REG0}: "IN Data, R0",
@@ -507,7 +667,12 @@ func TestJMPInstruction(t *testing.T) {
 
 func TestCLFInstructionString(t *testing.T) {
 	var TABLE map[Instruction]string = map[Instruction]string{
-		CLF{}: "CLF",
+		CLF{}:  "CLF",
+		RET{}:  "RET",
+		EI{}:   "EI",
+		DI{}:   "DI",
+		IRET{}: "IRET",
+		HALT{}: "HALT",
 	}
 
 	for ins, expected := range TABLE {
@@ -533,8 +698,8 @@ func TestCALLInstructionString(t *testing.T) {
 }
 func TestCALLInstruction(t *testing.T) {
 	var TABLE map[Instruction][]uint16 = map[Instruction][]uint16{
-		CALL{LABEL{"foo"}}: []uint16{0x23, 0x1234, 0x40, 0x0001},
-		CALL{LABEL{"bar"}}: []uint16{0x23, 0x1234, 0x40, 0x0002},
+		CALL{LABEL{"foo"}}: []uint16{0x0120, 0x0001},
+		CALL{LABEL{"bar"}}: []uint16{0x0120, 0x0002},
 	}
 	dummyLabelResolver := func(l LABEL) (uint16, error) {
 		if l.Name == "foo" {
@@ -569,7 +734,12 @@ func TestCALLInstruction(t *testing.T) {
 }
 func TestCLFInstruction(t *testing.T) {
 	var TABLE map[Instruction][]uint16 = map[Instruction][]uint16{
-		CLF{}: []uint16{0x60},
+		CLF{}:  []uint16{0x60},
+		RET{}:  []uint16{0x0130},
+		EI{}:   []uint16{0x0140},
+		DI{}:   []uint16{0x0150},
+		IRET{}: []uint16{0x0160},
+		HALT{}: []uint16{0x01B0},
 	}
 
 	for ins, expected := range TABLE {
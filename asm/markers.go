@@ -1,6 +1,10 @@
 package asm
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/djhworld/simple-computer/utils"
+)
 
 type marker interface {
 	placeholder()
@@ -38,3 +42,25 @@ func (n NUMBER) placeholder() {
 func (n NUMBER) String() string {
 	return fmt.Sprintf("0x%X", n.Value)
 }
+
+// resolveMarker returns the value of a number, symbol, expression or the address of a label
+func resolveMarker(m marker, labelResolver LabelResolver, symbolResolver SymbolResolver) (uint16, error) {
+	switch v := m.(type) {
+	case NUMBER:
+		return v.Value, nil
+	case SYMBOL:
+		return symbolResolver(v)
+	case LABEL:
+		return labelResolver(v)
+	case EXPRESSION:
+		return v.resolve(labelResolver, symbolResolver)
+	}
+	return 0x0000, fmt.Errorf("Unsupported value %v", m)
+}
+
+func markerToString(m marker) string {
+	if v, ok := m.(NUMBER); ok {
+		return utils.ValueToString(v.Value)
+	}
+	return fmt.Sprintf("%v", m)
+}
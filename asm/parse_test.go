@@ -1,6 +1,9 @@
 package asm
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -90,6 +93,43 @@ func TestParseADD(t *testing.T) {
 
 }
 
+func TestParseSUB(t *testing.T) {
+	input := `
+		SUB R0, R1
+		SUB R1,R0
+		SUB   R2,   R3
+	`
+
+	expected := []Instruction{SUB{REG0, REG1}, SUB{REG1, REG0}, SUB{REG2, REG3}}
+
+	testParseInstructions(input, expected, t)
+}
+
+func TestParseMOV(t *testing.T) {
+	input := `
+		MOV R0, R1
+		MOV R1,R0
+		MOV   R2,   R3
+	`
+
+	expected := []Instruction{MOV{REG0, REG1}, MOV{REG1, REG0}, MOV{REG2, REG3}}
+
+	testParseInstructions(input, expected, t)
+}
+
+func TestParseINCAndDEC(t *testing.T) {
+	input := `
+		INC R0
+		INC  R3
+		DEC R1
+		DEC    R2
+	`
+
+	expected := []Instruction{INC{REG0}, INC{REG3}, DEC{REG1}, DEC{REG2}}
+
+	testParseInstructions(input, expected, t)
+}
+
 func TestParseLD(t *testing.T) {
 	input := `
 		LD R0, R1
@@ -234,6 +274,56 @@ func TestParseJR(t *testing.T) {
 	testParseInstructions(input, expected, t)
 }
 
+func TestParseStack(t *testing.T) {
+	input := `
+	PUSH R0
+	PUSH  R1
+	POP R2
+	POP    R3
+	RET
+	`
+
+	expected := []Instruction{
+		PUSH{REG0},
+		PUSH{REG1},
+		POP{REG2},
+		POP{REG3},
+		RET{},
+	}
+
+	testParseInstructions(input, expected, t)
+}
+
+func TestParseInterrupts(t *testing.T) {
+	input := `
+	EI
+	DI
+	IRET
+	`
+
+	expected := []Instruction{
+		EI{},
+		DI{},
+		IRET{},
+	}
+
+	testParseInstructions(input, expected, t)
+}
+
+func TestParseHALT(t *testing.T) {
+	input := `
+	DATA R0, 0x0001
+	HALT
+	`
+
+	expected := []Instruction{
+		DATA{REG0, NUMBER{0x0001}},
+		HALT{},
+	}
+
+	testParseInstructions(input, expected, t)
+}
+
 func TestParseDATA(t *testing.T) {
 	input := `
 		DATA R0, %foo
@@ -264,6 +354,71 @@ func TestParseDATA(t *testing.T) {
 	testParseInstructions(input, expected, t)
 }
 
+func TestParseDATALabel(t *testing.T) {
+	input := `
+		DATA R0, message
+		DATA R1, data-table
+		DATA R2, 0x0abc
+	`
+
+	expected := []Instruction{
+		DATA{REG0, LABEL{"message"}},
+		DATA{REG1, LABEL{"data-table"}},
+		DATA{REG2, NUMBER{0x0ABC}},
+	}
+
+	testParseInstructions(input, expected, t)
+}
+
+func TestParseDataDirectives(t *testing.T) {
+	input := `
+	message:
+		.string "Hello; \"world\"\n" ; a comment
+		.pstring "Hi"
+	table:
+		.word 0x1234, 42, %FOO, message
+		.fill 3, 0xFFFF
+		.fill 0x10
+		.org 0x0600
+	`
+
+	expected := []Instruction{
+		DEFLABEL{"message"},
+		STRING{"Hello; \"world\"\n", false},
+		STRING{"Hi", true},
+		DEFLABEL{"table"},
+		WORDS{[]marker{NUMBER{0x1234}, NUMBER{42}, SYMBOL{"FOO"}, LABEL{"message"}}},
+		FILL{3, NUMBER{0xFFFF}},
+		FILL{0x10, NUMBER{0x0000}},
+		ORG{0x0600, 0},
+	}
+
+	testParseInstructions(input, expected, t)
+}
+
+func TestParseDataDirectiveErrors(t *testing.T) {
+	for _, input := range []string{
+		".string Hello",
+		".string \"Hello",
+		".string \"Hello\" R0",
+		".word",
+		".word 0x10000",
+		".word R0 R1",
+		".fill",
+		".fill %COUNT, 1",
+		".fill 1, 2, 3",
+		".org",
+		".org start",
+		".bytes 0x1",
+	} {
+		p := Parser{}
+		if _, err := p.Parse(strings.NewReader(input)); err == nil {
+			t.Logf("expected an error parsing %q", input)
+			t.FailNow()
+		}
+	}
+}
+
 func TestParseJMP(t *testing.T) {
 	input := `
 		JMP mylabel
@@ -410,6 +565,360 @@ func TestParseSimpleProgram(t *testing.T) {
 	testParseInstructions(input, expected, t)
 }
 
+func TestParseMacro(t *testing.T) {
+	input := `
+	MACRO select-adapter reg, address ; select an IO adapter
+		DATA \reg, \address
+		OUT Addr, \reg
+	ENDM
+	MACRO deselect-io
+		XOR R3, R3
+		OUT Addr, R3
+	ENDM
+
+	select-adapter R2, %DISPLAY-ADAPTER-ADDR
+	deselect-io
+	select-adapter R0, 0x000F
+	`
+
+	expected := []Instruction{
+		MACROCALL{"select-adapter", "R2, %DISPLAY-ADAPTER-ADDR"},
+		DATA{REG2, SYMBOL{"DISPLAY-ADAPTER-ADDR"}},
+		OUT{ADDRESS_MODE, REG2},
+		MACROCALL{"deselect-io", ""},
+		XOR{REG3, REG3},
+		OUT{ADDRESS_MODE, REG3},
+		MACROCALL{"select-adapter", "R0, 0x000F"},
+		DATA{REG0, NUMBER{0x000F}},
+		OUT{ADDRESS_MODE, REG0},
+	}
+
+	testParseInstructions(input, expected, t)
+}
+
+func TestParseMacroLabels(t *testing.T) {
+	input := `
+	MACRO count-to reg, end, done
+		XOR \reg, \reg
+	loop:
+		INC \reg
+		CLF
+		CMP \reg, R3
+		JMPE \done
+		JMP loop
+	ENDM
+	MACRO count-twice reg
+		count-to \reg, 0x2, next
+	next:
+		count-to \reg, 0x3, main
+	ENDM
+
+	main:
+	count-twice R1
+	`
+
+	expected := []Instruction{
+		DEFLABEL{"main"},
+		MACROCALL{"count-twice", "R1"},
+		MACROCALL{"count-to", "R1, 0x2, next-count-twice-1"},
+		XOR{REG1, REG1},
+		DEFLABEL{"loop-count-to-2"},
+		INC{REG1},
+		CLF{},
+		CMP{REG1, REG3},
+		JMPF{[]string{"E"}, LABEL{"next-count-twice-1"}},
+		JMP{LABEL{"loop-count-to-2"}},
+		DEFLABEL{"next-count-twice-1"},
+		MACROCALL{"count-to", "R1, 0x3, main"},
+		XOR{REG1, REG1},
+		DEFLABEL{"loop-count-to-3"},
+		INC{REG1},
+		CLF{},
+		CMP{REG1, REG3},
+		JMPF{[]string{"E"}, LABEL{"main"}},
+		JMP{LABEL{"loop-count-to-3"}},
+	}
+
+	testParseInstructions(input, expected, t)
+}
+
+func TestParseMacroErrors(t *testing.T) {
+	inputs := map[string]string{
+		"missing ENDM":         "MACRO foo\nCLF",
+		"ENDM without MACRO":   "CLF\nENDM",
+		"nested definition":    "MACRO foo\nMACRO bar\nENDM\nENDM",
+		"defined twice":        "MACRO foo\nENDM\nMACRO foo\nENDM",
+		"wrong argument count": "MACRO foo a, b\nENDM\nfoo R0",
+		"unknown parameter":    "MACRO foo a\nDATA R0, \\b\nENDM\nfoo 0x1",
+		"invalid argument":     "MACRO foo a\nENDM\nfoo R0 R1",
+		"recursive":            "MACRO foo\nfoo\nENDM\nfoo",
+	}
+
+	for name, input := range inputs {
+		p := Parser{}
+		if _, err := p.Parse(strings.NewReader(input)); err == nil {
+			t.Logf("%s: expected an error parsing %q", name, input)
+			t.FailNow()
+		}
+	}
+}
+
+func TestParseInclude(t *testing.T) {
+	dir := writeAsmFiles(t, map[string]string{
+		"main.asm":       ".include \"lib/screen.asm\"\nmain:\n\tselect-display\n\t.include \"halt.asm\" ; from the include path",
+		"lib/screen.asm": "%DISPLAY-ADAPTER-ADDR = 0x7\nMACRO select-display\n\tDATA R0, %DISPLAY-ADAPTER-ADDR\n\tOUT Addr, R0\nENDM",
+		"lib/halt.asm":   "CLF\nHALT",
+	})
+	defer os.RemoveAll(dir)
+
+	main := filepath.Join(dir, "main.asm")
+	p := Parser{Filename: main, IncludePaths: []string{filepath.Join(dir, "lib")}}
+	result, err := p.Parse(strings.NewReader(readAsmFile(t, main)))
+	if err != nil {
+		t.Logf("encountered error %v", err)
+		t.FailNow()
+	}
+
+	expected := []Instruction{
+		DEFSYMBOL{"DISPLAY-ADAPTER-ADDR", 0x7},
+		DEFLABEL{"main"},
+		MACROCALL{"select-display", ""},
+		DATA{REG0, SYMBOL{"DISPLAY-ADAPTER-ADDR"}},
+		OUT{ADDRESS_MODE, REG0},
+		CLF{},
+		HALT{},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Logf("expected %v but got %v", expected, result)
+		t.FailNow()
+	}
+
+	halt := filepath.Join(dir, "lib", "halt.asm")
+	expectedPositions := []SourcePosition{
+		{filepath.Join(dir, "lib", "screen.asm"), 1},
+		{main, 2},
+		{main, 3},
+		{main, 3},
+		{main, 3},
+		{halt, 1},
+		{halt, 2},
+	}
+	if !reflect.DeepEqual(p.Positions(), expectedPositions) {
+		t.Logf("expected positions %v but got %v", expectedPositions, p.Positions())
+		t.FailNow()
+	}
+}
+
+func TestParseIncludeErrors(t *testing.T) {
+	dir := writeAsmFiles(t, map[string]string{
+		"cycle.asm":      "CLF\n.include \"cycle-2.asm\"",
+		"cycle-2.asm":    ".include \"cycle.asm\"",
+		"broken.asm":     ".include \"lib/broken.asm\"",
+		"lib/broken.asm": "CLF\nfoo",
+		"missing.asm":    "\n.include \"missing-lib.asm\"",
+	})
+	defer os.RemoveAll(dir)
+
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+	expected := map[string]string{
+		"cycle.asm": path("cycle-2.asm") + ":1:10: error: " + path("cycle.asm") + " includes itself: " +
+			path("cycle.asm") + " -> " + path("cycle-2.asm") + " -> " + path("cycle.asm") + ", included from " + path("cycle.asm") + ":2",
+		"broken.asm":  path("lib/broken.asm") + ":2:1: error: unknown instruction 'foo', included from " + path("broken.asm") + ":1",
+		"missing.asm": path("missing.asm") + ":2:10: error: cannot find included file missing-lib.asm",
+	}
+
+	for name, message := range expected {
+		p := Parser{Filename: path(name)}
+		_, err := p.Parse(strings.NewReader(readAsmFile(t, path(name))))
+		if err == nil || !strings.HasPrefix(err.Error(), message) {
+			t.Logf("%s: expected the error %q but got %v", name, message, err)
+			t.FailNow()
+		}
+	}
+}
+
+// writeAsmFiles writes files to a new temporary directory and returns the directory
+func TestParseComments(t *testing.T) {
+	input := `
+	; a comment on a line of its own
+	// and another one
+	%SIZE = 0x10 // the size
+	main: DATA R0, %SIZE ; a label and an instruction on the same line
+	loop:	INC R0 // count up
+		JMP loop;
+	.string "a ; and a // in a string"
+	`
+
+	expected := []Instruction{
+		DEFSYMBOL{"SIZE", 0x10},
+		DEFLABEL{"main"},
+		DATA{REG0, SYMBOL{"SIZE"}},
+		DEFLABEL{"loop"},
+		INC{REG0},
+		JMP{LABEL{"loop"}},
+		STRING{"a ; and a // in a string", false},
+	}
+
+	testParseInstructions(input, expected, t)
+}
+
+func TestParseRejectsAnythingAfterTheOperands(t *testing.T) {
+	for _, input := range []string{
+		"HALT R0",
+		"SHL R3, R3",
+		"ADD R0, R1 R2",
+		"ADD R0, R1,",
+		"JMP loop now",
+		"OUT Addr, R0, R1",
+		"DATA R0, 0x1 0x2",
+		"%SIZE = 1 2",
+		"ENDM R0",
+		".include \"lib.asm\" \"other.asm\"",
+	} {
+		p := Parser{}
+		if _, err := p.Parse(strings.NewReader(input)); err == nil {
+			t.Logf("%s: expected an error", input)
+			t.FailNow()
+		}
+	}
+}
+
+func TestParseReportsEveryError(t *testing.T) {
+	input := "main:\n" +
+		"\tADD R0, R7\n" +
+		"\tHALT\n" +
+		"\tDATA R1, 0x10000 ; too big\n" +
+		"\tfoo R1\n" +
+		"\tOUT Addr R0\n" +
+		"\tJMP main\n"
+
+	p := Parser{Filename: "main.asm"}
+	_, err := p.Parse(strings.NewReader(input))
+	diagnostics, ok := err.(Diagnostics)
+	if !ok {
+		t.Logf("expected Diagnostics but got %v", err)
+		t.FailNow()
+	}
+
+	expected := []string{
+		"main.asm:2:10: error: unknown register R7, the registers are R0 - R3",
+		"main.asm:4:11: error: 0x10000 does not fit in 16 bits",
+		"main.asm:5:2: error: unknown instruction 'foo'",
+		"main.asm:6:11: error: expected ',' after OUT Addr but got 'R0'",
+	}
+	if len(diagnostics) != len(expected) {
+		t.Logf("expected %d errors but got %d:\n%v", len(expected), len(diagnostics), diagnostics)
+		t.FailNow()
+	}
+	for n, diagnostic := range diagnostics {
+		if diagnostic.String() != expected[n] {
+			t.Logf("expected the error %q but got %q", expected[n], diagnostic.String())
+			t.FailNow()
+		}
+	}
+}
+
+func TestParseReturnsTheLinesThatParseWithTheErrors(t *testing.T) {
+	input := "main:\n" +
+		"\tfoo R1\n" +
+		"\tJMP nowhere\n" +
+		"main:\n"
+
+	p := Parser{Filename: "main.asm"}
+	instructions, err := p.Parse(strings.NewReader(input))
+	if _, ok := err.(Diagnostics); !ok {
+		t.Logf("expected Diagnostics but got %v", err)
+		t.FailNow()
+	}
+
+	expected := []Instruction{DEFLABEL{"main"}, JMP{LABEL{"nowhere"}}, DEFLABEL{"main"}}
+	if !reflect.DeepEqual(instructions, expected) {
+		t.Logf("expected the instructions %v but got %v", expected, instructions)
+		t.FailNow()
+	}
+
+	// the assembler finds the errors of the lines that did parse
+	a := Assembler{Positions: p.Positions()}
+	_, err = a.Process(0x0500, instructions)
+	if err == nil || err.Error() != "main.asm:3: error: JMP nowhere: Cannot find label: nowhere in label map\n"+
+		"main.asm:4: error: label 'main' already exists, all labels should be unique" {
+		t.Logf("expected the errors of lines 3 and 4 but got %v", err)
+		t.FailNow()
+	}
+}
+
+func TestParseWarnsAboutUnusedLabelsAndSymbols(t *testing.T) {
+	dir := writeAsmFiles(t, map[string]string{
+		"main.asm": "%USED = 0x1\n" +
+			"%UNUSED = %USED + 1\n" +
+			"main:\n" +
+			"\tDATA R0, %USED\n" +
+			"loop:\n" +
+			"\tCALL routine\n" +
+			"\tJMP loop\n" +
+			"unused:\n" +
+			"\tHALT\n" +
+			".include \"lib.asm\"",
+		"lib.asm": "%LIB-UNUSED = 0x2\nroutine:\n\tRET",
+	})
+	defer os.RemoveAll(dir)
+
+	main := filepath.Join(dir, "main.asm")
+	p := Parser{Filename: main}
+	if _, err := p.Parse(strings.NewReader(readAsmFile(t, main))); err != nil {
+		t.Logf("encountered error %v", err)
+		t.FailNow()
+	}
+
+	expected := []string{
+		main + ":2:1: warning: symbol %UNUSED is never used",
+		main + ":8:1: warning: label unused is never used",
+	}
+	warnings := p.Warnings()
+	if len(warnings) != len(expected) {
+		t.Logf("expected %d warnings but got %d:\n%v", len(expected), len(warnings), warnings)
+		t.FailNow()
+	}
+	for n, warning := range warnings {
+		if warning.String() != expected[n] {
+			t.Logf("expected the warning %q but got %q", expected[n], warning.String())
+			t.FailNow()
+		}
+	}
+}
+
+func writeAsmFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "asm")
+	if err != nil {
+		t.Logf("error creating directory: %v", err)
+		t.FailNow()
+	}
+
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Logf("error creating directory: %v", err)
+			t.FailNow()
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Logf("error writing %s: %v", name, err)
+			t.FailNow()
+		}
+	}
+	return dir
+}
+
+func readAsmFile(t *testing.T, filename string) string {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Logf("error reading %s: %v", filename, err)
+		t.FailNow()
+	}
+	return string(b)
+}
+
 func testParseInstructions(input string, expected []Instruction, t *testing.T) {
 	p := Parser{}
 
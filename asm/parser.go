@@ -4,20 +4,19 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 )
 
-var IS_DEFLABEL *regexp.Regexp = regexp.MustCompile("[A-Za-z0-9-]+:")
-var IS_DEFSYMBOL *regexp.Regexp = regexp.MustCompile(`%([A-Za-z0-9-]+)\s*=\s*((0x)?[0-9a-fA-F]+)`)
-var INSTRUCTION *regexp.Regexp = regexp.MustCompile(`(CALL)\s*([A-Za-z0-9-]+)|(DATA)\s*(R\d,\s*.+)|(CLF)|(JR)\s*(R\d)|(NOT)\s*(R\d)|(SHL)\s*(R\d)|(SHR)\s*(R\d)|(ADD)\s*(R\d,\s*R\d)|(CMP)\s*(R\d,\s*R\d)|(AND)\s*(R\d,\s*R\d)|(OR)\s*(R\d,\s*R\d)|(LD)\s*(R\d,\s*R\d)|(ST)\s*(R\d,\s*R\d)|(XOR)\s*(R\d,\s*R\d)|(OUT)\s*([A-Za-z]+,\s*R\d)|(IN)\s*([A-Za-z]+,\s*R\d)|(JMP[A-Z]+)\s*([A-Za-z0-9-]+)|(JMP)\s*([A-Za-z0-9-]+)`)
-var TWO_REGISTER_EXTRACTOR *regexp.Regexp = regexp.MustCompile(`R(\d),\s*R(\d)\s*`)
-var ONE_REGISTER_EXTRACTOR *regexp.Regexp = regexp.MustCompile(`R(\d)\s*`)
-var DATA_EXTRACTOR *regexp.Regexp = regexp.MustCompile(`R(\d),\s*((0x)?[0-9a-fA-F]+|(%)([A-Za-z0-9-]+))`)
-var IO_EXTRACTOR *regexp.Regexp = regexp.MustCompile(`(Addr|Data),\s*R(\d)`)
-var LABEL_EXTRACTOR *regexp.Regexp = regexp.MustCompile(`([A-Za-z0-9-]+)`)
+var IS_REGISTER *regexp.Regexp = regexp.MustCompile(`^R\d+$`)
 var FLAGS_EXTRACTOR *regexp.Regexp = regexp.MustCompile(`([CAEZ]+)`)
+var IS_NUMBER *regexp.Regexp = regexp.MustCompile(`^(0x[0-9a-fA-F]+|[0-9]+)$`)
+
+// how deep macros can call other macros, stops a macro that calls itself
+const MAX_MACRO_DEPTH = 16
 
 var REGISTERS map[string]REGISTER = map[string]REGISTER{
 	"0": REG0,
@@ -27,131 +26,592 @@ var REGISTERS map[string]REGISTER = map[string]REGISTER{
 }
 
 type Parser struct {
+	// the name of the file being parsed, used in source positions and to find the files it includes
+	Filename string
+	// the directories searched for an included file that isn't next to the file including it
+	IncludePaths []string
+
+	positions []SourcePosition
+	macros    map[string]*macro
+	// the number of macro calls expanded, used to make the labels of each expansion unique
+	expansions int
+	// the absolute paths of the files being parsed, used to find files that include themselves
+	including []string
+	// the symbols defined so far with a constant value
+	constants map[string]uint16
+
+	errors   Diagnostics
+	warnings Diagnostics
+	// the labels and symbols defined by the file being parsed and the ones used anywhere, to
+	// warn about the ones that are never used
+	definitions []definition
+	usedLabels  map[string]bool
+	usedSymbols map[string]bool
+	// set once there is an instruction that takes up space, the labels before it are where the program starts
+	started bool
+}
+
+// a macro defined between MACRO and ENDM, the body is kept as text and parsed every time the macro is called
+type macro struct {
+	name   string
+	params []string
+	body   []string
+	// the labels defined in the body, these are given a unique name in every expansion
+	labels map[string]bool
+}
+
+func newMacro(name string) *macro {
+	m := new(macro)
+	m.name = name
+	m.labels = make(map[string]bool)
+	return m
+}
+
+// a label or symbol defined by the file being parsed
+type definition struct {
+	name     string
+	symbol   bool
+	position SourcePosition
+	column   int
 }
 
+// Parse parses the whole input, a line with an error is skipped so that the errors of every line
+// are found. The error returned is Diagnostics if there is something wrong with the input, it comes
+// with the instructions of the lines that did parse so that they can be assembled to find the errors
+// the Assembler finds too
 func (p *Parser) Parse(input io.Reader) ([]Instruction, error) {
+	p.positions = []SourcePosition{}
+	p.macros = make(map[string]*macro)
+	p.expansions = 0
+	p.constants = make(map[string]uint16)
+	p.errors = Diagnostics{}
+	p.warnings = Diagnostics{}
+	p.definitions = []definition{}
+	p.usedLabels = make(map[string]bool)
+	p.usedSymbols = make(map[string]bool)
+	p.started = false
+	p.including = []string{}
+	if p.Filename != "" {
+		if path, err := filepath.Abs(p.Filename); err == nil {
+			p.including = append(p.including, path)
+		}
+	}
+
+	instructions := p.parse(input, p.filename(), []SourcePosition{})
+	if len(p.errors) > 0 {
+		return instructions, p.errors
+	}
+	p.warnUnused()
+	return instructions, nil
+}
+
+// Warnings returns the warnings of the last call to Parse, such as labels and symbols that are never used
+func (p *Parser) Warnings() Diagnostics {
+	return p.warnings
+}
+
+// parse parses the lines of a file, includedFrom are the .include lines that led to the file
+func (p *Parser) parse(input io.Reader, filename string, includedFrom []SourcePosition) []Instruction {
 	scanner := bufio.NewScanner(input)
 	instructions := []Instruction{}
+	lineNo := 0
 
+	var defining *macro
+	var definedAt SourcePosition
 	for scanner.Scan() {
+		lineNo++
 		line := scanner.Text()
-		line = strings.TrimSpace(line)
+		position := SourcePosition{filename, lineNo}
 
-		if line == "" {
+		tokens, err := tokenize(line)
+		if err != nil {
+			p.report(err, position, includedFrom)
 			continue
 		}
-
-		if IS_DEFLABEL.MatchString(line) {
-			instructions = append(instructions, processLabel(line))
-		} else if IS_DEFSYMBOL.MatchString(line) {
-			if ins, err := parseDefSymbol(line); err == nil {
-				instructions = append(instructions, ins)
-			} else {
-				return nil, err
+		if len(tokens) == 0 {
+			continue
+		}
+		first := tokens[0]
+
+		if defining != nil {
+			switch {
+			case first.is(NAME_TOKEN, "ENDM"):
+				err = newTokenReader(tokens[1:]).end("ENDM")
+				// a macro with a mistake in its MACRO line has no name and is dropped
+				if defining.name != "" {
+					p.macros[defining.name] = defining
+				}
+				defining = nil
+			case first.is(NAME_TOKEN, "MACRO"):
+				err = errorAt(first.column, "macro %s can't define another macro", defining.name)
+			case first.is(DIRECTIVE_TOKEN, ".include"):
+				err = errorAt(first.column, "macro %s can't include a file", defining.name)
+			default:
+				err = defining.addLine(line, tokens)
 			}
-		} else if INSTRUCTION.MatchString(line) {
-			if ins, err := parseInstruction(line); err == nil {
-				instructions = append(instructions, ins)
-			} else {
-				return nil, err
+			if err != nil {
+				p.report(err, position, includedFrom)
 			}
-		} else {
-			return nil, fmt.Errorf("unsupported/unparseable line: %s", line)
+			continue
 		}
 
+		switch {
+		case first.is(NAME_TOKEN, "MACRO"):
+			m, err := p.parseMacroDirective(tokens)
+			if err != nil {
+				p.report(err, position, includedFrom)
+				m = newMacro("")
+			}
+			defining = m
+			definedAt = position
+		case first.is(NAME_TOKEN, "ENDM"):
+			p.report(errorAt(first.column, "ENDM without MACRO"), position, includedFrom)
+		case first.is(DIRECTIVE_TOKEN, ".include"):
+			ins, err := p.include(tokens, position, includedFrom)
+			if err != nil {
+				p.report(err, position, includedFrom)
+			}
+			instructions = append(instructions, ins...)
+		default:
+			ins, err := p.parseLine(line, tokens, 0)
+			if err != nil {
+				p.report(err, position, includedFrom)
+				continue
+			}
+			if len(includedFrom) == 0 {
+				p.define(ins, position, first.column)
+			}
+			instructions = append(instructions, ins...)
+			for _, i := range ins {
+				p.positions = append(p.positions, position)
+				p.started = p.started || i.Size() > 0
+			}
+		}
 	}
 	if err := scanner.Err(); err != nil {
+		p.report(err, SourcePosition{filename, lineNo + 1}, includedFrom)
+	}
+	if defining != nil {
+		p.report(fmt.Errorf("macro %s has no ENDM", defining.name), definedAt, includedFrom)
+	}
+	return instructions
+}
+
+// report records an error about a line, at the column of a lineError
+func (p *Parser) report(err error, position SourcePosition, includedFrom []SourcePosition) {
+	column := 0
+	if e, ok := err.(*lineError); ok {
+		column = e.column
+	}
+	p.errors = append(p.errors, Diagnostic{position, column, err.Error(), false, includedFrom})
+}
+
+// include parses the file named by an .include line, the instructions of the file take the place of the line
+func (p *Parser) include(tokens []token, position SourcePosition, includedFrom []SourcePosition) ([]Instruction, error) {
+	r := newTokenReader(tokens[1:])
+	file := r.take()
+	if file.kind != STRING_TOKEN {
+		return nil, errorAt(file.column, ".include needs a file name in double quotes but got %s", describe(file))
+	}
+	if err := r.end("the file name"); err != nil {
 		return nil, err
 	}
+	name, err := strconv.Unquote(file.text)
+	if err != nil {
+		return nil, errorAt(file.column, "invalid file name %s", file.text)
+	}
+
+	path, err := p.findInclude(name, position.File)
+	if err != nil {
+		return nil, errorAt(file.column, "%v", err)
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, errorAt(file.column, "%v", err)
+	}
+	for n, including := range p.including {
+		if including == absPath {
+			cycle := strings.Join(p.including[n:], " -> ")
+			return nil, errorAt(file.column, "%s includes itself: %s -> %s", path, cycle, absPath)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errorAt(file.column, "%v", err)
+	}
+	defer f.Close()
+
+	p.including = append(p.including, absPath)
+	instructions := p.parse(f, path, append([]SourcePosition{position}, includedFrom...))
+	p.including = p.including[:len(p.including)-1]
 	return instructions, nil
 }
 
-func parseDefSymbol(line string) (Instruction, error) {
-	tokens := IS_DEFSYMBOL.FindStringSubmatch(line)
-	if len(tokens) != 4 {
-		return nil, fmt.Errorf("could not parse the arguments correctly out of DEFSYMBOL %s", line)
+// findInclude looks for an included file next to the file including it and then in the include paths
+func (p *Parser) findInclude(name string, from string) (string, error) {
+	if filepath.IsAbs(name) {
+		return name, nil
 	}
 
-	var value uint64
-	var err error
-	// parse in base 16 or 10
-	if tokens[3] == "0x" {
-		value, err = strconv.ParseUint(strings.Replace(tokens[2], "0x", "", -1), 16, 16)
-	} else {
-		value, err = strconv.ParseUint(tokens[2], 10, 16)
+	candidates := []string{filepath.Join(filepath.Dir(from), name)}
+	for _, includePath := range p.IncludePaths {
+		candidates = append(candidates, filepath.Join(includePath, name))
+	}
+
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("cannot find included file %s in %s", name, strings.Join(candidates, ", "))
+}
+
+// parseLine parses the tokens of a line into an instruction, or the instructions of a macro called by the
+// line. A label can be followed by something else on the same line
+func (p *Parser) parseLine(line string, tokens []token, depth int) ([]Instruction, error) {
+	first := tokens[0]
+	if first.kind == NAME_TOKEN && len(tokens) > 1 && tokens[1].is(PUNCTUATION_TOKEN, ":") {
+		label := DEFLABEL{first.text}
+		if len(tokens) == 2 {
+			return []Instruction{label}, nil
+		}
+		ins, err := p.parseLine(line, tokens[2:], depth)
+		if err != nil {
+			return nil, err
+		}
+		return append([]Instruction{label}, ins...), nil
 	}
 
+	if m, ok := p.macros[first.text]; ok && first.kind == NAME_TOKEN {
+		return p.expand(m, line, tokens, depth)
+	}
+
+	var ins Instruction
+	var err error
+	switch first.kind {
+	case SYMBOL_TOKEN:
+		ins, err = p.parseDefSymbol(tokens)
+	case DIRECTIVE_TOKEN:
+		if first.text == ".include" {
+			return nil, errorAt(first.column, ".include has to be on a line of its own")
+		}
+		ins, err = p.parseDirective(tokens)
+	case NAME_TOKEN:
+		ins, err = p.parseInstruction(tokens)
+	default:
+		return nil, errorAt(first.column, "expected an instruction, label, symbol, directive or macro but got '%s'", first.text)
+	}
 	if err != nil {
 		return nil, err
 	}
+	return []Instruction{ins}, nil
+}
+
+// expand parses the body of a macro called by line, the parameters are replaced by the arguments and
+// the labels of the body are given a name that is unique to this expansion. The instructions start
+// with a MACROCALL so the expansion can be seen in the output of the assembler
+func (p *Parser) expand(m *macro, line string, tokens []token, depth int) ([]Instruction, error) {
+	call := tokens[0]
+	if depth >= MAX_MACRO_DEPTH {
+		return nil, errorAt(call.column, "macro %s is nested more than %d deep", m.name, MAX_MACRO_DEPTH)
+	}
 
-	return DEFSYMBOL{tokens[1], uint16(value)}, nil
+	// each argument is kept as the text it is written as, it is parsed again in the body
+	arguments := []string{}
+	r := newTokenReader(tokens[1:])
+	for !r.done() {
+		if len(arguments) > 0 {
+			if err := r.punctuation(",", fmt.Sprintf("argument %s", arguments[len(arguments)-1])); err != nil {
+				return nil, err
+			}
+		}
+		start := r.peek()
+		if _, err := r.expression(); err != nil {
+			return nil, err
+		}
+		last := r.tokens[r.next-1]
+		arguments = append(arguments, line[start.column-1:last.column-1+len(last.text)])
+	}
+	if len(arguments) != len(m.params) {
+		return nil, errorAt(call.column, "macro %s takes %d arguments but got %d", m.name, len(m.params), len(arguments))
+	}
+
+	values := make(map[string]string)
+	for n, param := range m.params {
+		values[param] = arguments[n]
+	}
+
+	p.expansions++
+	suffix := fmt.Sprintf("-%s-%d", m.name, p.expansions)
+
+	instructions := []Instruction{MACROCALL{m.name, strings.Join(arguments, ", ")}}
+	for _, bodyLine := range m.body {
+		bodyTokens, _ := tokenize(bodyLine)
+		bodyLine = m.substitute(bodyLine, bodyTokens, values, suffix)
+		bodyTokens, err := tokenize(bodyLine)
+		if err == nil {
+			var ins []Instruction
+			if ins, err = p.parseLine(bodyLine, bodyTokens, depth+1); err == nil {
+				instructions = append(instructions, ins...)
+				continue
+			}
+		}
+		return nil, errorAt(call.column, "in macro %s: %v", m.name, err)
+	}
+	return instructions, nil
 }
 
-func processLabel(line string) DEFLABEL {
-	line = strings.Replace(line, ":", "", -1)
+// parseMacroDirective parses MACRO <name> <param>, <param>...
+func (p *Parser) parseMacroDirective(tokens []token) (*macro, error) {
+	r := newTokenReader(tokens[1:])
+	name := r.take()
+	if name.kind != NAME_TOKEN {
+		return nil, errorAt(name.column, "a macro needs a name made of letters, numbers and dashes but got %s", describe(name))
+	}
+	if name.text == "MACRO" || name.text == "ENDM" {
+		return nil, errorAt(name.column, "'%s' can't be the name of a macro", name.text)
+	}
+	if _, ok := p.macros[name.text]; ok {
+		return nil, errorAt(name.column, "macro '%s' already exists, all macros should be unique", name.text)
+	}
 
-	return DEFLABEL{line}
+	m := newMacro(name.text)
+	for !r.done() {
+		if len(m.params) > 0 {
+			if err := r.punctuation(",", fmt.Sprintf("parameter %s", m.params[len(m.params)-1])); err != nil {
+				return nil, err
+			}
+		}
+		param := r.take()
+		if param.kind != NAME_TOKEN {
+			return nil, errorAt(param.column, "invalid parameter %s for macro %s", describe(param), m.name)
+		}
+		if m.hasParam(param.text) {
+			return nil, errorAt(param.column, "parameter '%s' of macro %s is given twice", param.text, m.name)
+		}
+		m.params = append(m.params, param.text)
+	}
+	return m, nil
 }
 
-func stripEmptyGroups(input []string) []string {
-	result := []string{}
-	for _, t := range input {
-		if t != "" {
-			result = append(result, t)
+// addLine adds a line to the body, it is an error if the line uses a parameter the macro doesn't have
+func (m *macro) addLine(line string, tokens []token) error {
+	for _, t := range tokens {
+		if t.kind == PARAMETER_TOKEN && !m.hasParam(t.text[1:]) {
+			return errorAt(t.column, "unknown parameter '%s' in macro %s", t.text, m.name)
 		}
 	}
+	if len(tokens) > 1 && tokens[0].kind == NAME_TOKEN && tokens[1].is(PUNCTUATION_TOKEN, ":") {
+		m.labels[tokens[0].text] = true
+	}
+	m.body = append(m.body, line)
+	return nil
+}
+
+func (m *macro) hasParam(name string) bool {
+	for _, param := range m.params {
+		if param == name {
+			return true
+		}
+	}
+	return false
+}
+
+// substitute replaces the parameters in a line of the body with their values and adds the suffix to
+// the labels of the body, the comment at the end of the line is dropped
+func (m *macro) substitute(line string, tokens []token, values map[string]string, suffix string) string {
+	result := ""
+	end := 0
+	for _, t := range tokens {
+		start := t.column - 1
+		result += line[end:start]
+		switch {
+		case t.kind == PARAMETER_TOKEN:
+			result += values[t.text[1:]]
+		case t.kind == NAME_TOKEN && m.labels[t.text]:
+			result += t.text + suffix
+		default:
+			result += t.text
+		}
+		end = start + len(t.text)
+	}
 	return result
 }
 
-func parseInstruction(line string) (Instruction, error) {
-	tokens := INSTRUCTION.FindStringSubmatch(line)
-	tokens = stripEmptyGroups(tokens)
-	instructionName := tokens[1]
+// define keeps the label or symbol defined by a line, the labels of macro expansions and the labels
+// where the program starts aren't kept
+func (p *Parser) define(instructions []Instruction, position SourcePosition, column int) {
+	if len(instructions) == 0 {
+		return
+	}
+	switch ins := instructions[0].(type) {
+	case DEFLABEL:
+		if !p.started {
+			return
+		}
+		p.definitions = append(p.definitions, definition{ins.Name, false, position, column})
+	case DEFSYMBOL:
+		p.definitions = append(p.definitions, definition{ins.Name, true, position, column})
+	case DEFEXPRESSION:
+		p.definitions = append(p.definitions, definition{ins.Name, true, position, column})
+	}
+}
 
-	var operands string
-	if len(tokens) > 2 {
-		operands = tokens[2]
+// use keeps the labels and symbols used by a value
+func (p *Parser) use(m marker) {
+	switch v := m.(type) {
+	case LABEL:
+		p.usedLabels[v.Name] = true
+	case SYMBOL:
+		p.usedSymbols[v.Name] = true
+	case EXPRESSION:
+		if v.Left != nil {
+			p.use(v.Left)
+		}
+		p.use(v.Right)
 	}
+}
+
+// warnUnused warns about the labels and symbols defined by the file being parsed that are never used,
+// the ones of included files aren't warned about as a file can include more than it needs
+func (p *Parser) warnUnused() {
+	for _, d := range p.definitions {
+		switch {
+		case d.symbol && !p.usedSymbols[d.name]:
+			p.warnings = append(p.warnings, Diagnostic{d.position, d.column, fmt.Sprintf("symbol %%%s is never used", d.name), true, nil})
+		case !d.symbol && !p.usedLabels[d.name]:
+			p.warnings = append(p.warnings, Diagnostic{d.position, d.column, fmt.Sprintf("label %s is never used", d.name), true, nil})
+		}
+	}
+}
+
+// value reads an expression and keeps the labels and symbols it uses
+func (p *Parser) value(r *tokenReader) (marker, error) {
+	m, err := r.expression()
+	if err != nil {
+		return nil, err
+	}
+	p.use(m)
+	return m, nil
+}
+
+// Positions returns the source position of each instruction returned by the last call to Parse
+func (p *Parser) Positions() []SourcePosition {
+	return p.positions
+}
+
+func (p *Parser) filename() string {
+	if p.Filename == "" {
+		return "<input>"
+	}
+	return p.Filename
+}
+
+// parseDefSymbol parses %<name> = <value>
+func (p *Parser) parseDefSymbol(tokens []token) (Instruction, error) {
+	name := tokens[0].text[1:]
+	r := newTokenReader(tokens[1:])
+	if err := r.punctuation("=", fmt.Sprintf("symbol %%%s", name)); err != nil {
+		return nil, err
+	}
+	start := r.peek()
+	expression, err := p.value(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.end(fmt.Sprintf("the value of %%%s", name)); err != nil {
+		return nil, err
+	}
+
+	// the value of a symbol that uses labels or symbols that aren't constants is worked out by the assembler
+	if !isConstant(expression, p.constants) {
+		return DEFEXPRESSION{name, expression}, nil
+	}
+	value, err := constantValue(expression, p.constants)
+	if err != nil {
+		return nil, errorAt(start.column, "%v", err)
+	}
+	p.constants[name] = value
+	return DEFSYMBOL{name, value}, nil
+}
+
+// parseInstruction parses an instruction and its operands, it is an error if anything comes after the operands
+func (p *Parser) parseInstruction(tokens []token) (Instruction, error) {
+	name := tokens[0]
+	r := newTokenReader(tokens[1:])
 
 	var instruction Instruction
 	var err error
-	switch instructionName {
-	case "ADD", "AND", "XOR", "OR", "CMP", "LD", "ST":
-		instruction, err = parseTwoRegisterInstruction(instructionName, operands)
-	case "SHR", "SHL", "NOT", "JR":
-		instruction, err = parseOneRegisterInstruction(instructionName, operands)
+	switch name.text {
+	case "ADD", "SUB", "MOV", "AND", "XOR", "OR", "CMP", "LD", "ST":
+		instruction, err = parseTwoRegisterInstruction(name.text, r)
+	case "SHR", "SHL", "NOT", "JR", "PUSH", "POP", "INC", "DEC":
+		instruction, err = parseOneRegisterInstruction(name.text, r)
 	case "DATA":
-		instruction, err = parseDataInstruction(operands)
+		instruction, err = p.parseDataInstruction(r)
 	case "CLF":
 		instruction = CLF{}
+	case "RET":
+		instruction = RET{}
+	case "EI":
+		instruction = EI{}
+	case "DI":
+		instruction = DI{}
+	case "IRET":
+		instruction = IRET{}
+	case "HALT":
+		instruction = HALT{}
 	case "OUT", "IN":
-		instruction, err = parseIOInstruction(instructionName, operands)
+		instruction, err = parseIOInstruction(name.text, r)
 	case "CALL", "JMP", "JMPZ", "JMPE", "JMPEZ", "JMPA", "JMPAZ", "JMPAE", "JMPAEZ", "JMPC", "JMPCZ", "JMPCE", "JMPCEZ", "JMPCA", "JMPCAZ", "JMPCAE", "JMPCAEZ":
-		instruction, err = parseLabelledJump(instructionName, operands)
+		instruction, err = p.parseLabelledJump(name.text, r)
 	default:
-		return nil, fmt.Errorf("unknown instruction name '%s'", instructionName)
+		return nil, errorAt(name.column, "unknown instruction '%s'", name.text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := r.end(instruction.String()); err != nil {
+		return nil, err
+	}
+	return instruction, nil
+}
+
+// parseRegister reads one of the registers R0 - R3, any other such as R7 is an error as it can't be
+// put in an instruction
+func parseRegister(name string, r *tokenReader) (REGISTER, error) {
+	t := r.take()
+	if t.kind != NAME_TOKEN || !IS_REGISTER.MatchString(t.text) {
+		return REG0, errorAt(t.column, "%s needs a register but got %s", name, describe(t))
+	}
+	register, ok := REGISTERS[t.text[1:]]
+	if !ok {
+		return REG0, errorAt(t.column, "unknown register %s, the registers are R0 - R3", t.text)
 	}
-	return instruction, err
+	return register, nil
 }
 
-func parseLabelledJump(name string, operands string) (Instruction, error) {
-	arguments := LABEL_EXTRACTOR.FindStringSubmatch(operands)
-	if len(arguments) != 2 {
-		return nil, fmt.Errorf("Instruction %s is not valid", name)
+func (p *Parser) parseLabelledJump(name string, r *tokenReader) (Instruction, error) {
+	t := r.take()
+	if t.kind != NAME_TOKEN {
+		return nil, errorAt(t.column, "%s needs a label but got %s", name, describe(t))
 	}
+	label := LABEL{t.text}
+	p.use(label)
 
 	switch name {
 	case "JMP":
-		return JMP{LABEL{arguments[1]}}, nil
+		return JMP{label}, nil
 	case "CALL":
-		return CALL{LABEL{arguments[1]}}, nil
+		return CALL{label}, nil
 	case "JMPZ", "JMPE", "JMPEZ", "JMPA", "JMPAZ", "JMPAE", "JMPAEZ", "JMPC", "JMPCZ", "JMPCE", "JMPCEZ", "JMPCA", "JMPCAZ", "JMPCAE", "JMPCAEZ":
 		flags, err := extractFlagsFrom(name)
 		if err != nil {
 			return nil, err
 		}
-		return JMPF{flags, LABEL{arguments[1]}}, nil
+		return JMPF{flags, label}, nil
 	default:
 		return nil, fmt.Errorf("Unsupported labelled jump instruction %s", name)
 	}
@@ -166,27 +626,25 @@ func extractFlagsFrom(name string) ([]string, error) {
 	return strings.Split(tokens[1], ""), nil
 }
 
-func parseIOInstruction(name string, operands string) (Instruction, error) {
-	arguments := IO_EXTRACTOR.FindStringSubmatch(operands)
-	if len(arguments) != 3 {
-		return nil, fmt.Errorf("Instruction %s is not valid", name)
-	}
+func parseIOInstruction(name string, r *tokenReader) (Instruction, error) {
+	mode := r.take()
 
 	var iomode IO_MODE
-	switch arguments[1] {
-	case "Data":
+	switch {
+	case mode.is(NAME_TOKEN, "Data"):
 		iomode = DATA_MODE
-	case "Addr":
+	case mode.is(NAME_TOKEN, "Addr"):
 		iomode = ADDRESS_MODE
 	default:
-		return nil, fmt.Errorf("Unsupported IO mode %s for instruction %s (must be Addr|Data)", arguments[1], name)
+		return nil, errorAt(mode.column, "%s needs the IO mode Addr or Data but got %s", name, describe(mode))
 	}
 
-	var register REGISTER
-	if v, ok := REGISTERS[arguments[2]]; !ok {
-		return nil, fmt.Errorf("Unknown register %s for instruction %s", arguments[2], name)
-	} else {
-		register = v
+	if err := r.punctuation(",", fmt.Sprintf("%s %s", name, mode.text)); err != nil {
+		return nil, err
+	}
+	register, err := parseRegister(name, r)
+	if err != nil {
+		return nil, err
 	}
 
 	switch name {
@@ -199,30 +657,26 @@ func parseIOInstruction(name string, operands string) (Instruction, error) {
 	}
 }
 
-func parseTwoRegisterInstruction(name string, operands string) (Instruction, error) {
-	registers := TWO_REGISTER_EXTRACTOR.FindStringSubmatch(operands)
-
-	if len(registers) != 3 {
-		return nil, fmt.Errorf("Instruction %s only supports two command separated registers", name)
+func parseTwoRegisterInstruction(name string, r *tokenReader) (Instruction, error) {
+	register1, err := parseRegister(name, r)
+	if err != nil {
+		return nil, err
 	}
-
-	var register1 REGISTER
-	if v, ok := REGISTERS[registers[1]]; !ok {
-		return nil, fmt.Errorf("Unknown register %s for instruction %s", registers[1], name)
-	} else {
-		register1 = v
+	if err := r.punctuation(",", fmt.Sprintf("%s R%d", name, register1)); err != nil {
+		return nil, err
 	}
-
-	var register2 REGISTER
-	if v, ok := REGISTERS[registers[2]]; !ok {
-		return nil, fmt.Errorf("Unknown register %s for instruction %s", registers[2], name)
-	} else {
-		register2 = v
+	register2, err := parseRegister(name, r)
+	if err != nil {
+		return nil, err
 	}
 
 	switch name {
 	case "ADD":
 		return ADD{register1, register2}, nil
+	case "SUB":
+		return SUB{register1, register2}, nil
+	case "MOV":
+		return MOV{register1, register2}, nil
 	case "AND":
 		return AND{register1, register2}, nil
 	case "XOR":
@@ -240,18 +694,10 @@ func parseTwoRegisterInstruction(name string, operands string) (Instruction, err
 	}
 }
 
-func parseOneRegisterInstruction(name string, operands string) (Instruction, error) {
-	registers := ONE_REGISTER_EXTRACTOR.FindStringSubmatch(operands)
-
-	if len(registers) != 2 {
-		return nil, fmt.Errorf("Instruction %s only supports one register", name)
-	}
-
-	var register1 REGISTER
-	if v, ok := REGISTERS[registers[1]]; !ok {
-		return nil, fmt.Errorf("Unknown register %s for instruction %s", registers[1], name)
-	} else {
-		register1 = v
+func parseOneRegisterInstruction(name string, r *tokenReader) (Instruction, error) {
+	register1, err := parseRegister(name, r)
+	if err != nil {
+		return nil, err
 	}
 
 	switch name {
@@ -263,72 +709,110 @@ func parseOneRegisterInstruction(name string, operands string) (Instruction, err
 		return NOT{register1}, nil
 	case "JR":
 		return JR{register1}, nil
+	case "PUSH":
+		return PUSH{register1}, nil
+	case "POP":
+		return POP{register1}, nil
+	case "INC":
+		return INC{register1}, nil
+	case "DEC":
+		return DEC{register1}, nil
 	default:
 		return nil, fmt.Errorf("unknown/unsupported instruction %s", name)
 	}
 }
 
-/*
-func parseDataInstruction(operands string) (Instruction, error) {
-	arguments := DATA_EXTRACTOR.FindStringSubmatch(operands)
-	if len(arguments) != 4 {
-		return nil, fmt.Errorf("could not parse the arguments correctly out of DATA %s", operands)
-	}
-
-	var register REGISTER
-	if v, ok := REGISTERS[arguments[1]]; !ok {
-		return nil, fmt.Errorf("Unknown register %s for DATA instruction", arguments[1])
-	} else {
-		register = v
+func (p *Parser) parseDataInstruction(r *tokenReader) (Instruction, error) {
+	register, err := parseRegister("DATA", r)
+	if err != nil {
+		return nil, err
 	}
-
-	var value uint64
-	var err error
-	// parse in base 16 or 10
-	if arguments[3] == "0x" {
-		value, err = strconv.ParseUint(strings.Replace(arguments[2], "0x", "", -1), 16, 16)
-	} else {
-		value, err = strconv.ParseUint(arguments[2], 10, 16)
+	if err := r.punctuation(",", fmt.Sprintf("DATA R%d", register)); err != nil {
+		return nil, err
 	}
 
+	value, err := p.value(r)
 	if err != nil {
 		return nil, err
 	}
-
-	return DATA{register, uint16(value)}, nil
+	return DATA{register, value}, nil
 }
-*/
 
-func parseDataInstruction(operands string) (Instruction, error) {
-	arguments := DATA_EXTRACTOR.FindStringSubmatch(operands)
-	if len(arguments) != 6 {
-		return nil, fmt.Errorf("could not parse the arguments correctly out of DATA %s", operands)
-	}
+// parseDirective parses the data directives
+//
+//	.word <value>, <value>...    the values, each an expression
+//	.string "<text>"             the characters of text followed by 0
+//	.pstring "<text>"            the number of characters of text followed by the characters
+//	.fill <count>, <value>       count words of value, value is 0 if it is left out
+//	.org <address>               0 words up to address, the next instruction is at address
+//
+// the count of .fill and the address of .org can't use labels, or symbols that aren't defined before with a constant
+func (p *Parser) parseDirective(tokens []token) (Instruction, error) {
+	directive := tokens[0]
+	name := directive.text
+	r := newTokenReader(tokens[1:])
 
-	var register REGISTER
-	if v, ok := REGISTERS[arguments[1]]; !ok {
-		return nil, fmt.Errorf("Unknown register %s for DATA instruction", arguments[1])
-	} else {
-		register = v
+	switch name {
+	case ".string", ".pstring":
+		t := r.take()
+		if t.kind != STRING_TOKEN {
+			return nil, errorAt(t.column, "%s needs a string in double quotes but got %s", name, describe(t))
+		}
+		text, err := strconv.Unquote(t.text)
+		if err != nil {
+			return nil, errorAt(t.column, "invalid string %s", t.text)
+		}
+		if err := r.end(fmt.Sprintf("the string of %s", name)); err != nil {
+			return nil, err
+		}
+		return STRING{text, name == ".pstring"}, nil
+	case ".word", ".fill", ".org":
+	default:
+		return nil, errorAt(directive.column, "unknown directive '%s'", name)
 	}
 
-	if arguments[4] == "%" {
-		return DATA{register, SYMBOL{arguments[5]}}, nil
-	} else {
-		var value uint64
-		var err error
-		// parse in base 16 or 10
-		if arguments[3] == "0x" {
-			value, err = strconv.ParseUint(strings.Replace(arguments[2], "0x", "", -1), 16, 16)
-		} else {
-			value, err = strconv.ParseUint(arguments[2], 10, 16)
+	values := []marker{}
+	columns := []int{}
+	for !r.done() {
+		if len(values) > 0 {
+			if err := r.punctuation(",", markerToString(values[len(values)-1])); err != nil {
+				return nil, err
+			}
 		}
-
+		columns = append(columns, r.peek().column)
+		value, err := p.value(r)
 		if err != nil {
 			return nil, err
 		}
-
-		return DATA{register, NUMBER{uint16(value)}}, nil
+		values = append(values, value)
 	}
 
+	switch name {
+	case ".word":
+		if len(values) == 0 {
+			return nil, errorAt(directive.column, ".word needs at least one value")
+		}
+		return WORDS{values}, nil
+	case ".fill":
+		if len(values) == 1 {
+			values = append(values, NUMBER{0x0000})
+		}
+		if len(values) != 2 {
+			return nil, errorAt(directive.column, ".fill needs a count and a value")
+		}
+		count, err := constantValue(values[0], p.constants)
+		if err != nil {
+			return nil, errorAt(columns[0], ".fill count: %v", err)
+		}
+		return FILL{int(count), values[1]}, nil
+	default:
+		if len(values) != 1 {
+			return nil, errorAt(directive.column, ".org needs an address")
+		}
+		address, err := constantValue(values[0], p.constants)
+		if err != nil {
+			return nil, errorAt(columns[0], ".org address: %v", err)
+		}
+		return ORG{address, 0}, nil
+	}
 }
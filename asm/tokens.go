@@ -0,0 +1,193 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// the kinds of token a line of assembly is made of
+type tokenKind int
+
+const (
+	// an instruction, register, label, macro or IO mode
+	NAME_TOKEN tokenKind = iota
+	NUMBER_TOKEN
+	// a character in single quotes such as 'A'
+	CHARACTER_TOKEN
+	// a string in double quotes such as "Hello\n"
+	STRING_TOKEN
+	// %name
+	SYMBOL_TOKEN
+	// \name, a parameter in the body of a macro
+	PARAMETER_TOKEN
+	// .name
+	DIRECTIVE_TOKEN
+	// one of , : = ( ) + - * / << >> & | ^ ~
+	PUNCTUATION_TOKEN
+	// the end of the line, the token read once all the others have been read
+	END_TOKEN
+)
+
+// the punctuation tokens, the ones of two characters come first so they are tried before the ones of one
+var PUNCTUATION = []string{"<<", ">>", ",", ":", "=", "(", ")", "+", "-", "*", "/", "&", "|", "^", "~"}
+
+// a token of a line, the column of its first character counts from 1
+type token struct {
+	kind   tokenKind
+	text   string
+	column int
+}
+
+func (t token) is(kind tokenKind, text string) bool {
+	return t.kind == kind && t.text == text
+}
+
+// lineError is an error at a column of a line
+type lineError struct {
+	column  int
+	message string
+}
+
+func (e *lineError) Error() string {
+	return e.message
+}
+
+func errorAt(column int, format string, a ...interface{}) error {
+	return &lineError{column, fmt.Sprintf(format, a...)}
+}
+
+// tokenize splits a line into tokens, a comment starting with ; or // ends the line. A name takes
+// in every dash that follows it, so a minus after a name needs a space in front of it
+func tokenize(line string) ([]token, error) {
+	tokens := []token{}
+	for n := 0; n < len(line); {
+		c := line[n]
+		column := n + 1
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			n++
+		case c == ';' || strings.HasPrefix(line[n:], "//"):
+			return tokens, nil
+		case c == '\'' || c == '"':
+			end := n + 1
+			for end < len(line) && line[end] != c {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, errorAt(column, "missing %c at the end of %s", c, line[n:])
+			}
+			kind := CHARACTER_TOKEN
+			if c == '"' {
+				kind = STRING_TOKEN
+			}
+			tokens = append(tokens, token{kind, line[n : end+1], column})
+			n = end + 1
+		case c == '%' || c == '\\' || c == '.' || isNameCharacter(c) && c != '-':
+			end := n + 1
+			for end < len(line) && isNameCharacter(line[end]) {
+				end++
+			}
+			text := line[n:end]
+			kind := NAME_TOKEN
+			switch {
+			case c == '%':
+				kind = SYMBOL_TOKEN
+			case c == '\\':
+				kind = PARAMETER_TOKEN
+			case c == '.':
+				kind = DIRECTIVE_TOKEN
+			case IS_NUMBER.MatchString(text):
+				kind = NUMBER_TOKEN
+			}
+			if kind != NAME_TOKEN && kind != NUMBER_TOKEN && len(text) == 1 {
+				return nil, errorAt(column, "'%c' needs a name after it", c)
+			}
+			tokens = append(tokens, token{kind, text, column})
+			n = end
+		default:
+			found := false
+			for _, p := range PUNCTUATION {
+				if strings.HasPrefix(line[n:], p) {
+					tokens = append(tokens, token{PUNCTUATION_TOKEN, p, column})
+					n += len(p)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, errorAt(column, "unexpected '%c'", c)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+func isNameCharacter(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-'
+}
+
+// tokenReader reads the tokens of a line one at a time
+type tokenReader struct {
+	tokens []token
+	next   int
+}
+
+func newTokenReader(tokens []token) *tokenReader {
+	r := new(tokenReader)
+	r.tokens = tokens
+	return r
+}
+
+// peek returns the next token without reading it, at the end of the line it is an END_TOKEN at the
+// column after the last token
+func (r *tokenReader) peek() token {
+	if r.next < len(r.tokens) {
+		return r.tokens[r.next]
+	}
+	if len(r.tokens) == 0 {
+		return token{END_TOKEN, "", 1}
+	}
+	last := r.tokens[len(r.tokens)-1]
+	return token{END_TOKEN, "", last.column + len(last.text)}
+}
+
+func (r *tokenReader) take() token {
+	t := r.peek()
+	if r.next < len(r.tokens) {
+		r.next++
+	}
+	return t
+}
+
+func (r *tokenReader) done() bool {
+	return r.next >= len(r.tokens)
+}
+
+// punctuation reads the punctuation p, it is an error if the next token is something else
+func (r *tokenReader) punctuation(p string, after string) error {
+	t := r.peek()
+	if !t.is(PUNCTUATION_TOKEN, p) {
+		return errorAt(t.column, "expected '%s' after %s but got %s", p, after, describe(t))
+	}
+	r.next++
+	return nil
+}
+
+// end is an error if there are tokens left after what has been read
+func (r *tokenReader) end(after string) error {
+	if t := r.peek(); t.kind != END_TOKEN {
+		return errorAt(t.column, "unexpected '%s' after %s", t.text, after)
+	}
+	return nil
+}
+
+// describe quotes the text of a token or says it is the end of the line
+func describe(t token) string {
+	if t.kind == END_TOKEN {
+		return "the end of the line"
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
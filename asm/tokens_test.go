@@ -0,0 +1,60 @@
+package asm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	expected := map[string][]token{
+		"\tADD R0, R1": {
+			{NAME_TOKEN, "ADD", 2}, {NAME_TOKEN, "R0", 6}, {PUNCTUATION_TOKEN, ",", 8}, {NAME_TOKEN, "R1", 10},
+		},
+		"loop: JMP end-of-loop ; a comment": {
+			{NAME_TOKEN, "loop", 1}, {PUNCTUATION_TOKEN, ":", 5}, {NAME_TOKEN, "JMP", 7}, {NAME_TOKEN, "end-of-loop", 11},
+		},
+		"%SIZE = (0x10<<2) - 'A' // a comment": {
+			{SYMBOL_TOKEN, "%SIZE", 1}, {PUNCTUATION_TOKEN, "=", 7}, {PUNCTUATION_TOKEN, "(", 9}, {NUMBER_TOKEN, "0x10", 10},
+			{PUNCTUATION_TOKEN, "<<", 14}, {NUMBER_TOKEN, "2", 16}, {PUNCTUATION_TOKEN, ")", 17}, {PUNCTUATION_TOKEN, "-", 19},
+			{CHARACTER_TOKEN, "'A'", 21},
+		},
+		`.string "a \"; b"`: {
+			{DIRECTIVE_TOKEN, ".string", 1}, {STRING_TOKEN, `"a \"; b"`, 9},
+		},
+		"DATA \\reg, ';'": {
+			{NAME_TOKEN, "DATA", 1}, {PARAMETER_TOKEN, "\\reg", 6}, {PUNCTUATION_TOKEN, ",", 10}, {CHARACTER_TOKEN, "';'", 12},
+		},
+		"  ; only a comment": {},
+	}
+
+	for line, tokens := range expected {
+		actual, err := tokenize(line)
+		if err != nil {
+			t.Logf("%s: error tokenizing: %v", line, err)
+			t.FailNow()
+		}
+		if !reflect.DeepEqual(actual, tokens) {
+			t.Logf("%s: expected %v but got %v", line, tokens, actual)
+			t.FailNow()
+		}
+	}
+}
+
+func TestTokenizeErrors(t *testing.T) {
+	expected := map[string]int{
+		"DATA R0, 'A":      10,
+		".string \"Hello":  9,
+		"ADD R0, R1 $":     12,
+		"DATA R0, % SIZE":  10,
+		"\tCALL ROUTINE#1": 14,
+	}
+
+	for line, column := range expected {
+		_, err := tokenize(line)
+		e, ok := err.(*lineError)
+		if !ok || e.column != column {
+			t.Logf("%s: expected an error at column %d but got %v", line, column, err)
+			t.FailNow()
+		}
+	}
+}
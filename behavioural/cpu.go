@@ -0,0 +1,565 @@
+package behavioural
+
+import (
+	"fmt"
+
+	"github.com/djhworld/simple-computer/components"
+	"github.com/djhworld/simple-computer/cpu"
+	"github.com/djhworld/simple-computer/io"
+	"github.com/djhworld/simple-computer/utils"
+)
+
+// the ALU operations in the order of their op code (bits 9 - 11 of the instruction)
+const (
+	ADD = iota
+	SHR
+	SHL
+	NOT
+	AND
+	OR
+	XOR
+	CMP
+)
+
+// the instructions decoded from bits 9 - 11 when the instruction is not an ALU or extended instruction
+const (
+	LOAD = iota
+	STORE
+	DATA
+	JR
+	JMP
+	JMPF
+	CLF
+	IO
+)
+
+// the extended instructions (0x01X0) decoded from bits 8 - 11
+const (
+	PUSH = iota
+	POP
+	CALL
+	RET
+	EI
+	DI
+	IRET
+	SUB
+	MOV
+	INC
+	DEC
+	HALT
+)
+
+const MEMORY_SIZE = 0x10000
+
+type flags struct {
+	carry   bool
+	aLarger bool
+	equal   bool
+	zero    bool
+}
+
+// CPU runs the instruction set of cpu.CPU (see the header of cpu.go) an instruction at a time
+// instead of simulating its gates. It takes the same number of steps as the gate level CPU,
+// the whole instruction runs at step 1 and the remaining steps only keep the timing, so at the
+// start of every instruction the registers, flags and memory are the same as they would be
+// in the gate level CPU. Peripherals see the same IO bus and main bus signals
+type CPU struct {
+	registers [4]uint16
+	iar       uint16
+	ir        uint16
+	sp        uint16
+	mar       uint16
+	flags     flags
+
+	savedIAR          uint16
+	savedFlags        flags
+	interruptsEnabled bool
+
+	memory []uint16
+	// called with the address of every memory write an instruction makes, see WatchWrites
+	watchWrites func(address uint16)
+
+	// the step of the stepper that runs next (1 - 6) and what the current and next rounds are for
+	stepperPosition int
+	extended        bool
+	extendNext      bool
+	interrupting    bool
+	interruptNext   bool
+	// set at step 4 of HALT, the stepper does not move after that
+	halted bool
+
+	mainBus     *components.Bus
+	ioBus       *components.IOBus
+	peripherals []io.Peripheral
+	clocked     []io.Clocked
+}
+
+// like the latches of the gate level CPU and memory, the registers and memory power up with all bits on
+const POWER_UP_VALUE = 0xFFFF
+
+func NewCPU(mainBus *components.Bus) *CPU {
+	c := new(CPU)
+	c.registers = [4]uint16{POWER_UP_VALUE, POWER_UP_VALUE, POWER_UP_VALUE, POWER_UP_VALUE}
+	c.iar = POWER_UP_VALUE
+	c.sp = POWER_UP_VALUE
+	c.mar = POWER_UP_VALUE
+
+	c.memory = make([]uint16, MEMORY_SIZE)
+	for i := range c.memory {
+		c.memory[i] = POWER_UP_VALUE
+	}
+	c.stepperPosition = 1
+	c.mainBus = mainBus
+	c.ioBus = components.NewIOBus()
+	c.peripherals = make([]io.Peripheral, 0)
+	return c
+}
+
+func (c *CPU) ConnectPeripheral(p io.Peripheral) {
+	p.Connect(c.ioBus, c.mainBus)
+	c.peripherals = append(c.peripherals, p)
+	if clocked, ok := p.(io.Clocked); ok {
+		c.clocked = append(c.clocked, clocked)
+	}
+}
+
+// Step runs one step of the stepper
+func (c *CPU) Step() {
+	if c.halted {
+		return
+	}
+
+	switch c.stepperPosition {
+	case 1:
+		c.tickPeripherals()
+		c.startRound()
+	case 4:
+		c.halted = !c.extended && !c.interrupting && c.isExtended(HALT)
+	case cpu.STEPS_PER_ROUND:
+		c.endRound()
+	}
+
+	c.stepperPosition = c.stepperPosition%cpu.STEPS_PER_ROUND + 1
+}
+
+// startRound works out what the round of the stepper is for in the same way as the extended
+// and interrupting bits of the gate level CPU, then runs it
+func (c *CPU) startRound() {
+	c.extended = c.extendNext
+	c.interrupting = c.interruptNext
+
+	switch {
+	case c.interrupting:
+		c.enterInterrupt()
+	case c.extended:
+		// the instruction has already been run in full by the first round
+	default:
+		c.fetch()
+		c.execute()
+	}
+}
+
+// endRound decides if the next round is an extended round or an interrupt entry
+func (c *CPU) endRound() {
+	c.updatePeripherals()
+
+	c.extendNext = !c.extended && !c.interrupting && c.isExtended(CALL)
+	c.interruptNext = c.interruptsEnabled && c.ioBus.IsInterruptRequested() && !c.extendNext && !c.interrupting
+}
+
+func (c *CPU) fetch() {
+	c.mar = c.iar
+	c.ir = c.memory[c.iar]
+	c.iar++
+}
+
+func (c *CPU) execute() {
+	// bits 0 - 6 of the instruction are not wired to anything
+	a := (c.ir >> 2) & 0x3
+	b := c.ir & 0x3
+
+	switch {
+	case c.ir&0x0180 == 0x0080:
+		c.runALU(int(c.ir>>4)&0x7, a, b)
+	case c.ir&0x0100 == 0:
+		c.runInstruction(int(c.ir>>4)&0x7, a, b)
+	default:
+		c.runExtendedInstruction(int(c.ir>>4)&0xF, a, b)
+	}
+}
+
+func (c *CPU) isExtended(instruction int) bool {
+	return c.ir&0x0100 != 0 && int(c.ir>>4)&0xF == instruction
+}
+
+func (c *CPU) runInstruction(instruction int, a, b uint16) {
+	switch instruction {
+	case LOAD:
+		c.mar = c.registers[a]
+		c.registers[b] = c.memory[c.mar]
+	case STORE:
+		c.mar = c.registers[a]
+		c.writeMemory(c.mar, c.registers[b])
+	case DATA:
+		c.mar = c.iar
+		c.registers[b] = c.memory[c.mar]
+		c.iar++
+	case JR:
+		c.iar = c.registers[b]
+	case JMP:
+		c.mar = c.iar
+		c.iar = c.memory[c.mar]
+	case JMPF:
+		c.mar = c.iar
+		if c.jumpConditionMet() {
+			c.iar = c.memory[c.mar]
+		} else {
+			c.iar++
+		}
+	case CLF:
+		c.flags = flags{}
+	case IO:
+		c.runIO(b)
+	}
+}
+
+// jumpConditionMet is true when any of the flags selected by bits 12 - 15 of a JMPF is on
+func (c *CPU) jumpConditionMet() bool {
+	return (c.ir&0x8 != 0 && c.flags.carry) ||
+		(c.ir&0x4 != 0 && c.flags.aLarger) ||
+		(c.ir&0x2 != 0 && c.flags.equal) ||
+		(c.ir&0x1 != 0 && c.flags.zero)
+}
+
+// runIO drives the IO bus the same way the gate level CPU does: OUT sets it while register B
+// is on the main bus, IN enables it and register B takes whatever the peripherals put on the main bus
+func (c *CPU) runIO(b uint16) {
+	output := c.ir&0x8 != 0
+	c.ioBus.Update(output, c.ir&0x4 != 0)
+
+	if output {
+		c.mainBus.SetValue(c.registers[b])
+		c.ioBus.Set()
+		c.updatePeripherals()
+		c.ioBus.Unset()
+		c.updatePeripherals()
+	} else {
+		c.ioBus.Enable()
+		c.updatePeripherals()
+		c.ioBus.Disable()
+		c.updatePeripherals()
+		c.registers[b] = c.mainBus.Value()
+	}
+
+	c.mainBus.SetValue(0x0000)
+}
+
+func (c *CPU) runExtendedInstruction(instruction int, a, b uint16) {
+	switch instruction {
+	case PUSH:
+		c.sp--
+		c.mar = c.sp
+		c.writeMemory(c.mar, c.registers[b])
+	case POP:
+		c.mar = c.sp
+		c.registers[b] = c.memory[c.mar]
+		c.sp++
+	case CALL:
+		c.sp--
+		c.writeMemory(c.sp, c.iar+1)
+		c.mar = c.iar
+		c.iar = c.memory[c.mar]
+	case RET:
+		c.mar = c.sp
+		c.iar = c.memory[c.mar]
+		c.sp++
+	case EI:
+		c.interruptsEnabled = true
+	case DI:
+		c.interruptsEnabled = false
+	case IRET:
+		c.iar = c.savedIAR
+		c.flags = c.savedFlags
+		c.interruptsEnabled = true
+	case SUB:
+		c.registers[b] = c.runThroughALU(ADD, c.registers[a], c.registers[b], c.flags.carry, true)
+	case MOV:
+		c.registers[b] = c.registers[a]
+	case INC:
+		c.registers[b] = c.runThroughALU(ADD, c.registers[b], 0x0001, false, false)
+	case DEC:
+		c.registers[b] = c.runThroughALU(ADD, c.registers[b], 0x0001, false, true)
+	}
+}
+
+// runALU runs an ALU instruction, the result goes in register B except for CMP
+func (c *CPU) runALU(op int, a, b uint16) {
+	result := c.runThroughALU(op, c.registers[a], c.registers[b], c.flags.carry, false)
+	if op != CMP {
+		c.registers[b] = result
+	}
+}
+
+// runThroughALU returns the output of the ALU and sets the flags. The flags register is set
+// before the carry flag reaches the ALU, so the flags are always the ones of the operation
+// without a carry in while the output does use the carry in
+func (c *CPU) runThroughALU(op int, inputA, inputB uint16, carryIn, subtract bool) uint16 {
+	output, carry := alu(op, inputA, inputB, false, subtract)
+	c.flags = flags{
+		carry:   carry,
+		aLarger: inputA > inputB,
+		equal:   inputA == inputB,
+		// CMP does not drive the output of the ALU, which leaves the zero flag off
+		zero: op != CMP && output == 0,
+	}
+
+	output, _ = alu(op, inputA, inputB, carryIn, subtract)
+	return output
+}
+
+// alu returns the output and carry out of the ALU, like the ALU only the adder and the shifters
+// drive the carry out. When subtract is set the adder subtracts inputB from inputA and the
+// carry in and out are a borrow
+func alu(op int, inputA, inputB uint16, carryIn, subtract bool) (uint16, bool) {
+	switch op {
+	case ADD:
+		if subtract {
+			inputB = ^inputB
+			carryIn = !carryIn
+		}
+		sum := uint32(inputA) + uint32(inputB)
+		if carryIn {
+			sum++
+		}
+		return uint16(sum), (sum > 0xFFFF) != subtract
+	case SHR:
+		result := inputA >> 1
+		if carryIn {
+			result |= 0x8000
+		}
+		return result, inputA&0x0001 != 0
+	case SHL:
+		result := inputA << 1
+		if carryIn {
+			result |= 0x0001
+		}
+		return result, inputA&0x8000 != 0
+	case NOT:
+		return ^inputA, false
+	case AND:
+		return inputA & inputB, false
+	case OR:
+		return inputA | inputB, false
+	case XOR:
+		return inputA ^ inputB, false
+	}
+	return 0x0000, false
+}
+
+// enterInterrupt saves the IAR and flags, disables interrupts and jumps to the address in
+// the vector table entry the interrupt controller puts on the main bus when acknowledged
+func (c *CPU) enterInterrupt() {
+	c.savedIAR = c.iar
+	c.savedFlags = c.flags
+	c.interruptsEnabled = false
+
+	c.ioBus.UpdateInterruptAcknowledge(true)
+	c.updatePeripherals()
+	c.mar = c.mainBus.Value()
+	c.ioBus.UpdateInterruptAcknowledge(false)
+	c.updatePeripherals()
+	c.mainBus.SetValue(0x0000)
+
+	c.iar = c.memory[c.mar]
+}
+
+func (c *CPU) writeMemory(address, value uint16) {
+	c.memory[address] = value
+	if c.watchWrites != nil {
+		c.watchWrites(address)
+	}
+}
+
+func (c *CPU) tickPeripherals() {
+	for _, p := range c.clocked {
+		p.Tick()
+	}
+}
+
+func (c *CPU) updatePeripherals() {
+	for _, p := range c.peripherals {
+		p.Update()
+	}
+}
+
+// IAR returns the current value of the instruction address register
+func (c *CPU) IAR() uint16 {
+	return c.iar
+}
+
+// Jump IAR
+func (c *CPU) SetIAR(address uint16) {
+	c.iar = address
+}
+
+// SP returns the current value of the stack pointer
+func (c *CPU) SP() uint16 {
+	return c.sp
+}
+
+// Set the stack pointer, the stack grows downwards from this address
+func (c *CPU) SetSP(address uint16) {
+	c.sp = address
+}
+
+// MAR returns the current value of the memory address register
+func (c *CPU) MAR() uint16 {
+	return c.mar
+}
+
+// SetMAR puts address into the memory address register
+func (c *CPU) SetMAR(address uint16) {
+	c.mar = address
+}
+
+// Register returns the value of general purpose register R0 - R3
+func (c *CPU) Register(index int) uint16 {
+	if index < 0 || index >= len(c.registers) {
+		panic(fmt.Sprintf("unknown general purpose register R%d", index))
+	}
+	return c.registers[index]
+}
+
+// SetRegister puts value into general purpose register R0 - R3
+func (c *CPU) SetRegister(index int, value uint16) {
+	if index < 0 || index >= len(c.registers) {
+		panic(fmt.Sprintf("unknown general purpose register R%d", index))
+	}
+	c.registers[index] = value
+}
+
+// Flags returns the carry, A larger, equal and zero flags
+func (c *CPU) Flags() (carry, aLarger, equal, zero bool) {
+	return c.flags.carry, c.flags.aLarger, c.flags.equal, c.flags.zero
+}
+
+// SetFlags overwrites the flags register
+func (c *CPU) SetFlags(carry, aLarger, equal, zero bool) {
+	c.flags = flags{carry, aLarger, equal, zero}
+}
+
+// ReadMemory returns the value stored in RAM at address
+func (c *CPU) ReadMemory(address uint16) uint16 {
+	return c.memory[address]
+}
+
+// WriteMemory stores value in RAM at address
+func (c *CPU) WriteMemory(address, value uint16) {
+	c.memory[address] = value
+}
+
+// WatchWrites calls watch with the address of every memory write the instructions make, writes
+// made with WriteMemory are not included. watch can be nil to stop watching
+func (c *CPU) WatchWrites(watch func(address uint16)) {
+	c.watchWrites = watch
+}
+
+// State returns the registers, flags and the position in the current instruction, TMP, ACC and
+// CarryTemp are always 0 as the instructions run in one go
+func (c *CPU) State() cpu.State {
+	return cpu.State{
+		Registers:         c.registers,
+		IAR:               c.iar,
+		IR:                c.ir,
+		SP:                c.sp,
+		MAR:               c.mar,
+		SavedIAR:          c.savedIAR,
+		Flags:             [4]bool{c.flags.carry, c.flags.aLarger, c.flags.equal, c.flags.zero},
+		SavedFlags:        [4]bool{c.savedFlags.carry, c.savedFlags.aLarger, c.savedFlags.equal, c.savedFlags.zero},
+		InterruptsEnabled: c.interruptsEnabled,
+		Extended:          c.extended,
+		ExtendNext:        c.extendNext,
+		Interrupting:      c.interrupting,
+		InterruptNext:     c.interruptNext,
+		Halted:            c.halted,
+		Steps:             c.stepperPosition - 1,
+	}
+}
+
+// SetState restores a state returned by State
+func (c *CPU) SetState(s cpu.State) {
+	c.registers = s.Registers
+	c.iar = s.IAR
+	c.ir = s.IR
+	c.sp = s.SP
+	c.mar = s.MAR
+	c.savedIAR = s.SavedIAR
+	c.flags = flags{s.Flags[0], s.Flags[1], s.Flags[2], s.Flags[3]}
+	c.savedFlags = flags{s.SavedFlags[0], s.SavedFlags[1], s.SavedFlags[2], s.SavedFlags[3]}
+	c.interruptsEnabled = s.InterruptsEnabled
+	c.extended = s.Extended
+	c.extendNext = s.ExtendNext
+	c.interrupting = s.Interrupting
+	c.interruptNext = s.InterruptNext
+	c.halted = s.Halted
+	c.stepperPosition = s.Steps%cpu.STEPS_PER_ROUND + 1
+}
+
+// HasExtendedSteps returns true if the instruction that has just been through steps 1 - 6
+// of the stepper needs another round of the stepper, see cpu.CPU.HasExtendedSteps
+func (c *CPU) HasExtendedSteps() bool {
+	return c.extendNext || c.interruptNext
+}
+
+// InterruptsEnabled returns true if the CPU will take interrupts requested by the interrupt controller
+func (c *CPU) InterruptsEnabled() bool {
+	return c.interruptsEnabled
+}
+
+// AtInstructionStart returns true if the next step will fetch a new instruction
+func (c *CPU) AtInstructionStart() bool {
+	return c.stepperPosition == 1 && !c.HasExtendedSteps()
+}
+
+// Halted returns true once a HALT instruction has stopped the stepper
+func (c *CPU) Halted() bool {
+	return c.halted
+}
+
+// StepperPosition returns which of the 6 steps of the stepper (1 - 6) runs next
+func (c *CPU) StepperPosition() int {
+	return c.stepperPosition
+}
+
+func (c *CPU) String() string {
+	return fmt.Sprintf("STEPPER: %d EXTENDED: %v INTERRUPTING: %v INTERRUPTS ENABLED: %v HALTED: %v\nIAR: %s\nSP: %s\nSIAR: %s\nSFLAGS: %s\nMAR: %s\nIR: %s\nR0: %s\nR1: %s\nR2: %s\nR3: %s\nFLAGS: %s\n",
+		c.stepperPosition,
+		c.extended,
+		c.interrupting,
+		c.interruptsEnabled,
+		c.halted,
+		utils.ValueToString(c.iar),
+		utils.ValueToString(c.sp),
+		utils.ValueToString(c.savedIAR),
+		c.savedFlags,
+		utils.ValueToString(c.mar),
+		utils.ValueToString(c.ir),
+		utils.ValueToString(c.registers[0]),
+		utils.ValueToString(c.registers[1]),
+		utils.ValueToString(c.registers[2]),
+		utils.ValueToString(c.registers[3]),
+		c.flags,
+	)
+}
+
+func (f flags) String() string {
+	result := ""
+	for i, on := range []bool{f.carry, f.aLarger, f.equal, f.zero} {
+		if on {
+			result += string("CAEZ"[i])
+		} else {
+			result += "-"
+		}
+	}
+	return result
+}
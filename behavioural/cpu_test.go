@@ -0,0 +1,323 @@
+package behavioural
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/djhworld/simple-computer/asm"
+	"github.com/djhworld/simple-computer/components"
+	"github.com/djhworld/simple-computer/cpu"
+	"github.com/djhworld/simple-computer/io"
+	"github.com/djhworld/simple-computer/memory"
+)
+
+const INTERRUPT_PROGRAM = `
+	DATA R0, 0x0481
+	DATA R1, 0x050F
+	ST R0, R1
+	DATA R0, 0x0001
+	OUT Addr, R0
+	DATA R0, 0x0002
+	OUT Data, R0
+	EI
+loop:
+	INC R2
+	JMP loop
+handler:
+	DATA R0, 0x000F
+	OUT Addr, R0
+	IN Data, R3
+	IRET
+`
+
+// counts the timer interrupts in R1, the handler reads the timer status into R3
+const TIMER_PROGRAM = `
+	DATA R0, 0x0482
+	DATA R1, 0x0516
+	ST R0, R1
+	DATA R1, 0x0000
+	DATA R0, 0x0001
+	OUT Addr, R0
+	DATA R0, 0x0004
+	OUT Data, R0
+	DATA R0, 0x0002
+	OUT Addr, R0
+	DATA R0, 0x%04X
+	OUT Data, R0
+	EI
+loop:
+	INC R2
+	JMP loop
+handler:
+	DATA R0, 0x0002
+	OUT Addr, R0
+	IN Data, R3
+	INC R1
+	IRET
+`
+
+func TestRandomInstructionsMatchGateLevelCPU(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		gates, behavioural := newCPUs()
+
+		for i := 0; i < 500; i++ {
+			// unknown extended instructions and the unused top bits are included on purpose,
+			// HALT is left out as it would stop both CPUs
+			word := uint16(r.Intn(0x0200))
+			for word&0x01F0 == 0x01B0 {
+				word = uint16(r.Intn(0x0200))
+			}
+			word |= uint16(r.Intn(4)) << 14
+			gates.WriteMemory(0x0500+uint16(i), word)
+			behavioural.WriteMemory(0x0500+uint16(i), word)
+		}
+
+		registers := [4]uint16{uint16(0x0500 + r.Intn(500)), uint16(r.Intn(0x10000)), uint16(r.Intn(0x10000)), uint16(0x0500 + r.Intn(500))}
+		for _, c := range []cpu.Processor{gates, behavioural} {
+			c.SetIAR(0x0500)
+			c.SetSP(0x0800)
+			for i, value := range registers {
+				c.SetRegister(i, value)
+			}
+		}
+
+		checkLockStep(t, gates, behavioural, 500, nil)
+	}
+}
+
+func TestInterruptsMatchGateLevelCPU(t *testing.T) {
+	gates, behavioural := newCPUs()
+
+	var keyboards []*io.KeyboardAdapter
+	for _, c := range []cpu.Processor{gates, behavioural} {
+		keyboard := io.NewKeyboardAdapter()
+		c.ConnectPeripheral(keyboard)
+		c.ConnectPeripheral(io.NewInterruptController())
+		keyboards = append(keyboards, keyboard)
+
+		load(t, c, INTERRUPT_PROGRAM)
+	}
+
+	pressKey := func(instruction int) {
+		if instruction == 30 || instruction == 70 {
+			for _, keyboard := range keyboards {
+				keyboard.KeyboardInBus.SetValue(uint16(0x41 + instruction))
+			}
+		}
+	}
+	checkLockStep(t, gates, behavioural, 100, pressKey)
+
+	if behavioural.Register(3) != 0x41+70 {
+		t.Logf("Expected the interrupt handler to have read the second key but R3 = 0x%04X", behavioural.Register(3))
+		t.FailNow()
+	}
+}
+
+func TestTimerInterruptsMatchGateLevelCPU(t *testing.T) {
+	// the reload counts expire at different steps of the instructions
+	for _, reload := range []uint16{5, 13, 31, 47, 100} {
+		gates, behavioural := newCPUs()
+
+		for _, c := range []cpu.Processor{gates, behavioural} {
+			c.ConnectPeripheral(io.NewTimer())
+			c.ConnectPeripheral(io.NewInterruptController())
+
+			load(t, c, fmt.Sprintf(TIMER_PROGRAM, reload))
+		}
+		checkLockStep(t, gates, behavioural, 150, nil)
+
+		if behavioural.Register(1) == 0 {
+			t.Logf("reload %d: expected the timer to have interrupted the program", reload)
+			t.FailNow()
+		}
+	}
+}
+
+func TestSUBUsesCarryAsBorrow(t *testing.T) {
+	_, c := newCPUs()
+	load(t, c, `
+	DATA R0, 0x0005
+	DATA R1, 0x0003
+	SUB R0, R1
+	SUB R0, R0
+	`)
+
+	c.SetFlags(true, false, false, false)
+	runInstructions(c, 3)
+	if c.Register(1) != 0x0001 {
+		t.Logf("Expected 5 - 3 - borrow = 1 but got 0x%04X", c.Register(1))
+		t.FailNow()
+	}
+	checkFlags(t, c, false, true, false, false)
+
+	runInstructions(c, 1)
+	if c.Register(0) != 0x0000 {
+		t.Logf("Expected 0x0000 but got 0x%04X", c.Register(0))
+		t.FailNow()
+	}
+	checkFlags(t, c, false, false, true, true)
+}
+
+func TestCALLTakesTwoRounds(t *testing.T) {
+	_, c := newCPUs()
+	load(t, c, `
+	CALL routine
+	CLF
+routine:
+	RET
+	`)
+
+	for i := 0; i < cpu.STEPS_PER_ROUND; i++ {
+		c.Step()
+	}
+	if !c.HasExtendedSteps() || c.AtInstructionStart() {
+		t.Log("Expected CALL to need another round of the stepper")
+		t.FailNow()
+	}
+
+	for i := 0; i < cpu.STEPS_PER_ROUND; i++ {
+		c.Step()
+	}
+	if c.HasExtendedSteps() || !c.AtInstructionStart() {
+		t.Log("Expected CALL to be done after two rounds of the stepper")
+		t.FailNow()
+	}
+	if c.IAR() != 0x0503 || c.SP() != 0x07FF || c.ReadMemory(0x07FF) != 0x0502 {
+		t.Logf("Expected to be in the routine with the return address pushed but got\n%s", c)
+		t.FailNow()
+	}
+}
+
+func TestHALTMatchesGateLevelCPU(t *testing.T) {
+	gates, behavioural := newCPUs()
+	for _, c := range []cpu.Processor{gates, behavioural} {
+		load(t, c, `
+	DATA R0, 0x002A
+	HALT
+	INC R0
+	`)
+	}
+
+	checkLockStep(t, gates, behavioural, 5, nil)
+
+	for _, c := range []cpu.Processor{gates, behavioural} {
+		if !c.Halted() || c.State().Steps != 4 || c.Register(0) != 0x002A || c.IAR() != 0x0503 {
+			t.Logf("Expected the CPU to halt at step 4 of HALT but got\n%s", c)
+			t.FailNow()
+		}
+	}
+}
+
+func TestStateRestoresMidInstruction(t *testing.T) {
+	_, c := newCPUs()
+	load(t, c, `
+	DATA R0, 0x0005
+	CALL routine
+	CLF
+routine:
+	SUB R0, R0
+	RET
+	`)
+
+	for i := 0; i < 9; i++ {
+		c.Step()
+	}
+
+	// the program and the stack
+	_, restored := newCPUs()
+	for address := uint16(0x0500); address < 0x0800; address++ {
+		restored.WriteMemory(address, c.ReadMemory(address))
+	}
+	restored.SetState(c.State())
+
+	for i := 0; i < 30; i++ {
+		c.Step()
+		restored.Step()
+		if expected, actual := state(c), state(restored); expected != actual {
+			t.Logf("Step %d: expected the restored CPU to be in state\n%s\nbut got\n%s", i, expected, actual)
+			t.FailNow()
+		}
+	}
+}
+
+func newCPUs() (*cpu.CPU, *CPU) {
+	gatesBus := components.NewBus(cpu.BUS_WIDTH)
+	gates := cpu.NewCPU(gatesBus, memory.NewMemory64K(gatesBus))
+	behavioural := NewCPU(components.NewBus(cpu.BUS_WIDTH))
+	return gates, behavioural
+}
+
+func load(t *testing.T, c cpu.Processor, source string) {
+	p := asm.Parser{}
+	instructions, err := p.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Logf("Error parsing program: %v", err)
+		t.FailNow()
+	}
+
+	a := asm.Assembler{}
+	bin, err := a.Process(0x0500, instructions)
+	if err != nil {
+		t.Logf("Error assembling program: %v", err)
+		t.FailNow()
+	}
+
+	for i, word := range bin {
+		c.WriteMemory(0x0500+uint16(i), word)
+	}
+	c.SetIAR(0x0500)
+	c.SetSP(0x0800)
+}
+
+func runInstructions(c cpu.Processor, n int) {
+	for i := 0; i < n; i++ {
+		for {
+			for step := 0; step < cpu.STEPS_PER_ROUND; step++ {
+				c.Step()
+			}
+			if !c.HasExtendedSteps() {
+				break
+			}
+		}
+	}
+}
+
+// checkLockStep runs both CPUs an instruction at a time and checks they are in the same state
+// after every instruction, before is called with the instruction number before it runs
+func checkLockStep(t *testing.T, gates, behavioural cpu.Processor, instructions int, before func(int)) {
+	for n := 0; n < instructions; n++ {
+		if before != nil {
+			before(n)
+		}
+
+		iar := gates.IAR()
+		runInstructions(gates, 1)
+		runInstructions(behavioural, 1)
+
+		if expected, actual := state(gates), state(behavioural); expected != actual {
+			t.Logf("Instruction %d at 0x%04X differs\ngate level:  %s\nbehavioural: %s", n, iar, expected, actual)
+			t.FailNow()
+		}
+	}
+}
+
+func state(c cpu.Processor) string {
+	carry, aLarger, equal, zero := c.Flags()
+	return fmt.Sprintf("R0 0x%04X R1 0x%04X R2 0x%04X R3 0x%04X IAR 0x%04X SP 0x%04X MAR 0x%04X [MAR] 0x%04X [SP] 0x%04X FLAGS %s IE %v",
+		c.Register(0), c.Register(1), c.Register(2), c.Register(3),
+		c.IAR(), c.SP(), c.MAR(), c.ReadMemory(c.MAR()), c.ReadMemory(c.SP()),
+		flags{carry, aLarger, equal, zero}, c.InterruptsEnabled())
+}
+
+func checkFlags(t *testing.T, c cpu.Processor, carry, aLarger, equal, zero bool) {
+	expected := flags{carry, aLarger, equal, zero}
+	var actual flags
+	actual.carry, actual.aLarger, actual.equal, actual.zero = c.Flags()
+	if actual != expected {
+		t.Logf("Expected flags %s but got %s", expected, actual)
+		t.FailNow()
+	}
+}
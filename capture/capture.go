@@ -0,0 +1,128 @@
+package capture
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	goio "io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/djhworld/simple-computer/io"
+)
+
+// Image converts frame to an image in the colours of its palette
+func Image(frame *io.Frame) *image.Paletted {
+	palette := make(color.Palette, len(frame.Palette))
+	for n, c := range frame.Palette {
+		palette[n] = color.RGBA{c.R, c.G, c.B, 0xFF}
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, len(frame.Pixels[0]), len(frame.Pixels)), palette)
+	for y := range frame.Pixels {
+		for x := range frame.Pixels[y] {
+			img.SetColorIndex(x, y, frame.Pixels[y][x])
+		}
+	}
+	return img
+}
+
+// WritePNG writes frame to w as a PNG image
+func WritePNG(w goio.Writer, frame *io.Frame) error {
+	return png.Encode(w, Image(frame))
+}
+
+// SavePNG writes frame to the PNG image filename
+func SavePNG(filename string, frame *io.Frame) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := WritePNG(f, frame); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Recorder records every stride-th frame it is given, either to an animated GIF or as numbered
+// PNG images in a directory
+type Recorder struct {
+	path   string
+	stride int
+	// the time between two recorded frames in 100ths of a second
+	delay int
+
+	frames    int
+	recorded  int
+	animation *gif.GIF
+}
+
+// NewRecorder records to path, a path ending in .gif is an animated GIF written by Close and any other path
+// is a directory the PNG images are written to as they are recorded, it is created if it does not exist.
+// interval is the time between two frames given to the recorder, used to play the GIF back at the same speed
+func NewRecorder(path string, stride int, interval time.Duration) (*Recorder, error) {
+	if stride < 1 {
+		return nil, fmt.Errorf("the stride must be at least 1 but got %d", stride)
+	}
+
+	r := new(Recorder)
+	r.path = path
+	r.stride = stride
+	r.delay = int(interval * time.Duration(stride) / (10 * time.Millisecond))
+	if r.delay < 1 {
+		r.delay = 1
+	}
+
+	if strings.HasSuffix(strings.ToLower(path), ".gif") {
+		r.animation = &gif.GIF{}
+	} else if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Record records frame if it is the stride-th frame since the last one recorded, the first frame is always recorded
+func (r *Recorder) Record(frame *io.Frame) error {
+	r.frames++
+	if (r.frames-1)%r.stride != 0 {
+		return nil
+	}
+
+	if r.animation != nil {
+		r.animation.Image = append(r.animation.Image, Image(frame))
+		r.animation.Delay = append(r.animation.Delay, r.delay)
+	} else if err := SavePNG(filepath.Join(r.path, fmt.Sprintf("frame-%06d.png", r.recorded)), frame); err != nil {
+		return err
+	}
+	r.recorded++
+	return nil
+}
+
+// Recorded returns the number of frames recorded so far
+func (r *Recorder) Recorded() int {
+	return r.recorded
+}
+
+// Close writes the animated GIF, if no frames were recorded there is nothing to write
+func (r *Recorder) Close() error {
+	if r.animation == nil || len(r.animation.Image) == 0 {
+		return nil
+	}
+
+	f, err := os.Create(r.path)
+	if err != nil {
+		return err
+	}
+
+	if err := gif.EncodeAll(f, r.animation); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
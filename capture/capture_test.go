@@ -0,0 +1,125 @@
+package capture
+
+import (
+	"bytes"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/djhworld/simple-computer/io"
+)
+
+func TestPNGHasColoursOfPalette(t *testing.T) {
+	frame := &io.Frame{Palette: io.MONO_PALETTE}
+	frame.Palette[2] = io.NewColour(0x0F80)
+	frame.Pixels[10][20] = 1
+	frame.Pixels[159][239] = 2
+
+	var b bytes.Buffer
+	if err := WritePNG(&b, frame); err != nil {
+		t.Logf("Error writing PNG: %v", err)
+		t.FailNow()
+	}
+	img, err := png.Decode(&b)
+	if err != nil {
+		t.Logf("Error reading PNG: %v", err)
+		t.FailNow()
+	}
+
+	if bounds := img.Bounds(); bounds.Dx() != 240 || bounds.Dy() != 160 {
+		t.Logf("Expected a 240x160 image but got %v", bounds)
+		t.FailNow()
+	}
+	for _, p := range []struct {
+		x, y   int
+		colour io.Colour
+	}{{0, 0, io.Colour{50, 50, 50}}, {20, 10, io.Colour{220, 220, 220}}, {239, 159, io.Colour{0xFF, 0x88, 0x00}}} {
+		r, g, b, _ := img.At(p.x, p.y).RGBA()
+		if (io.Colour{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)}) != p.colour {
+			t.Logf("Expected pixel %d,%d to be %v but got %v", p.x, p.y, p.colour, img.At(p.x, p.y))
+			t.FailNow()
+		}
+	}
+}
+
+func TestRecorderWritesEveryStrideFramesToGIF(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "capture.gif")
+	recorder, err := NewRecorder(filename, 3, 50*time.Millisecond)
+	if err != nil {
+		t.Logf("Error creating recorder: %v", err)
+		t.FailNow()
+	}
+
+	for n := 0; n < 7; n++ {
+		frame := &io.Frame{Palette: io.MONO_PALETTE}
+		frame.Pixels[0][n] = 1
+		recorder.Record(frame)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Logf("Error writing GIF: %v", err)
+		t.FailNow()
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Logf("Error opening GIF: %v", err)
+		t.FailNow()
+	}
+	defer f.Close()
+	animation, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Logf("Error reading GIF: %v", err)
+		t.FailNow()
+	}
+
+	// frames 0, 3 and 6
+	if len(animation.Image) != 3 || recorder.Recorded() != 3 {
+		t.Logf("Expected 3 frames to be recorded but got %d", len(animation.Image))
+		t.FailNow()
+	}
+	for n, img := range animation.Image {
+		if img.ColorIndexAt(n*3, 0) != 1 || animation.Delay[n] != 15 {
+			t.Logf("Expected frame %d of the GIF to be frame %d with a delay of 15 but got delay %d", n, n*3, animation.Delay[n])
+			t.FailNow()
+		}
+	}
+}
+
+func TestRecorderWritesNumberedPNGsToDirectory(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	recorder, err := NewRecorder(filepath.Join(dir, "frames"), 2, 0)
+	if err != nil {
+		t.Logf("Error creating recorder: %v", err)
+		t.FailNow()
+	}
+	for n := 0; n < 4; n++ {
+		if err := recorder.Record(&io.Frame{Palette: io.MONO_PALETTE}); err != nil {
+			t.Logf("Error recording frame %d: %v", n, err)
+			t.FailNow()
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "frames", "*.png"))
+	if err != nil || len(files) != 2 || filepath.Base(files[1]) != "frame-000001.png" {
+		t.Logf("Expected frame-000000.png and frame-000001.png but got %v (%v)", files, err)
+		t.FailNow()
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "capture")
+	if err != nil {
+		t.Logf("Error creating a directory for the capture: %v", err)
+		t.FailNow()
+	}
+	return dir
+}
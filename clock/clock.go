@@ -0,0 +1,258 @@
+package clock
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Mode is how the clock is ticking, every tick is one step of the CPU
+type Mode int
+
+const (
+	// as fast as the CPU can step
+	UNTHROTTLED Mode = iota
+	// at the frequency set with SetHz
+	FIXED_HZ
+	// only the steps allowed with Step
+	PAUSED
+	// unthrottled while turbo is held, then back to the frequency that was set
+	TURBO
+)
+
+func (m Mode) String() string {
+	switch m {
+	case UNTHROTTLED:
+		return "unthrottled"
+	case FIXED_HZ:
+		return "fixed"
+	case PAUSED:
+		return "paused"
+	case TURBO:
+		return "turbo"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// the effective frequency is measured over this long
+const MEASURE_WINDOW = time.Second
+
+// a clock that has fallen further behind than this (e.g. the host was busy) starts counting again
+// from now instead of running flat out to catch up
+const MAX_LAG = 250 * time.Millisecond
+
+// sleeping for less than this costs more than it saves, the ticks are let through and the time
+// is made up at the next tick that is far enough ahead
+const MIN_SLEEP = time.Millisecond
+
+// Clock paces the computer. Ticks at a fixed frequency are scheduled against a deadline that
+// advances by exactly one period per tick, so the average frequency is exact even though the
+// host can only sleep for a millisecond or so at a time. All methods can be called from any goroutine
+type Clock struct {
+	mu      sync.Mutex
+	resumed *sync.Cond
+
+	hz     float64
+	paused bool
+	turbo  bool
+	// the steps that can still run while paused
+	steps int
+
+	// when the next tick is due at a fixed frequency, zero when not running at one
+	next time.Time
+
+	windowStart time.Time
+	windowTicks int
+	effectiveHz float64
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// NewClock returns a clock that ticks hz times a second, 0 runs unthrottled
+func NewClock(hz float64) *Clock {
+	c := new(Clock)
+	c.resumed = sync.NewCond(&c.mu)
+	c.hz = hz
+	c.now = time.Now
+	c.sleep = time.Sleep
+	return c
+}
+
+// Tick blocks until the next step should be taken
+func (c *Clock) Tick() {
+	c.mu.Lock()
+	for c.paused && c.steps == 0 {
+		c.resumed.Wait()
+	}
+
+	now := c.now()
+	c.measure(now)
+
+	var wait time.Duration
+	switch c.mode() {
+	case FIXED_HZ:
+		if c.next.IsZero() || now.Sub(c.next) > MAX_LAG {
+			c.next = now
+		}
+		wait = c.next.Sub(now)
+		c.next = c.next.Add(c.period())
+	case PAUSED:
+		c.steps--
+		c.next = time.Time{}
+	default:
+		c.next = time.Time{}
+	}
+	c.mu.Unlock()
+
+	if wait >= MIN_SLEEP {
+		c.sleep(wait)
+	}
+}
+
+func (c *Clock) measure(now time.Time) {
+	if c.windowStart.IsZero() {
+		c.windowStart = now
+	}
+	c.windowTicks++
+
+	if elapsed := now.Sub(c.windowStart); elapsed >= MEASURE_WINDOW {
+		c.effectiveHz = float64(c.windowTicks) / elapsed.Seconds()
+		c.windowStart = now
+		c.windowTicks = 0
+	}
+}
+
+func (c *Clock) period() time.Duration {
+	return time.Duration(float64(time.Second) / c.hz)
+}
+
+func (c *Clock) mode() Mode {
+	switch {
+	case c.paused:
+		return PAUSED
+	case c.turbo:
+		return TURBO
+	case c.hz > 0:
+		return FIXED_HZ
+	}
+	return UNTHROTTLED
+}
+
+// Mode returns how the clock is ticking
+func (c *Clock) Mode() Mode {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.mode()
+}
+
+// Hz returns the frequency set with SetHz, 0 is unthrottled
+func (c *Clock) Hz() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hz
+}
+
+// SetHz sets the frequency the clock ticks at, 0 runs unthrottled
+func (c *Clock) SetHz(hz float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hz < 0 {
+		hz = 0
+	}
+	c.hz = hz
+	c.next = time.Time{}
+}
+
+// EffectiveHz returns the number of ticks a second measured over the last MEASURE_WINDOW,
+// it drops to 0 when the clock has not ticked for a while
+func (c *Clock) EffectiveHz() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.windowStart.IsZero() || c.now().Sub(c.windowStart) >= 2*MEASURE_WINDOW {
+		return 0
+	}
+	return c.effectiveHz
+}
+
+// Pause stops the clock ticking until Resume is called, see Step
+func (c *Clock) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = true
+	c.steps = 0
+}
+
+// Resume carries on ticking in the mode the clock was in before it was paused
+func (c *Clock) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = false
+	c.resumed.Broadcast()
+}
+
+// TogglePause pauses a running clock and resumes a paused one
+func (c *Clock) TogglePause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = !c.paused
+	c.steps = 0
+	c.resumed.Broadcast()
+}
+
+// Step lets n more ticks through while the clock is paused
+func (c *Clock) Step(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		c.steps += n
+		c.resumed.Broadcast()
+	}
+}
+
+// Scale multiplies the frequency by factor, an unthrottled clock is given a frequency from the
+// frequency it has been running at first
+func (c *Clock) Scale(factor float64) {
+	hz := c.Hz()
+	if hz == 0 {
+		hz = c.EffectiveHz()
+	}
+	if hz*factor < 1 {
+		c.SetHz(1)
+		return
+	}
+	c.SetHz(hz * factor)
+}
+
+// SetTurbo runs the clock unthrottled while on is true, e.g. while a key is held
+func (c *Clock) SetTurbo(on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.turbo = on
+	c.next = time.Time{}
+}
+
+// String describes the mode and the effective frequency, e.g. "1.0 kHz (998.2 Hz)"
+func (c *Clock) String() string {
+	effective := FormatHz(c.EffectiveHz())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch mode := c.mode(); mode {
+	case FIXED_HZ:
+		return fmt.Sprintf("%s (%s)", FormatHz(c.hz), effective)
+	default:
+		return fmt.Sprintf("%s (%s)", mode, effective)
+	}
+}
+
+// FormatHz formats a frequency with the unit that fits it, e.g. 6.2 kHz
+func FormatHz(hz float64) string {
+	switch {
+	case hz >= 1e6:
+		return fmt.Sprintf("%.1f MHz", hz/1e6)
+	case hz >= 1e3:
+		return fmt.Sprintf("%.1f kHz", hz/1e3)
+	}
+	return fmt.Sprintf("%.1f Hz", hz)
+}
@@ -0,0 +1,165 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeTime is a clock for the clock, sleeping moves it on straight away
+type fakeTime struct {
+	now   time.Time
+	slept time.Duration
+}
+
+func newTestClock(hz float64) (*Clock, *fakeTime) {
+	t := &fakeTime{now: time.Unix(0, 0)}
+	c := NewClock(hz)
+	c.now = func() time.Time {
+		return t.now
+	}
+	c.sleep = func(d time.Duration) {
+		t.now = t.now.Add(d)
+		t.slept += d
+	}
+	return c, t
+}
+
+func TestFixedHzTicksAtTheFrequency(t *testing.T) {
+	c, fake := newTestClock(1000)
+
+	for i := 0; i < 3001; i++ {
+		c.Tick()
+	}
+
+	// the first tick is straight away
+	if fake.now != time.Unix(3, 0) {
+		t.Logf("Expected 3001 ticks at 1 kHz to take 3s but took %v", fake.now.Sub(time.Unix(0, 0)))
+		t.FailNow()
+	}
+	if c.EffectiveHz() != 1000 {
+		t.Logf("Expected the effective frequency to be 1000 Hz but got %f", c.EffectiveHz())
+		t.FailNow()
+	}
+}
+
+func TestFixedHzMakesUpShortSleeps(t *testing.T) {
+	// 10 kHz is 100µs a tick, too short to sleep for every tick
+	c, fake := newTestClock(10000)
+
+	for i := 0; i < 10001; i++ {
+		c.Tick()
+		// the CPU takes 50µs a step
+		fake.now = fake.now.Add(50 * time.Microsecond)
+	}
+
+	// the last tick is due at 1s, the CPU then takes its 50µs
+	if fake.now != time.Unix(1, 50000) {
+		t.Logf("Expected 10001 ticks at 10 kHz to take 1s but took %v", fake.now.Sub(time.Unix(0, 0)))
+		t.FailNow()
+	}
+	if fake.slept > 500*time.Millisecond || fake.slept < 499*time.Millisecond {
+		t.Logf("Expected the clock to sleep for the time the CPU did not use but slept %v", fake.slept)
+		t.FailNow()
+	}
+}
+
+func TestFixedHzDoesNotCatchUpAfterFallingBehind(t *testing.T) {
+	c, fake := newTestClock(100)
+
+	c.Tick()
+	fake.now = fake.now.Add(time.Second)
+	c.Tick()
+	c.Tick()
+
+	if fake.slept != 10*time.Millisecond {
+		t.Logf("Expected the clock to start again from the late tick but slept %v", fake.slept)
+		t.FailNow()
+	}
+}
+
+func TestUnthrottledAndTurboDoNotSleep(t *testing.T) {
+	c, fake := newTestClock(0)
+	for i := 0; i < 100; i++ {
+		c.Tick()
+	}
+
+	c.SetHz(1)
+	c.SetTurbo(true)
+	if c.Mode() != TURBO {
+		t.Logf("Expected the clock to be in turbo but it is %s", c.Mode())
+		t.FailNow()
+	}
+	for i := 0; i < 100; i++ {
+		c.Tick()
+	}
+
+	if fake.slept != 0 {
+		t.Logf("Expected the clock not to sleep but it slept %v", fake.slept)
+		t.FailNow()
+	}
+
+	c.SetTurbo(false)
+	if c.Mode() != FIXED_HZ {
+		t.Logf("Expected the clock to go back to its frequency but it is %s", c.Mode())
+		t.FailNow()
+	}
+}
+
+func TestPausedOnlyTicksForSteps(t *testing.T) {
+	c, _ := newTestClock(0)
+	c.Pause()
+
+	ticks := make(chan bool)
+	go func() {
+		for {
+			c.Tick()
+			ticks <- true
+		}
+	}()
+
+	c.Step(2)
+	<-ticks
+	<-ticks
+	select {
+	case <-ticks:
+		t.Log("Expected the paused clock to stop after the steps")
+		t.FailNow()
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	c.Resume()
+	for i := 0; i < 10; i++ {
+		<-ticks
+	}
+}
+
+func TestScale(t *testing.T) {
+	c, _ := newTestClock(100)
+
+	c.Scale(0.5)
+	c.Scale(0.5)
+	if c.Hz() != 25 {
+		t.Logf("Expected 25 Hz but got %f", c.Hz())
+		t.FailNow()
+	}
+
+	c.Scale(0.01)
+	if c.Hz() != 1 {
+		t.Logf("Expected the frequency not to go below 1 Hz but got %f", c.Hz())
+		t.FailNow()
+	}
+}
+
+func TestFormatHz(t *testing.T) {
+	for hz, expected := range map[float64]string{
+		0:       "0.0 Hz",
+		999:     "999.0 Hz",
+		6250:    "6.2 kHz",
+		2500000: "2.5 MHz",
+	} {
+		if actual := FormatHz(hz); actual != expected {
+			t.Logf("Expected %s but got %s", expected, actual)
+			t.FailNow()
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/djhworld/simple-computer/asm"
 )
@@ -15,6 +16,24 @@ const USER_CODE_START = uint16(0x0500)
 var inputFile = flag.String("i", "", "input file (default: stdin)")
 var outputFile = flag.String("o", "", "output file (default: stdout)")
 var render = flag.Bool("s", false, "output assembly as string")
+var mapFile = flag.String("m", "", "write debug info (labels, symbols and the source line of each instruction) to this file")
+var includePaths = stringList{}
+
+func init() {
+	flag.Var(&includePaths, "I", "a directory to search for included files, can be given more than once")
+}
+
+// stringList is a flag that can be given more than once
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
 
 func exitWithError(message string, err error, exitCode int) {
 	fmt.Fprintln(os.Stderr, message, err)
@@ -23,6 +42,28 @@ func exitWithError(message string, err error, exitCode int) {
 	os.Exit(exitCode)
 }
 
+// exitWithDiagnostics lists the errors found in the input one a line, the way compilers do
+func exitWithDiagnostics(diagnostics asm.Diagnostics, exitCode int) {
+	for _, diagnostic := range diagnostics {
+		fmt.Fprintln(os.Stderr, diagnostic)
+	}
+	if len(diagnostics) == 1 {
+		fmt.Fprintln(os.Stderr, "1 error")
+	} else {
+		fmt.Fprintf(os.Stderr, "%d errors\n", len(diagnostics))
+	}
+	os.Exit(exitCode)
+}
+
+// exitIfFailed exits when there is an error, listing its diagnostics if it has them
+func exitIfFailed(message string, err error, exitCode int) {
+	if diagnostics, ok := err.(asm.Diagnostics); ok {
+		exitWithDiagnostics(diagnostics, exitCode)
+	} else if err != nil {
+		exitWithError(message, err, exitCode)
+	}
+}
+
 func main() {
 	flag.Parse()
 
@@ -32,19 +73,36 @@ func main() {
 	}
 	defer reader.Close()
 
-	parser := asm.Parser{}
+	parser := asm.Parser{Filename: *inputFile, IncludePaths: includePaths}
 	instructions, err := parser.Parse(reader)
-	if err != nil {
-		exitWithError("error parsing input: ", err, 104)
+	assembler := asm.Assembler{Positions: parser.Positions()}
+	if diagnostics, ok := err.(asm.Diagnostics); ok {
+		// the lines that did parse can have errors that are only found when they are assembled
+		if _, err := assembler.Process(USER_CODE_START, instructions); err != nil {
+			if more, ok := err.(asm.Diagnostics); ok {
+				diagnostics = append(diagnostics, more...)
+			}
+		}
+		exitWithDiagnostics(diagnostics, 104)
+	}
+	exitIfFailed("error parsing input: ", err, 104)
+	for _, warning := range parser.Warnings() {
+		fmt.Fprintln(os.Stderr, warning)
 	}
 
-	asm := asm.Assembler{}
 
-	if *render == false {
-		rawIns, err := asm.Process(USER_CODE_START, instructions)
-		if err != nil {
-			exitWithError("error assembling input: ", err, 104)
+	if *mapFile != "" {
+		debugInfo, err := assembler.DebugInfo(USER_CODE_START, instructions, parser.Positions())
+		exitIfFailed("error assembling input: ", err, 104)
+
+		if err := writeDebugInfo(*mapFile, debugInfo); err != nil {
+			exitWithError("error writing debug info: ", err, 5)
 		}
+	}
+
+	if *render == false {
+		rawIns, err := assembler.Process(USER_CODE_START, instructions)
+		exitIfFailed("error assembling input: ", err, 104)
 
 		writer, err := getWriterFor(*outputFile)
 		if err != nil {
@@ -56,10 +114,8 @@ func main() {
 			exitWithError("error writing output handle: ", err, 5)
 		}
 	} else {
-		str, err := asm.ToString(USER_CODE_START, instructions)
-		if err != nil {
-			exitWithError("error assembling input: ", err, 104)
-		}
+		str, err := assembler.ToString(USER_CODE_START, instructions)
+		exitIfFailed("error assembling input: ", err, 104)
 
 		writer, err := getWriterFor(*outputFile)
 		if err != nil {
@@ -71,6 +127,19 @@ func main() {
 	}
 }
 
+func writeDebugInfo(file string, debugInfo *asm.DebugInfo) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	if err := debugInfo.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 func getReaderFor(file string) (io.ReadCloser, error) {
 	if file == "" {
 		return os.Stdin, nil
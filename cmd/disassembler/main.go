@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/djhworld/simple-computer/asm"
+	"github.com/djhworld/simple-computer/computer"
+	"github.com/djhworld/simple-computer/disassembler"
+)
+
+var inputFile = flag.String("i", "", "input .bin file")
+var outputFile = flag.String("o", "", "output file (default: stdout)")
+var mapFile = flag.String("m", "", "debug info written by the assembler (-m), used to restore the labels of the program")
+
+func exitWithError(message string, err error, exitCode int) {
+	fmt.Fprintln(os.Stderr, message, err)
+	fmt.Fprint(os.Stderr, "\n")
+	flag.Usage()
+	os.Exit(exitCode)
+}
+
+func main() {
+	flag.Parse()
+
+	if *inputFile == "" {
+		exitWithError("error reading input: ", fmt.Errorf("no input file given"), 2)
+	}
+
+	bin, err := computer.ReadBinFile(*inputFile)
+	if err != nil {
+		exitWithError("error reading input: ", err, 5)
+	}
+
+	var debugInfo *asm.DebugInfo
+	if *mapFile != "" {
+		if debugInfo, err = asm.ReadDebugInfoFile(*mapFile); err != nil {
+			exitWithError("error reading map file: ", err, 5)
+		}
+	}
+
+	instructions, err := disassembler.Disassemble(computer.CODE_REGION_START, bin, debugInfo)
+	if err != nil {
+		exitWithError("error disassembling input: ", err, 104)
+	}
+
+	writer, err := getWriterFor(*outputFile)
+	if err != nil {
+		exitWithError("error getting output handle: ", err, 104)
+	}
+	defer writer.Close()
+
+	fmt.Fprint(writer, disassembler.Format(instructions))
+}
+
+func getWriterFor(file string) (io.WriteCloser, error) {
+	if file == "" {
+		return os.Stdout, nil
+	}
+
+	return os.Create(file)
+}
@@ -112,7 +112,6 @@ func initialiseCommonCode() []asm.Instruction {
 
 	instructions.Add(
 		asm.DEFSYMBOL{"LINE-WIDTH", 0x001E},
-		asm.DEFSYMBOL{"CALL-RETURN-ADDRESS", 0xFF33},
 		asm.DEFSYMBOL{"ONE", 0x0001},
 		asm.DEFSYMBOL{"LINEX", 0xFF01},
 		asm.DEFSYMBOL{"PEN-POSITION-ADDR", 0x0400},
@@ -150,7 +149,7 @@ func routine_loadFontDescriptions(label string) []asm.Instruction {
 		)
 	}
 
-	instructions.Add(asm.JR{asm.REG3})
+	instructions.Add(asm.RET{})
 	return instructions.Get()
 }
 
@@ -158,12 +157,6 @@ func routine_pollKeyboard(labelPrefix string) []asm.Instruction {
 	instructions := asm.Instructions{}
 	instructions.Add(asm.DEFLABEL{labelPrefix})
 
-	// push retun address
-	instructions.Add(
-		asm.DATA{asm.REG2, asm.SYMBOL{"CALL-RETURN-ADDRESS"}},
-		asm.STORE{asm.REG2, asm.REG3},
-	)
-
 	instructions.Add(
 		asm.DATA{asm.REG2, asm.NUMBER{0x000F}}, //select keyboard keyboard
 		asm.OUT{asm.ADDRESS_MODE, asm.REG2},
@@ -187,9 +180,41 @@ func routine_pollKeyboard(labelPrefix string) []asm.Instruction {
 	// return to callee
 	instructions.Add(
 		asm.CLF{},
-		asm.DATA{asm.REG3, asm.SYMBOL{"CALL-RETURN-ADDRESS"}},
-		asm.LOAD{asm.REG3, asm.REG3},
-		asm.JR{asm.REG3},
+		asm.RET{},
+	)
+
+	return instructions.Get()
+}
+
+// prints the null terminated string at the address in R0 to the serial port
+func routine_serialPrintString(labelPrefix string) []asm.Instruction {
+	instructions := asm.Instructions{}
+	instructions.Add(asm.DEFLABEL{labelPrefix})
+
+	instructions.Add(
+		asm.DATA{asm.REG2, asm.SYMBOL{"SERIAL-ADAPTER-ADDR"}}, // select serial adapter
+		asm.OUT{asm.ADDRESS_MODE, asm.REG2},
+	)
+
+	instructions.Add(
+		asm.DEFLABEL{labelPrefix + "-STARTLOOP"},
+		asm.LOAD{asm.REG0, asm.REG1},                                 // load the next character
+		asm.AND{asm.REG1, asm.REG1},                                  // check if it is the null at the end
+		asm.JMPF{[]string{"Z"}, asm.LABEL{labelPrefix + "-ENDLOOP"}}, // if it is - stop
+		asm.OUT{asm.DATA_MODE, asm.REG1},                             // otherwise send it
+		asm.DATA{asm.REG3, asm.SYMBOL{"ONE"}},                        // and move on to the next one
+		asm.ADD{asm.REG3, asm.REG0},
+		asm.CLF{},
+		asm.JMP{asm.LABEL{labelPrefix + "-STARTLOOP"}},
+
+		asm.DEFLABEL{labelPrefix + "-ENDLOOP"},
+	)
+	instructions.AddBlocks(deselectIO(asm.REG2))
+
+	// return to callee
+	instructions.Add(
+		asm.CLF{},
+		asm.RET{},
 	)
 
 	return instructions.Get()
@@ -212,8 +237,6 @@ func routine_drawFontCharacter(labelPrefix string) []asm.Instruction {
 	instructions.Add(asm.DEFLABEL{labelPrefix})
 
 	instructions.Add(
-		asm.DATA{asm.REG2, asm.SYMBOL{"CALL-RETURN-ADDRESS"}},
-		asm.STORE{asm.REG2, asm.REG3},
 		asm.DATA{asm.REG2, asm.SYMBOL{"PEN-POSITION-ADDR"}},
 		asm.LOAD{asm.REG2, asm.REG2},
 	)
@@ -380,9 +403,7 @@ func routine_drawFontCharacter(labelPrefix string) []asm.Instruction {
 	// return to callee
 	instructions.Add(
 		asm.CLF{},
-		asm.DATA{asm.REG3, asm.SYMBOL{"CALL-RETURN-ADDRESS"}},
-		asm.LOAD{asm.REG3, asm.REG3},
-		asm.JR{asm.REG3},
+		asm.RET{},
 	)
 
 	return instructions.Get()
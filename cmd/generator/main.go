@@ -13,7 +13,7 @@ import (
 // 0x0000 - 0x03FF ASCII table
 // 0x0400 - 0x0400 pen position
 // 0x0401 - 0x0401 keycode register
-// 0x0500 - 0xFEFD user code + memory
+// 0x0500 - 0xFEFD user code + memory, the stack grows down from 0xFEFD
 // 0xFEFE - 0xFEFF used to jump back to user code
 // 0xFF00 - 0xFFFF temporary variables
 func main() {
@@ -40,6 +40,9 @@ func main() {
 	case "me":
 		me(instructions)
 		return
+	case "serial":
+		serial(instructions)
+		return
 	default:
 		log.Fatalf("unknown program: %s", program)
 	}
@@ -69,10 +72,58 @@ func me(instructions asm.Instructions) {
 		resetLinex(),
 	)
 
+	// exit code 0
+	instructions.Add(
+		asm.DATA{asm.REG0, asm.NUMBER{0x0000}},
+		asm.HALT{},
+	)
+
+	fmt.Println(instructions.String())
+}
+
+func serial(instructions asm.Instructions) {
+	instructions.Add(
+		asm.DEFSYMBOL{"SERIAL-ADAPTER-ADDR", 0x0004},
+		asm.JMP{asm.LABEL{"main"}},
+	)
+	instructions.AddBlocks(routine_serialPrintString("ROUTINE-io-serialPrintString"))
+
+	// MAIN FUNCTION
 	instructions.Add(
-		asm.DEFLABEL{"noop"},
+		asm.DEFLABEL{"main"},
+		asm.DATA{asm.REG0, asm.LABEL{"greeting"}},
+	)
+	instructions.AddBlocks(
+		callRoutine("ROUTINE-io-serialPrintString"),
+	)
+
+	// echo everything received until the end of the line
+	instructions.Add(
+		asm.DATA{asm.REG2, asm.SYMBOL{"SERIAL-ADAPTER-ADDR"}},
+		asm.OUT{asm.ADDRESS_MODE, asm.REG2},
+		asm.DATA{asm.REG1, asm.NUMBER{0x800A}}, // newline with the ready bit
+
+		asm.DEFLABEL{"echo"},
+		asm.IN{asm.DATA_MODE, asm.REG3},
+		asm.AND{asm.REG3, asm.REG3},                // check if a byte has been received
+		asm.JMPF{[]string{"Z"}, asm.LABEL{"echo"}}, // if not - keep polling
+		asm.OUT{asm.DATA_MODE, asm.REG3},           // otherwise send it back
+		asm.CMP{asm.REG3, asm.REG1},                // stop at the end of the line
+		asm.JMPF{[]string{"E"}, asm.LABEL{"echo-end"}},
 		asm.CLF{},
-		asm.JMP{asm.LABEL{"noop"}},
+		asm.JMP{asm.LABEL{"echo"}},
+
+		asm.DEFLABEL{"echo-end"},
+	)
+	instructions.AddBlocks(deselectIO(asm.REG2))
+
+	// exit code 0
+	instructions.Add(
+		asm.DATA{asm.REG0, asm.NUMBER{0x0000}},
+		asm.HALT{},
+
+		asm.DEFLABEL{"greeting"},
+		asm.STRING{"Hello from the simple computer, type a line and it will be sent back\n", false},
 	)
 
 	fmt.Println(instructions.String())
@@ -168,8 +219,6 @@ func routine_drawBrush(labelPrefix string) []asm.Instruction {
 	instructions.Add(asm.DEFLABEL{labelPrefix})
 
 	instructions.Add(
-		asm.DATA{asm.REG2, asm.SYMBOL{"CALL-RETURN-ADDRESS"}},
-		asm.STORE{asm.REG2, asm.REG3},
 		asm.DATA{asm.REG2, asm.SYMBOL{"PEN-POSITION-ADDR"}},
 		asm.LOAD{asm.REG2, asm.REG2},
 	)
@@ -302,9 +351,7 @@ func routine_drawBrush(labelPrefix string) []asm.Instruction {
 	// return to callee
 	instructions.Add(
 		asm.CLF{},
-		asm.DATA{asm.REG3, asm.SYMBOL{"CALL-RETURN-ADDRESS"}},
-		asm.LOAD{asm.REG3, asm.REG3},
-		asm.JR{asm.REG3},
+		asm.RET{},
 	)
 
 	return instructions.Get()
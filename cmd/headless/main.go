@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	goio "io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/djhworld/simple-computer/asm"
+	"github.com/djhworld/simple-computer/capture"
+	"github.com/djhworld/simple-computer/computer"
+	"github.com/djhworld/simple-computer/debugger"
+	"github.com/djhworld/simple-computer/io"
+)
+
+var binFile = flag.String("bin", "/dev/stdin", "the bin file to load into the computer")
+var maxInstructions = flag.Int("instructions", 0, "number of instructions to execute (0 = no limit, requires -stop-at)")
+var stopAt = flag.String("stop-at", "", "stop when the instruction address register reaches this address (e.g. 0x0520)")
+var framesDir = flag.String("frames-dir", "", "directory to write frames to in -frame-format (default: frames are discarded)")
+var frameFormat = flag.String("frame-format", "pbm", "the format of the frames, pbm is black and white while ppm and png have the colours of the palette")
+var screenshot = flag.String("screenshot", "", "write the final frame to this PNG file")
+var record = flag.String("record", "", "record the frames to this animated GIF (if it ends in .gif) or directory of numbered PNGs")
+var recordEvery = flag.Int("record-every", 1, "record every Nth frame rendered with -record")
+var frameEvery = flag.Int("frame-every", 0, "render a frame every N instructions (0 = only render the final frame)")
+var keysFile = flag.String("keys", "", "file of keys to press, one '<cycle> <keycode>' pair per line in increasing cycles, a shorter form of -key-script. The cycles are cycles of the CPU, they used to be counts of instructions")
+var printState = flag.Bool("print-state", false, "print the computer state to stdout")
+var printStateSampleSize = flag.Int("print-state-every", 512, "how often in steps to print the computer state. lower will decrease performance.")
+var debug = flag.Bool("debug", false, "start the computer paused with a debugger reading commands from stdin")
+var mapFile = flag.String("map", "", "debug info written by the assembler (-m), used to show labels and source lines in state dumps and the debugger")
+var cpuModel = flag.String("cpu", "gates", "the CPU model to run, gates simulates every gate of the CPU, behavioural runs the same instructions much faster")
+var loadSnapshot = flag.String("load-snapshot", "", "carry on from a snapshot saved with -save-snapshot instead of loading -bin, -cpu must be the model and -key-script (or -keys) the script the snapshot was taken with")
+var saveSnapshot = flag.String("save-snapshot", "", "save a snapshot of the computer to this file when the run ends")
+var serial = flag.String("serial", "stdio", "where the serial port goes, stdio sends to stdout and receives from stdin (not with -debug), none disconnects it, or a file or pty")
+var keyScript = flag.String("key-script", "", "replay a script of keys to type and waits in cycles or wall time to the keyboard, see io.KeyScript")
+var diskImage = flag.String("disk", "", "the image file of the disk, it is created if it does not exist (default: no disk)")
+
+func exitWithError(message string, err error, exitCode int) {
+	fmt.Fprintln(os.Stderr, message, err)
+	os.Exit(exitCode)
+}
+
+func main() {
+	flag.Parse()
+
+	if *maxInstructions <= 0 && *stopAt == "" && !*debug {
+		fmt.Fprintln(os.Stderr, "one of -instructions, -stop-at or -debug must be provided, otherwise the computer will run forever")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	model, err := computer.ParseCPUModel(*cpuModel)
+	if err != nil {
+		exitWithError("invalid -cpu", err, 2)
+	}
+
+	if *frameFormat != "pbm" && *frameFormat != "ppm" && *frameFormat != "png" {
+		exitWithError("invalid -frame-format", fmt.Errorf("unknown format '%s', must be pbm, ppm or png", *frameFormat), 2)
+	}
+
+	config := computer.HeadlessConfig{
+		MaxInstructions:  *maxInstructions,
+		FrameEvery:       *frameEvery,
+		PrintStateConfig: computer.PrintStateConfig{*printState, *printStateSampleSize},
+	}
+
+	if *stopAt != "" {
+		address, err := strconv.ParseUint(*stopAt, 0, 16)
+		if err != nil {
+			exitWithError("invalid -stop-at address", err, 2)
+		}
+		config.HaltWhen = func(c *computer.SimpleComputer) bool {
+			return c.IAR() == uint16(address)
+		}
+	}
+
+	if *framesDir != "" {
+		if err := os.MkdirAll(*framesDir, 0755); err != nil {
+			exitWithError("error creating frames directory", err, 5)
+		}
+	}
+
+	var recorder *capture.Recorder
+	if *record != "" {
+		// played back at the rate the simulator draws frames
+		if recorder, err = capture.NewRecorder(*record, *recordEvery, 33*time.Millisecond); err != nil {
+			exitWithError("error starting recording", err, 5)
+		}
+	}
+
+	var screenChannel chan *io.Frame
+	done := make(chan bool)
+	if *framesDir != "" || recorder != nil || *screenshot != "" {
+		screenChannel = make(chan *io.Frame)
+		go writeFrames(screenChannel, *framesDir, *frameFormat, recorder, done)
+	}
+
+	comp := computer.NewComputer(screenChannel, make(chan bool), model)
+	if *diskImage != "" {
+		disk, err := io.OpenDisk(*diskImage)
+		if err != nil {
+			exitWithError("error opening disk image", err, 5)
+		}
+		defer disk.Close()
+		comp.ConnectDisk(disk)
+	}
+
+	if *keysFile != "" && *keyScript != "" {
+		exitWithError("invalid flags", fmt.Errorf("-keys and -key-script can't be used together"), 2)
+	}
+	if *keysFile != "" {
+		script, err := readKeysFile(*keysFile)
+		if err != nil {
+			exitWithError("error reading -keys file", err, 5)
+		}
+		comp.ConnectScriptedKeyboard(io.NewScriptedKeyboard(script))
+	}
+	if *keyScript != "" {
+		script, err := io.ReadKeyScript(*keyScript)
+		if err != nil {
+			exitWithError("error reading -key-script file", err, 5)
+		}
+		comp.ConnectScriptedKeyboard(io.NewScriptedKeyboard(script))
+	}
+
+	// after the key script is connected, a snapshot carries on from where the script was
+	if *loadSnapshot != "" {
+		if err := comp.LoadFile(*loadSnapshot); err != nil {
+			exitWithError("error loading snapshot", err, 5)
+		}
+	} else {
+		bin, err := computer.ReadBinFile(*binFile)
+		if err != nil {
+			exitWithError("error attempting to parse bin file", err, 5)
+		}
+		comp.LoadToRAM(computer.CODE_REGION_START, bin)
+	}
+
+	port, err := serialPort()
+	if err != nil {
+		exitWithError("error opening serial port", err, 5)
+	}
+	if port != nil {
+		comp.ConnectSerialPort(port)
+		go port.Run()
+	}
+
+	if *mapFile != "" {
+		debugInfo, err := asm.ReadDebugInfoFile(*mapFile)
+		if err != nil {
+			exitWithError("error reading map file", err, 5)
+		}
+		comp.SetDebugInfo(debugInfo)
+	}
+
+	if *debug {
+		comp.SetStepHook(debugger.NewDebugger(os.Stdin, os.Stdout))
+	}
+
+	executed := comp.RunHeadless(config)
+	if screenChannel != nil {
+		close(screenChannel)
+		<-done
+	}
+
+	log.Printf("Executed %d instructions, IAR = %s", executed, comp.Describe(comp.IAR()))
+
+	if *saveSnapshot != "" {
+		if err := comp.SaveFile(*saveSnapshot); err != nil {
+			exitWithError("error saving snapshot", err, 5)
+		}
+		log.Printf("Saved snapshot to %s", *saveSnapshot)
+	}
+
+	if comp.Halted() {
+		os.Exit(comp.ExitCode() & 0xFF)
+	}
+}
+
+// serialPort opens the port given with -serial, nil if it is not connected
+func serialPort() (*io.SerialPort, error) {
+	switch *serial {
+	case "none":
+		return nil, nil
+	case "stdio":
+		// the debugger reads its commands from stdin
+		if *debug {
+			return io.NewSerialPort(nil, os.Stdout, nil), nil
+		}
+		return io.NewSerialPort(os.Stdin, os.Stdout, nil), nil
+	}
+	return io.OpenSerialPort(*serial, nil)
+}
+
+// writeFrames writes every frame received to a numbered file in dir if it is set and records it if there is
+// a recorder, the last frame is written to -screenshot
+func writeFrames(screenChannel chan *io.Frame, dir string, format string, recorder *capture.Recorder, done chan bool) {
+	write := writePBM
+	switch format {
+	case "ppm":
+		write = writePPM
+	case "png":
+		write = capture.SavePNG
+	}
+
+	var last *io.Frame
+	frameNo := 0
+	for frame := range screenChannel {
+		if dir != "" {
+			filename := filepath.Join(dir, fmt.Sprintf("frame-%06d.%s", frameNo, format))
+			if err := write(filename, frame); err != nil {
+				log.Println("error writing frame", filename, err)
+			}
+		}
+		if recorder != nil {
+			if err := recorder.Record(frame); err != nil {
+				log.Println("error recording frame", frameNo, err)
+			}
+		}
+		last = frame
+		frameNo++
+	}
+
+	if recorder != nil {
+		if err := recorder.Close(); err != nil {
+			log.Println("error writing recording", *record, err)
+		}
+		log.Printf("Recorded %d frames to %s", recorder.Recorded(), *record)
+	}
+	if *screenshot != "" && last != nil {
+		if err := capture.SavePNG(*screenshot, last); err != nil {
+			log.Println("error writing screenshot", *screenshot, err)
+		}
+	}
+	done <- true
+}
+
+// writePBM writes pixels that are not colour 0 of the palette as black
+func writePBM(filename string, frame *io.Frame) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "P1\n%d %d\n", len(frame.Pixels[0]), len(frame.Pixels))
+	for y := range frame.Pixels {
+		for x := range frame.Pixels[y] {
+			if x > 0 {
+				w.WriteByte(' ')
+			}
+			if frame.Pixels[y][x] > 0 {
+				w.WriteByte('1')
+			} else {
+				w.WriteByte('0')
+			}
+		}
+		w.WriteByte('\n')
+	}
+	return w.Flush()
+}
+
+// writePPM writes every pixel in its colour of the palette
+func writePPM(filename string, frame *io.Frame) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "P6\n%d %d\n255\n", len(frame.Pixels[0]), len(frame.Pixels))
+	for y := range frame.Pixels {
+		for x := range frame.Pixels[y] {
+			colour := frame.Palette[frame.Pixels[y][x]]
+			w.Write([]byte{colour.R, colour.G, colour.B})
+		}
+	}
+	return w.Flush()
+}
+
+// readKeysFile parses a file of '<cycle> <keycode>' lines into a key script that presses each key once the CPU
+// has run that many cycles, blank lines and lines starting with # are ignored. Every key needs a later cycle than
+// the one before it, the keyboard adapter only holds the last key pressed. Key codes are GLFW key codes
+// (e.g. 65 for 'A', 257 for enter) in decimal or hex
+func readKeysFile(filename string) (*io.KeyScript, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseKeysFile(f)
+}
+
+func parseKeysFile(reader goio.Reader) (*io.KeyScript, error) {
+	script := new(io.KeyScript)
+	scanner := bufio.NewScanner(reader)
+	lineNo := 0
+	// the cycle of the key before, -1 until there is one, and the cycle the script waits up to
+	last, waitedTo := -1, 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected '<cycle> <keycode>' but got '%s'", lineNo, line)
+		}
+
+		cycle, err := strconv.Atoi(fields[0])
+		if err != nil || cycle < 0 {
+			return nil, fmt.Errorf("line %d: invalid cycle '%s'", lineNo, fields[0])
+		}
+		if cycle <= last {
+			return nil, fmt.Errorf("line %d: cycle %d is not after the cycle of the key before it, %d", lineNo, cycle, last)
+		}
+
+		keycode, err := strconv.ParseUint(fields[1], 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid keycode '%s'", lineNo, fields[1])
+		}
+
+		if cycle > waitedTo {
+			script.Wait(cycle - waitedTo)
+			waitedTo = cycle
+		}
+		script.Press(int(keycode), true)
+		last = cycle
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/djhworld/simple-computer/components"
+	"github.com/djhworld/simple-computer/io"
+)
+
+func TestParseKeysFile(t *testing.T) {
+	pressA := new(io.KeyScript)
+	pressA.Wait(100)
+	pressA.Press(65, true)
+
+	typeABThenEnter := new(io.KeyScript)
+	typeABThenEnter.Wait(100)
+	typeABThenEnter.Press(0x41, true)
+	typeABThenEnter.Wait(1)
+	typeABThenEnter.Press(0x42, true)
+	typeABThenEnter.Wait(99)
+	typeABThenEnter.Press(257, true)
+
+	atTheStart := new(io.KeyScript)
+	atTheStart.Press(32, true)
+
+	expected := map[string]*io.KeyScript{
+		"100 65": pressA,
+		"# type AB then enter\n\n100 0x41\n  101 66  \n200\t0x101\n": typeABThenEnter,
+		"0 32": atTheStart,
+		"":     new(io.KeyScript),
+	}
+
+	for keys, script := range expected {
+		actual, err := parseKeysFile(strings.NewReader(keys))
+		if err != nil {
+			t.Logf("%q: error parsing keys: %v", keys, err)
+			t.FailNow()
+		}
+		if !reflect.DeepEqual(actual, script) {
+			t.Logf("%q: expected %+v but got %+v", keys, script, actual)
+			t.FailNow()
+		}
+	}
+}
+
+func TestKeysFilePressesEachKeyAtItsCycle(t *testing.T) {
+	script, err := parseKeysFile(strings.NewReader("2 65\n3 66\n6 67"))
+	if err != nil {
+		t.Logf("Error parsing keys: %v", err)
+		t.FailNow()
+	}
+
+	bus := components.NewBus(io.BUS_WIDTH)
+	keyboard := io.NewScriptedKeyboard(script)
+	keyboard.ConnectTo(bus)
+
+	// the value on the bus after every cycle, the program reads a key by clearing the bus
+	expected := []uint16{0, 0, 'A', 'B', 0, 0, 'C', 0}
+	for cycle, key := range expected {
+		keyboard.Tick()
+		if bus.Value() != key {
+			t.Logf("Expected 0x%04X on the bus at cycle %d but got 0x%04X", key, cycle, bus.Value())
+			t.FailNow()
+		}
+		bus.SetValue(0)
+	}
+}
+
+func TestParseKeysFileErrors(t *testing.T) {
+	expected := map[string]string{
+		"100":                  "line 1: expected '<cycle> <keycode>' but got '100'",
+		"100 65 66":            "line 1: expected '<cycle> <keycode>' but got '100 65 66'",
+		"# a comment\nsoon 65": "line 2: invalid cycle 'soon'",
+		"-5 65":                "line 1: invalid cycle '-5'",
+		"100 0x10000":          "line 1: invalid keycode '0x10000'",
+		"100 enter":            "line 1: invalid keycode 'enter'",
+		"100 65 # a":           "line 1: expected '<cycle> <keycode>' but got '100 65 # a'",
+		"100 65\n100 66":       "line 2: cycle 100 is not after the cycle of the key before it, 100",
+		"0 65\n\n0 66":         "line 3: cycle 0 is not after the cycle of the key before it, 0",
+		"200 65\n100 66":       "line 2: cycle 100 is not after the cycle of the key before it, 200",
+	}
+
+	for keys, message := range expected {
+		_, err := parseKeysFile(strings.NewReader(keys))
+		if err == nil || err.Error() != message {
+			t.Logf("%q: expected the error %q but got %v", keys, message, err)
+			t.FailNow()
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"sync"
 	"time"
 
 	"github.com/djhworld/simple-computer/io"
@@ -9,39 +10,113 @@ import (
 	"github.com/go-gl/glfw/v3.2/glfw"
 )
 
+// the key that saves a snapshot when -save-snapshot is set
+const SNAPSHOT_KEY = glfw.KeyF12
+
+// the key that saves the frame on the screen to a PNG in -screenshot-dir
+const SCREENSHOT_KEY = glfw.KeyF11
+
+// the keys that control the clock
+const (
+	PAUSE_KEY       = glfw.KeyF5
+	SINGLE_STEP_KEY = glfw.KeyF6
+	SLOWER_KEY      = glfw.KeyF7
+	FASTER_KEY      = glfw.KeyF8
+	UNTHROTTLED_KEY = glfw.KeyF9
+	// runs unthrottled while held
+	TURBO_KEY = glfw.KeyF10
+)
+
+// how often the window title is updated with the status
+const STATUS_INTERVAL = time.Second
+
 // GlfwIO is for running the system using GLFW.
 // libglfw3 will be required on the system
 type GlfwIO struct {
 	glfwDisplay     *glfwDisplay
-	screenChannel   chan *[160][240]byte
+	screenChannel   chan *io.Frame
 	keyPressChannel chan *io.KeyPress
 	quitChannel     chan bool
+	quitOnce        sync.Once
+
+	// keys handled by the simulator itself, they are not passed on to the computer
+	keyHandlers map[glfw.Key]func(action glfw.Action)
+
+	// called with every frame drawn
+	frameHandlers []func(frame *io.Frame)
+
+	title  string
+	status func() string
 }
 
-func NewGlfwIO(screenChannel chan *[160][240]byte, keyPressChannel chan *io.KeyPress, quitChannel chan bool) *GlfwIO {
+func NewGlfwIO(screenChannel chan *io.Frame, keyPressChannel chan *io.KeyPress, quitChannel chan bool) *GlfwIO {
 	log.Println("Creating GLFW based IO Handler")
-	glfwDisplay := newGlfwDisplay(func() {
-		close(quitChannel)
+	i := new(GlfwIO)
+	i.glfwDisplay = newGlfwDisplay(i.Quit)
+	i.screenChannel = screenChannel
+	i.keyPressChannel = keyPressChannel
+	i.quitChannel = quitChannel
+	i.keyHandlers = make(map[glfw.Key]func(action glfw.Action))
+	return i
+}
+
+// Quit closes the window and stops everything listening on the quit channel, the same as
+// closing the window. It can be called more than once and from any goroutine
+func (i *GlfwIO) Quit() {
+	i.quitOnce.Do(func() {
+		close(i.quitChannel)
 	})
+}
 
-	return &GlfwIO{
-		glfwDisplay,
-		screenChannel,
-		keyPressChannel,
-		quitChannel,
+// HandleKey calls handler whenever key is pressed instead of passing the key on to the computer,
+// it is called on the main thread so it must not block
+func (i *GlfwIO) HandleKey(key glfw.Key, handler func()) {
+	i.keyHandlers[key] = func(action glfw.Action) {
+		if action == glfw.Press {
+			handler()
+		}
 	}
 }
 
+// HandleKeyHold is like HandleKey but handler is called with true when key is pressed and
+// false when it is released
+func (i *GlfwIO) HandleKeyHold(key glfw.Key, handler func(down bool)) {
+	i.keyHandlers[key] = func(action glfw.Action) {
+		if action != glfw.Repeat {
+			handler(action == glfw.Press)
+		}
+	}
+}
+
+// OnFrame calls handler with every frame after it is drawn, it is called on the main thread so it must not block.
+// The frame is only valid until handler returns
+func (i *GlfwIO) OnFrame(handler func(frame *io.Frame)) {
+	i.frameHandlers = append(i.frameHandlers, handler)
+}
+
+// SetStatus shows the result of status after the title of the window, it is updated every STATUS_INTERVAL
+func (i *GlfwIO) SetStatus(status func() string) {
+	i.status = status
+}
+
 func (i *GlfwIO) Run() {
 	clock := time.Tick(33 * time.Millisecond)
+	statusClock := time.Tick(STATUS_INTERVAL)
 	for {
 		<-clock
 		select {
 		case <-i.quitChannel:
 			i.glfwDisplay.Destroy()
 			return
+		case <-statusClock:
+			if i.status != nil {
+				i.glfwDisplay.window.SetTitle(i.title + " - " + i.status())
+			}
 		case frame := <-i.screenChannel:
 			i.glfwDisplay.DrawFrame(frame)
+			for _, handler := range i.frameHandlers {
+				handler(frame)
+			}
 		}
 	}
 }
@@ -53,8 +128,14 @@ func (i *GlfwIO) Init(title string) error {
 	if err != nil {
 		return err
 	}
+	i.title = title
 
 	i.glfwDisplay.window.SetKeyCallback(func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+		if handler, ok := i.keyHandlers[key]; ok {
+			handler(action)
+			return
+		}
+
 		if action == glfw.Repeat {
 			i.keyPressChannel <- &down_key_presses[int(key)]
 			return
@@ -126,7 +207,7 @@ func (s *glfwDisplay) Destroy() {
 	glfw.Terminate()
 }
 
-func (s *glfwDisplay) DrawFrame(screenData *[160][240]byte) {
+func (s *glfwDisplay) DrawFrame(frame *io.Frame) {
 	fw, fh := s.window.GetFramebufferSize()
 	gl.Viewport(0, 0, int32(fw), int32(fh))
 	gl.MatrixMode(gl.PROJECTION)
@@ -143,14 +224,9 @@ func (s *glfwDisplay) DrawFrame(screenData *[160][240]byte) {
 	gl.Begin(gl.POINTS)
 	for y := 0; y < 160; y++ {
 		for x := 0; x < 240; x++ {
-			var pixel byte = screenData[y][x]
-			if pixel > 0 {
-				gl.Color3ub(220, 220, 220)
-				gl.Vertex2i(int32(x), int32(y))
-			} else {
-				gl.Color3ub(50, 50, 50)
-				gl.Vertex2i(int32(x), int32(y))
-			}
+			colour := frame.Palette[frame.Pixels[y][x]]
+			gl.Color3ub(colour.R, colour.G, colour.B)
+			gl.Vertex2i(int32(x), int32(y))
 		}
 	}
 
@@ -1,18 +1,20 @@
 package main
 
 import (
-	"encoding/binary"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
 
-	goio "io"
-
+	"github.com/djhworld/simple-computer/asm"
+	"github.com/djhworld/simple-computer/capture"
+	"github.com/djhworld/simple-computer/clock"
 	"github.com/djhworld/simple-computer/computer"
+	"github.com/djhworld/simple-computer/debugger"
 	"github.com/djhworld/simple-computer/io"
 )
 
@@ -25,24 +27,55 @@ func init() {
 var binFile = flag.String("bin", "/dev/stdin", "the bin file to load into the computer")
 var printState = flag.Bool("print-state", false, "print the computer state to stdout")
 var printStateSampleSize = flag.Int("print-state-every", 512, "how often in steps to print the computer state. lower will decrease performance.")
+var debug = flag.Bool("debug", false, "start the computer paused with a debugger reading commands from stdin")
+var mapFile = flag.String("map", "", "debug info written by the assembler (-m), used to show labels and source lines in state dumps and the debugger")
+var cpuModel = flag.String("cpu", "gates", "the CPU model to run, gates simulates every gate of the CPU, behavioural runs the same instructions much faster")
+var loadSnapshot = flag.String("load-snapshot", "", "carry on from a snapshot saved with -save-snapshot instead of loading -bin, -cpu must be the model and -key-script the script the snapshot was taken with")
+var saveSnapshot = flag.String("save-snapshot", "", "save a snapshot of the computer to this file when the simulator exits and whenever F12 is pressed")
+var hz = flag.Float64("hz", 0, "the clock frequency in steps of the stepper a second, 0 runs as fast as the CPU can go. F7 and F8 halve and double it while running, F9 goes back to unthrottled and F10 runs unthrottled while held")
+var paused = flag.Bool("paused", false, "start with the clock paused, F5 pauses and resumes the clock and F6 runs a single step while it is paused")
+var serial = flag.String("serial", "stdio", "where the serial port goes, stdio sends to stdout and receives from stdin (not with -debug), none disconnects it, or a file or pty")
+var keyScript = flag.String("key-script", "", "replay a script of keys to type and waits in cycles or wall time to the keyboard, see io.KeyScript")
+var diskImage = flag.String("disk", "", "the image file of the disk, it is created if it does not exist (default: no disk)")
+var screenshotDir = flag.String("screenshot-dir", ".", "the directory F11 saves screenshots of the screen to as PNGs")
+var record = flag.String("record", "", "record the frames drawn to this animated GIF (if it ends in .gif) or directory of numbered PNGs, the GIF is written when the simulator exits")
+var recordEvery = flag.Int("record-every", 1, "record every Nth frame drawn with -record, frames are drawn every 33ms")
+var exitOnHalt = flag.Bool("exit-on-halt", false, "exit when the program halts instead of keeping its last frame on the screen until the window is closed")
 
 func main() {
 	flag.Parse()
 	fmt.Println("\nDaniel's Simple Computer (based on the Scott CPU)")
 	fmt.Println(strings.Repeat("-", 80))
 
-	bin, err := read(*binFile)
+	var bin []uint16
+	if *loadSnapshot == "" {
+		var err error
+		if bin, err = computer.ReadBinFile(*binFile); err != nil {
+			fmt.Fprintln(os.Stderr, "error attempting to parse bin file", err)
+			os.Exit(5)
+		}
+	}
+
+	model, err := computer.ParseCPUModel(*cpuModel)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "error attempting to parse bin file", err)
-		os.Exit(5)
+		fmt.Fprintln(os.Stderr, "invalid -cpu", err)
+		os.Exit(2)
+	}
+
+	var debugInfo *asm.DebugInfo
+	if *mapFile != "" {
+		if debugInfo, err = asm.ReadDebugInfoFile(*mapFile); err != nil {
+			fmt.Fprintln(os.Stderr, "error attempting to read map file", err)
+			os.Exit(5)
+		}
 	}
 
-	run(bin)
+	run(bin, debugInfo, model)
 }
 
-func run(bin []uint16) {
+func run(bin []uint16, debugInfo *asm.DebugInfo, model computer.CPUModel) {
 	keyPressChannel := make(chan *io.KeyPress)
-	screenChannel := make(chan *[160][240]byte)
+	screenChannel := make(chan *io.Frame)
 	quitChannel := make(chan bool, 10)
 
 	glfw := NewGlfwIO(screenChannel, keyPressChannel, quitChannel)
@@ -51,49 +84,169 @@ func run(bin []uint16) {
 		os.Exit(5)
 	}
 
-	comp := computer.NewComputer(screenChannel, quitChannel)
+	comp := computer.NewComputer(screenChannel, quitChannel, model)
 	keyboard := io.NewKeyboard(keyPressChannel, quitChannel)
 	comp.ConnectKeyboard(keyboard)
-	comp.LoadToRAM(0x0500, bin)
+	if *keyScript != "" {
+		script, err := io.ReadKeyScript(*keyScript)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error reading key script", err)
+			os.Exit(5)
+		}
+		comp.ConnectScriptedKeyboard(io.NewScriptedKeyboard(script))
+	}
+	if *diskImage != "" {
+		disk, err := io.OpenDisk(*diskImage)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error opening disk image", err)
+			os.Exit(5)
+		}
+		comp.ConnectDisk(disk)
+	}
+	port, err := serialPort(quitChannel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error opening serial port", err)
+		os.Exit(5)
+	}
+	if port != nil {
+		comp.ConnectSerialPort(port)
+	}
+	if *loadSnapshot != "" {
+		if err := comp.LoadFile(*loadSnapshot); err != nil {
+			fmt.Fprintln(os.Stderr, "error loading snapshot", err)
+			os.Exit(5)
+		}
+	} else {
+		comp.LoadToRAM(computer.CODE_REGION_START, bin)
+	}
+	comp.SetDebugInfo(debugInfo)
+	if *debug {
+		comp.SetStepHook(debugger.NewDebugger(os.Stdin, os.Stdout))
+	}
+
+	// closed when the program halts, the screen keeps showing the last frame
+	var exitCode int
+	halted := make(chan bool)
+
+	if *saveSnapshot != "" {
+		glfw.HandleKey(SNAPSHOT_KEY, func() {
+			go logSnapshot(comp.Snapshot(*saveSnapshot))
+		})
+	}
+
+	computerClock := newClock(glfw)
+	recorder := startCapture(glfw)
 
 	go keyboard.Run()
-	go comp.Run(time.Tick(1*time.Nanosecond), computer.PrintStateConfig{*printState, *printStateSampleSize})
+	if port != nil {
+		go port.Run()
+	}
+	go func() {
+		exitCode = comp.Run(computerClock, computer.PrintStateConfig{*printState, *printStateSampleSize})
+		close(halted)
+		if *exitOnHalt {
+			glfw.Quit()
+		}
+	}()
 
 	glfw.Run()
-}
 
-func read(filename string) ([]uint16, error) {
-	var reader goio.ReadCloser
-	if r, err := os.Open(filename); err != nil {
-		return nil, err
-	} else {
-		reader = r
+	if recorder != nil {
+		if err := recorder.Close(); err != nil {
+			log.Println("error writing recording", err)
+		} else {
+			log.Printf("Recorded %d frames to %s", recorder.Recorded(), *record)
+		}
 	}
-	defer reader.Close()
 
-	size, err := countUint16s(filename)
-	if err != nil {
-		return nil, err
+	if *saveSnapshot != "" {
+		logSnapshot(comp.Snapshot(*saveSnapshot))
 	}
 
-	instructions := make([]uint16, size)
-	if err := binary.Read(reader, binary.LittleEndian, &instructions); err != nil {
-		return nil, err
+	select {
+	case <-halted:
+		os.Exit(exitCode & 0xFF)
+	default:
 	}
+}
 
-	return instructions, nil
+// serialPort opens the port given with -serial, nil if it is not connected
+func serialPort(quitChannel chan bool) (*io.SerialPort, error) {
+	switch *serial {
+	case "none":
+		return nil, nil
+	case "stdio":
+		// the debugger reads its commands from stdin
+		if *debug {
+			return io.NewSerialPort(nil, os.Stdout, quitChannel), nil
+		}
+		return io.NewSerialPort(os.Stdin, os.Stdout, quitChannel), nil
+	}
+	return io.OpenSerialPort(*serial, quitChannel)
 }
 
-func countUint16s(filename string) (int64, error) {
-	if stat, err := os.Stat(filename); err != nil {
-		return -1, err
-	} else {
-		filesize := stat.Size()
-		if filesize%2 != 0 {
-			return -1, fmt.Errorf("size of file '%s' is not an even number (bytes = %d)", filename, filesize)
+// newClock creates the clock from the command line and lets the keys adjust it while running,
+// the window title shows the clock frequency
+func newClock(glfw *GlfwIO) *clock.Clock {
+	c := clock.NewClock(*hz)
+	if *paused {
+		c.Pause()
+	}
+
+	glfw.HandleKey(PAUSE_KEY, c.TogglePause)
+	glfw.HandleKey(SINGLE_STEP_KEY, func() {
+		c.Step(1)
+	})
+	glfw.HandleKey(SLOWER_KEY, func() {
+		c.Scale(0.5)
+	})
+	glfw.HandleKey(FASTER_KEY, func() {
+		c.Scale(2)
+	})
+	glfw.HandleKey(UNTHROTTLED_KEY, func() {
+		c.SetHz(0)
+	})
+	glfw.HandleKeyHold(TURBO_KEY, c.SetTurbo)
+	glfw.SetStatus(c.String)
+	return c
+}
+
+// startCapture lets F11 save screenshots and records the frames to -record, the recorder has to be closed when
+// the simulator exits
+func startCapture(glfw *GlfwIO) *capture.Recorder {
+	var lastFrame io.Frame
+	glfw.OnFrame(func(frame *io.Frame) {
+		lastFrame = *frame
+	})
+	glfw.HandleKey(SCREENSHOT_KEY, func() {
+		filename := filepath.Join(*screenshotDir, fmt.Sprintf("screenshot-%s.png", time.Now().Format("20060102-150405.000")))
+		if err := capture.SavePNG(filename, &lastFrame); err != nil {
+			log.Println("error saving screenshot", err)
+			return
 		}
-		uint16s := filesize / 2
-		log.Println("Size of", filename, "is", uint16s, "unsigned 16-bit integers")
-		return uint16s, nil
+		log.Printf("Saved screenshot to %s", filename)
+	})
+
+	if *record == "" {
+		return nil
+	}
+	recorder, err := capture.NewRecorder(*record, *recordEvery, 33*time.Millisecond)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error starting recording", err)
+		os.Exit(5)
+	}
+	glfw.OnFrame(func(frame *io.Frame) {
+		if err := recorder.Record(frame); err != nil {
+			log.Println("error recording frame", err)
+		}
+	})
+	return recorder
+}
+
+func logSnapshot(err error) {
+	if err != nil {
+		log.Println("error saving snapshot", err)
+		return
 	}
+	log.Printf("Saved snapshot to %s", *saveSnapshot)
 }
@@ -1,6 +1,10 @@
 package components
 
-import "github.com/djhworld/simple-computer/circuit"
+import (
+	"fmt"
+
+	"github.com/djhworld/simple-computer/circuit"
+)
 
 type ORGate3 struct {
 	inputA circuit.Wire
@@ -165,6 +169,46 @@ func (g *ORGate6) Output() bool {
 	return g.output.Get()
 }
 
+// ORGateN is a chain of 2 input OR gates for when a control wire is fed by more
+// inputs than the fixed size gates allow
+type ORGateN struct {
+	inputs int
+	ors    []circuit.ORGate
+	output circuit.Wire
+}
+
+func NewORGateN(inputs int) *ORGateN {
+	if inputs < 2 {
+		panic("ORGateN needs at least 2 inputs")
+	}
+
+	a := new(ORGateN)
+	a.inputs = inputs
+	a.output = *circuit.NewWire("o", false)
+	a.ors = make([]circuit.ORGate, inputs-1)
+	for i := range a.ors {
+		a.ors[i] = *circuit.NewORGate()
+	}
+
+	return a
+}
+
+func (g *ORGateN) Update(inputs ...bool) {
+	if len(inputs) != g.inputs {
+		panic(fmt.Sprintf("ORGateN expected %d inputs but got %d", g.inputs, len(inputs)))
+	}
+
+	g.ors[0].Update(inputs[0], inputs[1])
+	for i := 1; i < len(g.ors); i++ {
+		g.ors[i].Update(g.ors[i-1].Output(), inputs[i+1])
+	}
+	g.output.Update(g.ors[len(g.ors)-1].Output())
+}
+
+func (g *ORGateN) Output() bool {
+	return g.output.Get()
+}
+
 type ANDGate8 struct {
 	andA   circuit.ANDGate
 	andB   circuit.ANDGate
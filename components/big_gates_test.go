@@ -586,3 +586,22 @@ func TestORGate6(t *testing.T) {
 		}
 	}
 }
+
+func TestORGateN(t *testing.T) {
+	for inputs := 2; inputs <= 10; inputs++ {
+		gate := NewORGateN(inputs)
+		for combination := 0; combination < 1<<uint(inputs); combination++ {
+			values := make([]bool, inputs)
+			for i := range values {
+				values[i] = combination&(1<<uint(i)) != 0
+			}
+
+			gate.Update(values...)
+
+			if gate.Output() != (combination != 0) {
+				t.Logf("Expected %d input OR gate to be %v for %v", inputs, combination != 0, values)
+				t.FailNow()
+			}
+		}
+	}
+}
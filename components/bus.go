@@ -43,6 +43,18 @@ func (b *Bus) SetValue(value uint16) {
 	}
 }
 
+// Value returns the bus as a number, wire 0 is the most significant bit
+func (b *Bus) Value() uint16 {
+	var value uint16
+	for i := 0; i < b.width; i++ {
+		value <<= 1
+		if b.GetOutputWire(i) {
+			value |= 1
+		}
+	}
+	return value
+}
+
 func (b *Bus) String() string {
 	result := ""
 	for i := 0; i < b.width; i++ {
@@ -596,3 +596,47 @@ func (b *BusOne) String() string {
 	}
 	return utils.ValueToString(output)
 }
+
+// BusMinusOne sits between bus 1 and the ALU. When enabled every output wire is on,
+// which is -1 in two's complement, so adding it to a value decrements that value
+type BusMinusOne struct {
+	inputBus  *Bus
+	outputBus *Bus
+	inputs    [BUS_WIDTH]circuit.Wire
+	minusOne  circuit.Wire
+	orGates   [BUS_WIDTH]circuit.ORGate
+	outputs   [BUS_WIDTH]circuit.Wire
+}
+
+func NewBusMinusOne(inputBus, outputBus *Bus) *BusMinusOne {
+	b := new(BusMinusOne)
+	b.inputBus = inputBus
+	b.outputBus = outputBus
+
+	for i, _ := range b.orGates {
+		b.orGates[i] = *circuit.NewORGate()
+	}
+
+	return b
+}
+
+func (b *BusMinusOne) GetOutputWire(index int) bool {
+	return b.outputs[index].Get()
+}
+
+func (b *BusMinusOne) Enable() {
+	b.minusOne.Update(true)
+}
+
+func (b *BusMinusOne) Disable() {
+	b.minusOne.Update(false)
+}
+
+func (b *BusMinusOne) Update() {
+	for i := BUS_WIDTH - 1; i >= 0; i-- {
+		b.inputs[i].Update(b.inputBus.GetOutputWire(i))
+		b.orGates[i].Update(b.inputs[i].Get(), b.minusOne.Get())
+		b.outputs[i].Update(b.orGates[i].Output())
+		b.outputBus.SetInputWire(i, b.outputs[i].Get())
+	}
+}
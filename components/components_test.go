@@ -527,3 +527,27 @@ func testBusOneReturnsCorrectResult(b *BusOne, inputBus, outputBus *Bus, input i
 		t.FailNow()
 	}
 }
+
+func TestBusMinusOne(t *testing.T) {
+	inputBus := NewBus(BUS_WIDTH)
+	outputBus := NewBus(BUS_WIDTH)
+	b := NewBusMinusOne(inputBus, outputBus)
+	for i := 0; i < 65536; i++ {
+		setWireOnComponent16(inputBus, i)
+
+		b.Disable()
+		b.Update()
+		if output := getValueOfOutput(outputBus, BUS_WIDTH); output != i {
+			t.Logf("Expected output to be 0x%X but got 0x%X", i, output)
+			t.FailNow()
+		}
+
+		//should always return 0xFFFF regardless of input
+		b.Enable()
+		b.Update()
+		if output := getValueOfOutput(outputBus, BUS_WIDTH); output != 0xFFFF {
+			t.Logf("Expected output to be 0xFFFF but got 0x%X", output)
+			t.FailNow()
+		}
+	}
+}
@@ -11,8 +11,17 @@ const (
 	DATA_OR_ADDRESS = 3
 )
 
+// the number of interrupt lines peripherals can raise
+const INTERRUPT_LINES = 8
+
+// Besides the clock and mode wires the IO bus carries an interrupt line for each
+// peripheral that can raise one, the interrupt request the interrupt controller sends to the
+// CPU and the acknowledge the CPU sends back when it starts handling the interrupt
 type IOBus struct {
-	wires [4]circuit.Wire
+	wires                [4]circuit.Wire
+	interruptLines       [INTERRUPT_LINES]circuit.Wire
+	interruptRequest     circuit.Wire
+	interruptAcknowledge circuit.Wire
 }
 
 func NewIOBus() *IOBus {
@@ -21,6 +30,11 @@ func NewIOBus() *IOBus {
 	b.wires[CLOCK_ENABLE] = *circuit.NewWire("", false)
 	b.wires[MODE] = *circuit.NewWire("", false)
 	b.wires[DATA_OR_ADDRESS] = *circuit.NewWire("", false)
+	for i := range b.interruptLines {
+		b.interruptLines[i] = *circuit.NewWire("", false)
+	}
+	b.interruptRequest = *circuit.NewWire("", false)
+	b.interruptAcknowledge = *circuit.NewWire("", false)
 	return b
 }
 
@@ -72,3 +86,27 @@ func (i *IOBus) Update(mode, dataOrAddress bool) {
 func (i *IOBus) GetOutputWire(index int) bool {
 	return i.wires[index].Get()
 }
+
+func (i *IOBus) UpdateInterruptLine(line int, value bool) {
+	i.interruptLines[line].Update(value)
+}
+
+func (i *IOBus) IsInterruptLineRaised(line int) bool {
+	return i.interruptLines[line].Get()
+}
+
+func (i *IOBus) UpdateInterruptRequest(value bool) {
+	i.interruptRequest.Update(value)
+}
+
+func (i *IOBus) IsInterruptRequested() bool {
+	return i.interruptRequest.Get()
+}
+
+func (i *IOBus) UpdateInterruptAcknowledge(value bool) {
+	i.interruptAcknowledge.Update(value)
+}
+
+func (i *IOBus) IsInterruptAcknowledged() bool {
+	return i.interruptAcknowledge.Get()
+}
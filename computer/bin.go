@@ -0,0 +1,46 @@
+package computer
+
+import (
+	"encoding/binary"
+	"fmt"
+	goio "io"
+	"log"
+	"os"
+)
+
+// ReadBinFile reads a little endian .bin file as produced by the assembler
+func ReadBinFile(filename string) ([]uint16, error) {
+	var reader goio.ReadCloser
+	if r, err := os.Open(filename); err != nil {
+		return nil, err
+	} else {
+		reader = r
+	}
+	defer reader.Close()
+
+	size, err := countUint16s(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	instructions := make([]uint16, size)
+	if err := binary.Read(reader, binary.LittleEndian, &instructions); err != nil {
+		return nil, err
+	}
+
+	return instructions, nil
+}
+
+func countUint16s(filename string) (int64, error) {
+	if stat, err := os.Stat(filename); err != nil {
+		return -1, err
+	} else {
+		filesize := stat.Size()
+		if filesize%2 != 0 {
+			return -1, fmt.Errorf("size of file '%s' is not an even number (bytes = %d)", filename, filesize)
+		}
+		uint16s := filesize / 2
+		log.Println("Size of", filename, "is", uint16s, "unsigned 16-bit integers")
+		return uint16s, nil
+	}
+}
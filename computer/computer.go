@@ -3,8 +3,11 @@ package computer
 import (
 	"fmt"
 	"log"
-	"time"
+	"sync"
 
+	"github.com/djhworld/simple-computer/asm"
+	"github.com/djhworld/simple-computer/behavioural"
+	"github.com/djhworld/simple-computer/clock"
 	"github.com/djhworld/simple-computer/components"
 	"github.com/djhworld/simple-computer/cpu"
 	"github.com/djhworld/simple-computer/io"
@@ -13,33 +16,106 @@ import (
 
 const CODE_REGION_START = uint16(0x0500)
 
+// the stack grows downwards from the end of the user code region, the first value pushed goes in 0xFEFD
+const STACK_START = uint16(0xFEFE)
+
+// CPUModel selects which model of the CPU the computer runs
+type CPUModel int
+
+const (
+	// simulates every gate of the CPU and memory, slow but shows how the hardware works
+	GATE_LEVEL_CPU CPUModel = iota
+	// runs an instruction at a time, see the behavioural package
+	BEHAVIOURAL_CPU
+)
+
+// ParseCPUModel returns the model for the name used on the command line, gates or behavioural
+func ParseCPUModel(name string) (CPUModel, error) {
+	switch name {
+	case "gates":
+		return GATE_LEVEL_CPU, nil
+	case "behavioural":
+		return BEHAVIOURAL_CPU, nil
+	}
+	return GATE_LEVEL_CPU, fmt.Errorf("unknown CPU model '%s', expected gates or behavioural", name)
+}
+
+// String returns the name of the model used on the command line
+func (m CPUModel) String() string {
+	if m == BEHAVIOURAL_CPU {
+		return "behavioural"
+	}
+	return "gates"
+}
+
+// every instruction goes through the 6 steps of the stepper, some go round a second time (see cpu.HasExtendedSteps)
+const STEPS_PER_INSTRUCTION = cpu.STEPS_PER_ROUND
+
 type PrintStateConfig struct {
 	PrintState      bool
 	PrintStateEvery int
 }
 
+// HeadlessConfig controls a run of the computer via RunHeadless
+type HeadlessConfig struct {
+	// stop after this many instructions, 0 means no limit
+	MaxInstructions int
+	// checked before every instruction, the run stops when it returns true
+	HaltWhen func(*SimpleComputer) bool
+	// render a frame every N instructions, 0 means only the final frame is rendered
+	FrameEvery int
+	PrintStateConfig
+}
+
+// StepHook is called by the computer before every step of the CPU and blocks until the step
+// should be taken, e.g. the debugger uses it to pause the computer
+type StepHook interface {
+	BeforeStep(c *SimpleComputer)
+}
+
 type SimpleComputer struct {
-	memory  *memory.Memory64K
-	cpu     *cpu.CPU
+	model   CPUModel
+	cpu     cpu.Processor
 	mainBus *components.Bus
 
 	displayAdapter  *io.DisplayAdapter
 	screenControl   *io.ScreenControl
 	keyboardAdapter *io.KeyboardAdapter
+	timer           *io.Timer
+	diskController  *io.DiskController
+	serialAdapter   *io.SerialAdapter
+	// nil unless a key script is replayed, see ConnectScriptedKeyboard
+	scriptedKeyboard *io.ScriptedKeyboard
 
-	screenChannel chan *[160][240]byte
+	// connected last so it sees the interrupt lines raised by the other peripherals
+	interruptController *io.InterruptController
+
+	screenChannel chan *io.Frame
 	quitChannel   chan bool
+
+	stepHook  StepHook
+	debugInfo *asm.DebugInfo
+
+	// set when a snapshot has been loaded, the computer carries on from it instead of booting
+	restored bool
+	// held while the CPU takes a step, a snapshot is taken between two steps
+	stepping sync.Mutex
 }
 
-func NewComputer(screenChannel chan *[160][240]byte, quitChannel chan bool) *SimpleComputer {
+func NewComputer(screenChannel chan *io.Frame, quitChannel chan bool, model CPUModel) *SimpleComputer {
 	c := new(SimpleComputer)
 
 	c.screenChannel = screenChannel
 	c.quitChannel = quitChannel
 
+	c.model = model
 	c.mainBus = components.NewBus(16)
-	c.memory = memory.NewMemory64K(c.mainBus)
-	c.cpu = cpu.NewCPU(c.mainBus, c.memory)
+	switch model {
+	case BEHAVIOURAL_CPU:
+		c.cpu = behavioural.NewCPU(c.mainBus)
+	default:
+		c.cpu = cpu.NewCPU(c.mainBus, memory.NewMemory64K(c.mainBus))
+	}
 
 	c.keyboardAdapter = io.NewKeyboardAdapter()
 	c.cpu.ConnectPeripheral(c.keyboardAdapter)
@@ -48,6 +124,18 @@ func NewComputer(screenChannel chan *[160][240]byte, quitChannel chan bool) *Sim
 	c.screenControl = io.NewScreenControl(c.displayAdapter, c.screenChannel, c.quitChannel)
 	c.cpu.ConnectPeripheral(c.displayAdapter)
 
+	c.timer = io.NewTimer()
+	c.cpu.ConnectPeripheral(c.timer)
+
+	c.diskController = io.NewDiskController()
+	c.cpu.ConnectPeripheral(c.diskController)
+
+	c.serialAdapter = io.NewSerialAdapter()
+	c.cpu.ConnectPeripheral(c.serialAdapter)
+
+	c.interruptController = io.NewInterruptController()
+	c.cpu.ConnectPeripheral(c.interruptController)
+
 	return c
 }
 
@@ -55,6 +143,35 @@ func (c *SimpleComputer) ConnectKeyboard(keyboard *io.Keyboard) {
 	keyboard.ConnectTo(c.keyboardAdapter.KeyboardInBus)
 }
 
+// ConnectScriptedKeyboard replays a key script into the keyboard adapter, it can be used with or
+// instead of a keyboard. The waits of the script count cycles of the CPU. To carry on from a snapshot
+// taken with a key script the same script has to be connected before the snapshot is loaded
+func (c *SimpleComputer) ConnectScriptedKeyboard(keyboard *io.ScriptedKeyboard) {
+	keyboard.ConnectTo(c.keyboardAdapter.KeyboardInBus)
+	c.cpu.ConnectPeripheral(keyboard)
+	c.scriptedKeyboard = keyboard
+}
+
+// ConnectDisk puts a disk in the disk controller, without one the disk reads as 0 and writes are lost
+func (c *SimpleComputer) ConnectDisk(disk *io.Disk) {
+	c.diskController.ConnectDisk(disk)
+}
+
+// ConnectSerialPort connects the host side of the serial adapter, without one the bytes sent are lost
+func (c *SimpleComputer) ConnectSerialPort(port *io.SerialPort) {
+	c.serialAdapter.ConnectPort(port)
+}
+
+// SetStepHook installs a hook that is called before every step, it runs after the clock has ticked
+func (c *SimpleComputer) SetStepHook(hook StepHook) {
+	c.stepHook = hook
+}
+
+// SetDebugInfo makes the state dumps show where the IAR is in the source of the program
+func (c *SimpleComputer) SetDebugInfo(debugInfo *asm.DebugInfo) {
+	c.debugInfo = debugInfo
+}
+
 func (c *SimpleComputer) LoadToRAM(offset uint16, values []uint16) {
 	if offset < 0x0500 {
 		panic("0x0000 - 0x04FF is a reserved memory area")
@@ -70,48 +187,173 @@ func (c *SimpleComputer) LoadToRAM(offset uint16, values []uint16) {
 }
 
 func (c *SimpleComputer) loadToRAM(addr uint16, value uint16) {
-	c.putValueInRAM(addr, value)
+	c.cpu.WriteMemory(addr, value)
 }
 
-func (c *SimpleComputer) putValueInRAM(address, value uint16) {
-	c.memory.AddressRegister.Set()
-	c.mainBus.SetValue(address)
-	c.memory.Update()
+// Run runs the computer until the program halts and returns its exit code, see ExitCode.
+// Every step waits for a tick of the clock
+func (c *SimpleComputer) Run(clock *clock.Clock, printStateConfig PrintStateConfig) int {
+	log.Println("Starting computer....")
+	c.boot()
+	go c.screenControl.Run()
+
+	steps := 0
+	for !c.cpu.Halted() {
+		clock.Tick()
+		c.step(steps, printStateConfig)
+		steps++
+	}
 
-	c.memory.AddressRegister.Unset()
-	c.memory.Update()
+	log.Printf("Halted with exit code %d", c.ExitCode())
+	return c.ExitCode()
+}
 
-	c.mainBus.SetValue(value)
-	c.memory.Set()
-	c.memory.Update()
+// RunHeadless runs the computer without a display or keyboard goroutine attached.
+// Frames are rendered synchronously every config.FrameEvery instructions and sent on the
+// screen channel (if there is one), keys can be pressed with a scripted keyboard, see
+// ConnectScriptedKeyboard. The run also stops when the program halts.
+// Returns the number of instructions executed.
+func (c *SimpleComputer) RunHeadless(config HeadlessConfig) int {
+	log.Println("Starting computer (headless)....")
+	c.boot()
 
-	c.memory.Unset()
-	c.memory.Update()
+	// a snapshot can be taken half way through a round of the stepper, finish the round first
+	steps := 0
+	for i := c.cpu.State().Steps; i > 0 && i < STEPS_PER_INSTRUCTION; i++ {
+		c.step(steps, config.PrintStateConfig)
+		steps++
+	}
+
+	instructions := 0
+	for {
+		if config.MaxInstructions > 0 && instructions >= config.MaxInstructions {
+			break
+		}
+		if c.cpu.Halted() {
+			log.Printf("Halted after %d instructions with exit code %d", instructions, c.ExitCode())
+			break
+		}
+		if config.HaltWhen != nil && config.HaltWhen(c) {
+			log.Printf("Halt condition met after %d instructions", instructions)
+			break
+		}
+
+		steps = c.runInstruction(steps, config.PrintStateConfig)
+		instructions++
+
+		if config.FrameEvery > 0 && instructions%config.FrameEvery == 0 {
+			c.sendFrame()
+		}
+	}
+
+	// always emit the final state of the screen
+	c.sendFrame()
+	return instructions
 }
 
-func (c *SimpleComputer) Run(tickInterval <-chan time.Time, printStateConfig PrintStateConfig) {
-	log.Println("Starting computer....")
-	c.putValueInRAM(0xFEFE, 0x0040) //JMP back to code region start if IAR reaches the end
-	c.putValueInRAM(0xFEFF, CODE_REGION_START)
+// IAR returns the address of the next instruction the CPU will fetch
+func (c *SimpleComputer) IAR() uint16 {
+	return c.cpu.IAR()
+}
+
+// Halted returns true once the program has run a HALT instruction, the computer does not
+// run any further
+func (c *SimpleComputer) Halted() bool {
+	return c.cpu.Halted()
+}
+
+// ExitCode returns the exit code of a halted program, which is the value in R0
+func (c *SimpleComputer) ExitCode() int {
+	return int(c.cpu.Register(0))
+}
+
+// DebugInfo returns the debug info set with SetDebugInfo, or nil
+func (c *SimpleComputer) DebugInfo() *asm.DebugInfo {
+	return c.debugInfo
+}
+
+// Describe formats an address along with its label and source line if there is debug info, e.g. 0x0503 main-loop+3 (ascii.asm:42)
+func (c *SimpleComputer) Describe(address uint16) string {
+	if c.debugInfo != nil {
+		if description := c.debugInfo.Describe(address); description != "" {
+			return fmt.Sprintf("0x%04X %s", address, description)
+		}
+	}
+	return fmt.Sprintf("0x%04X", address)
+}
+
+// CPU returns the CPU of the computer so its registers can be inspected and changed between steps
+func (c *SimpleComputer) CPU() cpu.Processor {
+	return c.cpu
+}
+
+// ReadMemory returns the value stored in RAM at address
+func (c *SimpleComputer) ReadMemory(address uint16) uint16 {
+	return c.cpu.ReadMemory(address)
+}
+
+// WriteMemory stores value in RAM at address, the memory address register is restored afterwards
+// so this is safe to call between any two steps
+func (c *SimpleComputer) WriteMemory(address, value uint16) {
+	c.cpu.WriteMemory(address, value)
+}
+
+func (c *SimpleComputer) boot() {
+	if c.restored {
+		return
+	}
+	c.stepping.Lock()
+	defer c.stepping.Unlock()
+
+	c.cpu.WriteMemory(0xFEFE, 0x0040) //JMP back to code region start if IAR reaches the end
+	c.cpu.WriteMemory(0xFEFF, CODE_REGION_START)
 
 	// start at offet of user code
 	c.cpu.SetIAR(CODE_REGION_START)
-	go c.screenControl.Run()
+	c.cpu.SetSP(STACK_START)
+}
 
-	steps := 0
+// runInstruction steps the CPU until the current instruction has completed, including
+// any extended steps, and returns the updated step count
+func (c *SimpleComputer) runInstruction(steps int, printStateConfig PrintStateConfig) int {
 	for {
-		<-tickInterval
-		c.cpu.Step()
-
-		if printStateConfig.PrintState {
-			if steps%printStateConfig.PrintStateEvery == 0 {
-				fmt.Println("COMPUTER\n-----------------------------------------------------------")
-				fmt.Printf("Cycle count = %d, step count = %d, printing state every %d steps\n\n", steps/6, steps, printStateConfig.PrintStateEvery)
-				fmt.Println("CPU\n----------------------------------------")
-				fmt.Println(c.cpu.String())
-				fmt.Println()
+		for i := 0; i < STEPS_PER_INSTRUCTION; i++ {
+			c.step(steps, printStateConfig)
+			steps++
+		}
+
+		if !c.cpu.HasExtendedSteps() {
+			return steps
+		}
+	}
+}
+
+func (c *SimpleComputer) step(steps int, printStateConfig PrintStateConfig) {
+	if c.stepHook != nil {
+		c.stepHook.BeforeStep(c)
+	}
+
+	c.stepping.Lock()
+	c.cpu.Step()
+	c.stepping.Unlock()
+
+	if printStateConfig.PrintState {
+		if steps%printStateConfig.PrintStateEvery == 0 {
+			fmt.Println("COMPUTER\n-----------------------------------------------------------")
+			fmt.Printf("Cycle count = %d, step count = %d, printing state every %d steps\n\n", steps/STEPS_PER_INSTRUCTION, steps, printStateConfig.PrintStateEvery)
+			fmt.Println("CPU\n----------------------------------------")
+			if c.debugInfo != nil {
+				fmt.Printf("LOCATION: %s\n", c.Describe(c.cpu.IAR()))
 			}
+			fmt.Println(c.cpu.String())
+			fmt.Println()
 		}
-		steps++
 	}
 }
+
+func (c *SimpleComputer) sendFrame() {
+	if c.screenChannel == nil {
+		return
+	}
+	c.screenChannel <- c.screenControl.Frame()
+}
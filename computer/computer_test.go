@@ -0,0 +1,266 @@
+package computer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/djhworld/simple-computer/asm"
+	"github.com/djhworld/simple-computer/clock"
+	"github.com/djhworld/simple-computer/io"
+)
+
+// counts to 3 in R1 and halts with exit code 3
+const HALT_PROGRAM = `
+	DATA R0, 0x0003
+	DATA R1, 0x0000
+loop:
+	INC R1
+	CMP R0, R1
+	JMPE done
+	JMP loop
+done:
+	MOV R1, R0
+	HALT
+`
+
+// writes two words to sector 1 of the disk, reads them back into R2 and R3 and halts with the second as the exit code
+const DISK_PROGRAM = `
+	DATA R0, 0x0003
+	OUT Addr, R0
+	DATA R1, 0x0001
+	OUT Data, R1
+	DATA R1, 0x1234
+	OUT Data, R1
+	DATA R1, 0x0042
+	OUT Data, R1
+	OUT Addr, R0
+	DATA R1, 0x0001
+	OUT Data, R1
+	IN Data, R2
+	IN Data, R3
+	MOV R3, R0
+	HALT
+`
+
+// waits for a byte from the serial port, sends it back followed by the next character and halts with it as the exit code
+const SERIAL_PROGRAM = `
+	DATA R0, 0x0004
+	OUT Addr, R0
+loop:
+	IN Data, R1
+	AND R1, R1
+	JMPZ loop
+	OUT Data, R1
+	DATA R2, 0x0001
+	ADD R2, R1
+	OUT Data, R1
+	MOV R1, R0
+	HALT
+`
+
+// waits for a key and halts with it as the exit code
+const KEYBOARD_PROGRAM = `
+	DATA R0, 0x000F
+	OUT Addr, R0
+loop:
+	IN Data, R0
+	AND R0, R0
+	JMPZ loop
+	HALT
+`
+
+func TestRunHeadlessStopsWhenHalted(t *testing.T) {
+	for _, model := range []CPUModel{GATE_LEVEL_CPU, BEHAVIOURAL_CPU} {
+		c := newComputer(t, model, HALT_PROGRAM)
+
+		executed := c.RunHeadless(HeadlessConfig{MaxInstructions: 1000})
+		if !c.Halted() || executed != 15 {
+			t.Logf("%s: expected the program to halt after 15 instructions but it ran %d", model, executed)
+			t.FailNow()
+		}
+		if c.ExitCode() != 3 {
+			t.Logf("%s: expected exit code 3 but got %d", model, c.ExitCode())
+			t.FailNow()
+		}
+	}
+}
+
+func TestRunHeadlessStopsAtMaxInstructions(t *testing.T) {
+	for _, model := range []CPUModel{GATE_LEVEL_CPU, BEHAVIOURAL_CPU} {
+		c := newComputer(t, model, HALT_PROGRAM)
+
+		executed := c.RunHeadless(HeadlessConfig{MaxInstructions: 5})
+		if executed != 5 || c.Halted() {
+			t.Logf("%s: expected the run to stop after 5 instructions but it ran %d (halted: %v)", model, executed, c.Halted())
+			t.FailNow()
+		}
+		// DATA, DATA, then INC, CMP and JMPE round the loop once
+		if c.cpu.Register(1) != 1 {
+			t.Logf("%s: expected R1 to be 1 but got\n%s", model, c.cpu)
+			t.FailNow()
+		}
+	}
+}
+
+func TestRunHeadlessStopsWhenHaltWhenIsTrue(t *testing.T) {
+	// the address of done in HALT_PROGRAM
+	const done = CODE_REGION_START + 0x000A
+
+	for _, model := range []CPUModel{GATE_LEVEL_CPU, BEHAVIOURAL_CPU} {
+		c := newComputer(t, model, HALT_PROGRAM)
+
+		checked := 0
+		executed := c.RunHeadless(HeadlessConfig{
+			MaxInstructions: 1000,
+			HaltWhen: func(c *SimpleComputer) bool {
+				checked++
+				return c.IAR() == done
+			},
+		})
+		if c.Halted() || c.IAR() != done || c.cpu.Register(1) != 3 {
+			t.Logf("%s: expected the run to stop at 0x%04X once R1 is 3 but got\n%s", model, done, c.cpu)
+			t.FailNow()
+		}
+		if checked != executed+1 {
+			t.Logf("%s: expected HaltWhen to be checked before each of the %d instructions and once more but it was checked %d times", model, executed, checked)
+			t.FailNow()
+		}
+	}
+}
+
+func TestRunHeadlessPressesScriptedKeysAtTheirCycle(t *testing.T) {
+	for _, model := range []CPUModel{GATE_LEVEL_CPU, BEHAVIOURAL_CPU} {
+		script := new(io.KeyScript)
+		// released keys don't reach the adapter
+		script.Press('A', false)
+		script.Wait(20)
+		script.Press('B', true)
+
+		c := newComputer(t, model, KEYBOARD_PROGRAM)
+		c.ConnectScriptedKeyboard(io.NewScriptedKeyboard(script))
+		executed := c.RunHeadless(HeadlessConfig{MaxInstructions: 1000})
+
+		if !c.Halted() || c.ExitCode() != 'B' {
+			t.Logf("%s: expected the program to halt with the key pressed but got\n%s", model, c.cpu)
+			t.FailNow()
+		}
+		// 2 instructions to select the keyboard, then IN, AND and JMPZ round the loop. The key is pressed
+		// on the 21st cycle so the IN of the 7th time round reads it, after which AND, JMPZ and HALT run
+		if executed != 24 {
+			t.Logf("%s: expected the key to be read after 20 cycles and the program to halt after 24 instructions but it ran %d", model, executed)
+			t.FailNow()
+		}
+	}
+}
+
+func TestRunReturnsExitCode(t *testing.T) {
+	c := newComputer(t, BEHAVIOURAL_CPU, HALT_PROGRAM)
+
+	if exitCode := c.Run(clock.NewClock(0), PrintStateConfig{}); exitCode != 3 {
+		t.Logf("Expected Run to return exit code 3 but got %d", exitCode)
+		t.FailNow()
+	}
+}
+
+func TestProgramsWriteAndReadTheDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk")
+	if err != nil {
+		t.Logf("Error creating a directory for the disk image: %v", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	for _, model := range []CPUModel{GATE_LEVEL_CPU, BEHAVIOURAL_CPU} {
+		filename := filepath.Join(dir, model.String()+".img")
+		disk, err := io.OpenDisk(filename)
+		if err != nil {
+			t.Logf("%s: error opening the disk image: %v", model, err)
+			t.FailNow()
+		}
+
+		c := newComputer(t, model, DISK_PROGRAM)
+		c.ConnectDisk(disk)
+		c.RunHeadless(HeadlessConfig{MaxInstructions: 1000})
+		disk.Close()
+
+		if !c.Halted() || c.ExitCode() != 0x42 || c.cpu.Register(2) != 0x1234 {
+			t.Logf("%s: expected the program to read back the words it wrote but got\n%s", model, c.cpu)
+			t.FailNow()
+		}
+
+		image, err := ioutil.ReadFile(filename)
+		if err != nil || len(image) != io.SECTOR_SIZE*2+4 || string(image[io.SECTOR_SIZE*2:]) != "\x12\x34\x00\x42" {
+			t.Logf("%s: expected the words at the start of sector 1 of the image but got % X (%v)", model, image, err)
+			t.FailNow()
+		}
+	}
+}
+
+func TestProgramsUseTheSerialPort(t *testing.T) {
+	for _, model := range []CPUModel{GATE_LEVEL_CPU, BEHAVIOURAL_CPU} {
+		var out bytes.Buffer
+		port := io.NewSerialPort(strings.NewReader("a"), &out, nil)
+		port.Run()
+
+		c := newComputer(t, model, SERIAL_PROGRAM)
+		c.ConnectSerialPort(port)
+		c.RunHeadless(HeadlessConfig{MaxInstructions: 1000})
+
+		if !c.Halted() || c.ExitCode() != io.SERIAL_READY_BIT|'b' {
+			t.Logf("%s: expected the program to receive 'a' with the ready bit but got\n%s", model, c.cpu)
+			t.FailNow()
+		}
+		if out.String() != "ab" {
+			t.Logf("%s: expected \"ab\" to be sent but got %q", model, out.String())
+			t.FailNow()
+		}
+	}
+}
+
+func TestScriptedKeyboardTypesIntoPrograms(t *testing.T) {
+	for _, model := range []CPUModel{GATE_LEVEL_CPU, BEHAVIOURAL_CPU} {
+		script, err := io.ParseKeyScript(strings.NewReader("wait 100\ntype Z"))
+		if err != nil {
+			t.Logf("Error parsing key script: %v", err)
+			t.FailNow()
+		}
+
+		c := newComputer(t, model, KEYBOARD_PROGRAM)
+		c.ConnectScriptedKeyboard(io.NewScriptedKeyboard(script))
+		executed := c.RunHeadless(HeadlessConfig{MaxInstructions: 1000})
+
+		if !c.Halted() || c.ExitCode() != 'Z' {
+			t.Logf("%s: expected the program to halt with the key typed but got\n%s", model, c.cpu)
+			t.FailNow()
+		}
+		// 2 instructions to select the keyboard, then 3 a time round the loop while waiting
+		if executed < 100 || executed > 105 {
+			t.Logf("%s: expected the key to be typed after 100 cycles but the program ran %d instructions", model, executed)
+			t.FailNow()
+		}
+	}
+}
+
+func newComputer(t *testing.T, model CPUModel, source string) *SimpleComputer {
+	p := asm.Parser{}
+	instructions, err := p.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Logf("Error parsing program: %v", err)
+		t.FailNow()
+	}
+
+	a := asm.Assembler{}
+	bin, err := a.Process(CODE_REGION_START, instructions)
+	if err != nil {
+		t.Logf("Error assembling program: %v", err)
+		t.FailNow()
+	}
+
+	c := NewComputer(nil, nil, model)
+	c.LoadToRAM(CODE_REGION_START, bin)
+	return c
+}
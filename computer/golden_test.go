@@ -0,0 +1,153 @@
+package computer
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/djhworld/simple-computer/capture"
+	"github.com/djhworld/simple-computer/io"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "write the frames of the sample programs to testdata/golden instead of comparing them")
+
+// the GLFW key codes used by the sample programs
+const (
+	KEY_ENTER = 257
+	KEY_RIGHT = 262
+	KEY_DOWN  = 264
+)
+
+// a sample program from _programs, run until it halts or has executed instructions, with keys
+// pressed along the way. The frame at the end is compared to testdata/golden/<name>.png
+type goldenProgram struct {
+	name         string
+	instructions int
+	keys         *io.KeyScript
+}
+
+var GOLDEN_PROGRAMS = []goldenProgram{
+	{"ascii", 20000, nil},
+	{"me", 50000, nil},
+	{"brush", 40000, typeKeys(20000, 1000, KEY_RIGHT, KEY_RIGHT, KEY_RIGHT, KEY_DOWN, KEY_DOWN, KEY_RIGHT)},
+	{"text-writer", 60000, typeKeys(20000, 2000, 'H', 'E', 'L', 'L', 'O', KEY_ENTER, '4', '2')},
+	{"font", 5000, nil},
+	{"colours", 100000, nil},
+}
+
+func TestSampleProgramsMatchGoldenFrames(t *testing.T) {
+	for _, program := range GOLDEN_PROGRAMS {
+		frame := runGoldenProgram(t, program)
+		filename := filepath.Join("testdata", "golden", program.name+".png")
+
+		if *updateGolden {
+			if err := capture.SavePNG(filename, frame); err != nil {
+				t.Logf("%s: error writing golden frame: %v", program.name, err)
+				t.FailNow()
+			}
+			continue
+		}
+
+		golden, err := readGoldenFrame(filename)
+		if err != nil {
+			t.Logf("%s: error reading golden frame, run the tests with -update-golden to create it: %v", program.name, err)
+			t.FailNow()
+		}
+
+		if diff := diffFrames(golden, capture.Image(frame)); diff != "" {
+			actual := filepath.Join(os.TempDir(), program.name+"-actual.png")
+			capture.SavePNG(actual, frame)
+			t.Logf("%s: the frame does not match %s, the frame is in %s. Run the tests with -update-golden if the change is expected\n%s", program.name, filename, actual, diff)
+			t.Fail()
+		}
+	}
+}
+
+func runGoldenProgram(t *testing.T, program goldenProgram) *io.Frame {
+	bin, err := ReadBinFile(filepath.Join("..", "_programs", program.name+".bin"))
+	if err != nil {
+		t.Logf("%s: error reading program: %v", program.name, err)
+		t.FailNow()
+	}
+
+	// the behavioural CPU runs the same instructions as the gate level CPU, see the lockstep package
+	c := NewComputer(nil, nil, BEHAVIOURAL_CPU)
+	c.LoadToRAM(CODE_REGION_START, bin)
+	if program.keys != nil {
+		c.ConnectScriptedKeyboard(io.NewScriptedKeyboard(program.keys))
+	}
+	c.RunHeadless(HeadlessConfig{MaxInstructions: program.instructions})
+	return c.screenControl.Frame()
+}
+
+// typeKeys presses keys one after the other, every cycles apart starting at cycle start
+func typeKeys(start, every int, keys ...int) *io.KeyScript {
+	script := new(io.KeyScript)
+	script.Wait(start)
+	for _, key := range keys {
+		script.Press(key, true)
+		script.Wait(every)
+	}
+	return script
+}
+
+func readGoldenFrame(filename string) (image.Image, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return png.Decode(bytes.NewReader(b))
+}
+
+// diffFrames returns an empty string if the colours of every pixel are the same, otherwise the part of the
+// frame that is different with '#' for a pixel that is different and '.' for one that is the same
+func diffFrames(golden, actual image.Image) string {
+	if golden.Bounds() != actual.Bounds() {
+		return fmt.Sprintf("expected a frame of %v but got %v", golden.Bounds(), actual.Bounds())
+	}
+
+	differences := 0
+	box := image.Rectangle{}
+	var first string
+	bounds := golden.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if !samePixel(golden, actual, x, y) {
+				if differences == 0 {
+					first = fmt.Sprintf("the first is at %d,%d, expected %v but got %v", x, y, golden.At(x, y), actual.At(x, y))
+				}
+				differences++
+				box = box.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if differences == 0 {
+		return ""
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%d pixels are different in %v, %s\n", differences, box, first)
+	for y := box.Min.Y; y < box.Max.Y; y++ {
+		for x := box.Min.X; x < box.Max.X; x++ {
+			if samePixel(golden, actual, x, y) {
+				b.WriteByte('.')
+			} else {
+				b.WriteByte('#')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func samePixel(golden, actual image.Image, x, y int) bool {
+	r1, g1, b1, _ := golden.At(x, y).RGBA()
+	r2, g2, b2, _ := actual.At(x, y).RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2
+}
@@ -0,0 +1,170 @@
+package computer
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	goio "io"
+	"os"
+
+	"github.com/djhworld/simple-computer/cpu"
+	"github.com/djhworld/simple-computer/io"
+)
+
+// a snapshot file starts with SNAPSHOT_MAGIC and the version of the format as a big endian
+// uint16, the rest of the file is the gzipped gob encoding of a snapshot
+const SNAPSHOT_MAGIC = "SIMPLE-COMPUTER-SNAPSHOT"
+
+// bump when a snapshot changes in a way that older snapshots cannot be loaded
+const SNAPSHOT_VERSION = uint16(1)
+
+// snapshot is the full state of the computer, it can be taken between any two steps of the CPU
+type snapshot struct {
+	CPUModel   CPUModel
+	CPU        cpu.State
+	Memory     []uint16
+	Keyboard   io.KeyboardAdapterState
+	Display    io.DisplayAdapterState
+	Timer      io.TimerState
+	Disk       io.DiskControllerState
+	Serial     io.SerialAdapterState
+	Interrupts io.InterruptControllerState
+	// nil if there was no key script being replayed
+	KeyScript *io.ScriptedKeyboardState
+}
+
+// Save writes the state of the memory, the CPU and the peripherals to w, it must not be called while the computer is running, see Snapshot.
+// The contents of the disk are not saved, they are already in its image file
+func (c *SimpleComputer) Save(w goio.Writer) error {
+	s := snapshot{
+		CPUModel:   c.model,
+		CPU:        c.cpu.State(),
+		Memory:     make([]uint16, 0x10000),
+		Keyboard:   c.keyboardAdapter.State(),
+		Display:    c.displayAdapter.State(),
+		Timer:      c.timer.State(),
+		Disk:       c.diskController.State(),
+		Serial:     c.serialAdapter.State(),
+		Interrupts: c.interruptController.State(),
+	}
+	if c.scriptedKeyboard != nil {
+		state := c.scriptedKeyboard.State()
+		s.KeyScript = &state
+	}
+	for address := range s.Memory {
+		s.Memory[address] = c.cpu.ReadMemory(uint16(address))
+	}
+
+	if _, err := goio.WriteString(w, SNAPSHOT_MAGIC); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, SNAPSHOT_VERSION); err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(w)
+	if err := gob.NewEncoder(zw).Encode(&s); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Load restores a snapshot written by Save, the computer must have been created with the same
+// CPU model and have the same key script connected, if any. When the computer is run it carries on
+// from the snapshot instead of booting
+func (c *SimpleComputer) Load(r goio.Reader) error {
+	magic := make([]byte, len(SNAPSHOT_MAGIC))
+	if _, err := goio.ReadFull(r, magic); err != nil || string(magic) != SNAPSHOT_MAGIC {
+		return fmt.Errorf("not a snapshot")
+	}
+
+	var version uint16
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return err
+	}
+	if version != SNAPSHOT_VERSION {
+		return fmt.Errorf("unsupported snapshot version %d, expected %d", version, SNAPSHOT_VERSION)
+	}
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	var s snapshot
+	if err := gob.NewDecoder(zr).Decode(&s); err != nil {
+		return fmt.Errorf("error decoding snapshot: %v", err)
+	}
+
+	if s.CPUModel != c.model {
+		return fmt.Errorf("the snapshot was taken with the %s CPU but the computer has the %s CPU", s.CPUModel, c.model)
+	}
+	if len(s.Memory) != 0x10000 || len(s.Display.RAM) != io.DISPLAY_RAM_SIZE {
+		return fmt.Errorf("the snapshot does not have all of the RAM")
+	}
+	if s.KeyScript != nil {
+		if c.scriptedKeyboard == nil {
+			return fmt.Errorf("the snapshot was taken while replaying a key script, connect the same script to carry on from it")
+		}
+		if err := c.scriptedKeyboard.SetState(*s.KeyScript); err != nil {
+			return fmt.Errorf("the key script is not the one the snapshot was taken with: %v", err)
+		}
+	}
+
+	// writing to the gate level RAM is slow, most of it is never written
+	for address, value := range s.Memory {
+		if c.cpu.ReadMemory(uint16(address)) != value {
+			c.cpu.WriteMemory(uint16(address), value)
+		}
+	}
+	c.keyboardAdapter.SetState(s.Keyboard)
+	c.displayAdapter.SetState(s.Display)
+	c.timer.SetState(s.Timer)
+	c.diskController.SetState(s.Disk)
+	c.serialAdapter.SetState(s.Serial)
+	c.interruptController.SetState(s.Interrupts)
+	// last, the CPU settles the IO bus with the peripherals restored
+	c.cpu.SetState(s.CPU)
+
+	c.restored = true
+	return nil
+}
+
+// SaveFile saves a snapshot to filename, see Save
+func (c *SimpleComputer) SaveFile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	if err := c.Save(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadFile loads a snapshot saved with SaveFile, see Load
+func (c *SimpleComputer) LoadFile(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return c.Load(bufio.NewReader(f))
+}
+
+// Snapshot saves a snapshot to filename, see SaveFile. It can be called from any goroutine while the
+// computer runs, the snapshot is taken between two steps of the CPU even if the clock is paused
+func (c *SimpleComputer) Snapshot(filename string) error {
+	c.stepping.Lock()
+	defer c.stepping.Unlock()
+	return c.SaveFile(filename)
+}
@@ -0,0 +1,196 @@
+package computer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/djhworld/simple-computer/io"
+)
+
+// draws on the display while the keyboard interrupt handler reads the keys
+const SNAPSHOT_PROGRAM = `
+	DATA R0, 0x0481
+	DATA R1, 0x0519 ; handler
+	ST R0, R1
+	DATA R0, 0x0001
+	OUT Addr, R0
+	DATA R0, 0x0002
+	OUT Data, R0
+	DATA R1, 0x0000
+	DATA R3, 0x00F0
+	EI
+loop:
+	DATA R0, 0x0007
+	OUT Addr, R0
+	OUT Data, R1
+	OUT Data, R3
+	INC R1
+	SHL R3
+	JMP loop
+handler:
+	DATA R0, 0x000F
+	OUT Addr, R0
+	IN Data, R2
+	IRET
+`
+
+func TestSnapshotRestoresComputer(t *testing.T) {
+	for _, model := range []CPUModel{GATE_LEVEL_CPU, BEHAVIOURAL_CPU} {
+		c := newComputer(t, model, SNAPSHOT_PROGRAM)
+		c.boot()
+
+		// stop half way through an instruction, while the first key press is waiting to be read
+		runSteps(c, 0, 1203)
+		var saved bytes.Buffer
+		if err := c.Save(&saved); err != nil {
+			t.Logf("%s: error saving snapshot: %v", model, err)
+			t.FailNow()
+		}
+
+		restored := NewComputer(nil, nil, model)
+		if err := restored.Load(bytes.NewReader(saved.Bytes())); err != nil {
+			t.Logf("%s: error loading snapshot: %v", model, err)
+			t.FailNow()
+		}
+		restored.boot()
+
+		runSteps(c, 1203, 1800)
+		runSteps(restored, 1203, 1800)
+
+		var expected, actual bytes.Buffer
+		c.Save(&expected)
+		restored.Save(&actual)
+		if !bytes.Equal(expected.Bytes(), actual.Bytes()) {
+			t.Logf("%s: expected the restored computer to be in the same state\n%s\nbut got\n%s", model, c.cpu, restored.cpu)
+			t.FailNow()
+		}
+
+		if c.ReadMemory(0x0481) == 0xFFFF || c.keyboardAdapter.State().Keycode != 0 || c.cpu.Register(2) != 0x0041 {
+			t.Logf("%s: expected the program to have read the key but got\n%s", model, c.cpu)
+			t.FailNow()
+		}
+		if *c.screenControl.Frame() != *restored.screenControl.Frame() {
+			t.Logf("%s: expected the restored computer to show the same frame", model)
+			t.FailNow()
+		}
+	}
+}
+
+func TestLoadRejectsOtherSnapshots(t *testing.T) {
+	c := NewComputer(nil, nil, BEHAVIOURAL_CPU)
+	var saved bytes.Buffer
+	if err := c.Save(&saved); err != nil {
+		t.Logf("Error saving snapshot: %v", err)
+		t.FailNow()
+	}
+
+	newer := append([]byte{}, saved.Bytes()...)
+	binary.BigEndian.PutUint16(newer[len(SNAPSHOT_MAGIC):], SNAPSHOT_VERSION+1)
+
+	for _, test := range []struct {
+		snapshot []byte
+		model    CPUModel
+		expected string
+	}{
+		{[]byte("not a snapshot at all"), BEHAVIOURAL_CPU, "not a snapshot"},
+		{newer, BEHAVIOURAL_CPU, "unsupported snapshot version 2, expected 1"},
+		{saved.Bytes(), GATE_LEVEL_CPU, "the snapshot was taken with the behavioural CPU but the computer has the gates CPU"},
+	} {
+		err := NewComputer(nil, nil, test.model).Load(bytes.NewReader(test.snapshot))
+		if err == nil || err.Error() != test.expected {
+			t.Logf("Expected error %q but got %v", test.expected, err)
+			t.FailNow()
+		}
+	}
+}
+
+func TestSnapshotIsTakenWhileTheComputerRuns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Logf("Error creating a directory for the snapshots: %v", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	c := newComputer(t, BEHAVIOURAL_CPU, SNAPSHOT_PROGRAM)
+	finished := make(chan bool)
+	go func() {
+		c.RunHeadless(HeadlessConfig{MaxInstructions: 20000})
+		close(finished)
+	}()
+
+	running := filepath.Join(dir, "running.snap")
+	if err := c.Snapshot(running); err != nil {
+		t.Logf("Error saving a snapshot while the computer runs: %v", err)
+		t.FailNow()
+	}
+	<-finished
+
+	// the computer doesn't have to take another step
+	stopped := filepath.Join(dir, "stopped.snap")
+	if err := c.Snapshot(stopped); err != nil {
+		t.Logf("Error saving a snapshot once the computer has stopped: %v", err)
+		t.FailNow()
+	}
+
+	for _, filename := range []string{running, stopped} {
+		if err := NewComputer(nil, nil, BEHAVIOURAL_CPU).LoadFile(filename); err != nil {
+			t.Logf("%s: error loading snapshot: %v", filename, err)
+			t.FailNow()
+		}
+	}
+}
+
+func TestSnapshotCarriesOnWithTheKeyScript(t *testing.T) {
+	newScript := func() *io.KeyScript {
+		script := new(io.KeyScript)
+		script.Wait(50)
+		script.Press('K', true)
+		return script
+	}
+
+	c := newComputer(t, BEHAVIOURAL_CPU, KEYBOARD_PROGRAM)
+	c.ConnectScriptedKeyboard(io.NewScriptedKeyboard(newScript()))
+	all := c.RunHeadless(HeadlessConfig{MaxInstructions: 1000})
+
+	c = newComputer(t, BEHAVIOURAL_CPU, KEYBOARD_PROGRAM)
+	c.ConnectScriptedKeyboard(io.NewScriptedKeyboard(newScript()))
+	before := c.RunHeadless(HeadlessConfig{MaxInstructions: 20})
+	var saved bytes.Buffer
+	if err := c.Save(&saved); err != nil {
+		t.Logf("Error saving snapshot: %v", err)
+		t.FailNow()
+	}
+
+	if err := NewComputer(nil, nil, BEHAVIOURAL_CPU).Load(bytes.NewReader(saved.Bytes())); err == nil {
+		t.Logf("Expected an error loading a snapshot taken with a key script without one")
+		t.FailNow()
+	}
+
+	restored := NewComputer(nil, nil, BEHAVIOURAL_CPU)
+	restored.ConnectScriptedKeyboard(io.NewScriptedKeyboard(newScript()))
+	if err := restored.Load(bytes.NewReader(saved.Bytes())); err != nil {
+		t.Logf("Error loading snapshot: %v", err)
+		t.FailNow()
+	}
+	after := restored.RunHeadless(HeadlessConfig{MaxInstructions: 1000})
+
+	if !restored.Halted() || restored.ExitCode() != 'K' || before+after != all {
+		t.Logf("Expected the key to be pressed after %d instructions as without the snapshot but it was after %d + %d\n%s", all, before, after, restored.cpu)
+		t.FailNow()
+	}
+}
+
+// runSteps steps the computer from step from to step to, the key is pressed at the same step every run
+func runSteps(c *SimpleComputer, from, to int) {
+	for steps := from; steps < to; steps++ {
+		if steps == 1195 {
+			c.keyboardAdapter.KeyboardInBus.SetValue(0x41)
+		}
+		c.step(steps, PrintStateConfig{})
+	}
+}
@@ -256,6 +256,126 @@ import (
 // 0x00FE = CMP R3, R2
 // 0x00FF = CMP R3, R3
 
+// EXTENDED INSTRUCTIONS
+// instructions with 0x0100 set are decoded from bits 0x00F0 by the extended
+// instruction decoder, the decoding of the instructions above is switched off for them.
+// Instructions that need more than 6 steps continue into steps 7 - 12 without
+// fetching the next instruction
+
+// PUSH
+// decrement the stack pointer then store the register in memory at the stack pointer
+// ----------------------
+// 0x0100 = PUSH R0
+// 0x0101 = PUSH R1
+// 0x0102 = PUSH R2
+// 0x0103 = PUSH R3
+
+// POP
+// load the value in memory at the stack pointer into the register then increment the stack pointer
+// ----------------------
+// 0x0110 = POP R0
+// 0x0111 = POP R1
+// 0x0112 = POP R2
+// 0x0113 = POP R3
+
+// CALL
+// push the address of the next instruction and set instruction address register to next byte (2 byte instruction)
+// ----------------------
+// 0x0120 = CALL <value>
+
+// RET
+// pop the return address pushed by CALL into the instruction address register
+// ----------------------
+// 0x0130 = RET
+
+// EI (ENABLE INTERRUPTS)
+// ----------------------
+// 0x0140 = EI
+
+// DI (DISABLE INTERRUPTS)
+// ----------------------
+// 0x0150 = DI
+
+// IRET
+// restore the instruction address register and flags saved when the interrupt was taken,
+// then enable interrupts again
+// ----------------------
+// 0x0160 = IRET
+
+// SUB
+// subtract register B from register A and put the result in register B,
+// the carry flag is used as a borrow in and set when there is a borrow out
+// ----------------------
+// 0x0170 = SUB R0, R0
+// 0x0171 = SUB R0, R1
+// 0x0172 = SUB R0, R2
+// 0x0173 = SUB R0, R3
+
+// 0x0174 = SUB R1, R0
+// 0x0175 = SUB R1, R1
+// 0x0176 = SUB R1, R2
+// 0x0177 = SUB R1, R3
+
+// 0x0178 = SUB R2, R0
+// 0x0179 = SUB R2, R1
+// 0x017A = SUB R2, R2
+// 0x017B = SUB R2, R3
+
+// 0x017C = SUB R3, R0
+// 0x017D = SUB R3, R1
+// 0x017E = SUB R3, R2
+// 0x017F = SUB R3, R3
+
+// MOV
+// copy register A into register B
+// ----------------------
+// 0x0180 = MOV R0, R0
+// 0x0181 = MOV R0, R1
+// 0x0182 = MOV R0, R2
+// 0x0183 = MOV R0, R3
+
+// 0x0184 = MOV R1, R0
+// 0x0185 = MOV R1, R1
+// 0x0186 = MOV R1, R2
+// 0x0187 = MOV R1, R3
+
+// 0x0188 = MOV R2, R0
+// 0x0189 = MOV R2, R1
+// 0x018A = MOV R2, R2
+// 0x018B = MOV R2, R3
+
+// 0x018C = MOV R3, R0
+// 0x018D = MOV R3, R1
+// 0x018E = MOV R3, R2
+// 0x018F = MOV R3, R3
+
+// INC
+// add 1 to the register, the flags are set like ADD without a carry in
+// ----------------------
+// 0x0190 = INC R0
+// 0x0191 = INC R1
+// 0x0192 = INC R2
+// 0x0193 = INC R3
+
+// DEC
+// subtract 1 from the register, the flags are set like SUB without a borrow in
+// ----------------------
+// 0x01A0 = DEC R0
+// 0x01A1 = DEC R1
+// 0x01A2 = DEC R2
+// 0x01A3 = DEC R3
+
+// HALT
+// stop the stepper at step 4, the computer stops with the value in R0 as its exit code
+// ----------------------
+// 0x01B0 = HALT
+
+// INTERRUPTS
+// when interrupts are enabled and the interrupt controller requests an interrupt at the end of
+// an instruction the stepper runs another round that does not fetch an instruction:
+// the IAR and FLAGS are saved, interrupts are disabled, the interrupt is acknowledged so the
+// controller puts the vector table address on the bus and the IAR is loaded from that address
+
 const BUS_WIDTH = 16
 
 const (
@@ -281,7 +401,7 @@ type Updatable interface {
 
 type InstructionDecoder3x8 struct {
 	decoder       components.Decoder3x8
-	selectorGates [8]circuit.ANDGate
+	selectorGates [8]components.ANDGate3
 	bit0NOTGate   circuit.NOTGate
 }
 
@@ -289,13 +409,28 @@ func NewInstructionDecoder3x8() *InstructionDecoder3x8 {
 	i := new(InstructionDecoder3x8)
 	i.decoder = *components.NewDecoder3x8()
 	for n := range i.selectorGates {
-		i.selectorGates[n] = *circuit.NewANDGate()
+		i.selectorGates[n] = *components.NewANDGate3()
 	}
 	i.bit0NOTGate = *circuit.NewNOTGate()
 	return i
 
 }
 
+// decodes the extended instructions (0x01X0)
+type ExtendedInstructionDecoder4x16 struct {
+	decoder       components.Decoder4x16
+	selectorGates [16]circuit.ANDGate
+}
+
+func NewExtendedInstructionDecoder4x16() *ExtendedInstructionDecoder4x16 {
+	i := new(ExtendedInstructionDecoder4x16)
+	i.decoder = *components.NewDecoder4x16()
+	for n := range i.selectorGates {
+		i.selectorGates[n] = *circuit.NewANDGate()
+	}
+	return i
+}
+
 type CPU struct {
 	gpReg0 components.Register
 	gpReg1 components.Register
@@ -306,16 +441,23 @@ type CPU struct {
 	ir     components.Register
 	iar    components.Register
 	flags  components.Register
+	sp     components.Register
+
+	// saved on interrupt entry, restored by IRET
+	savedIAR   components.Register
+	savedFlags components.Register
 
 	clockState bool
 	memory     *memory.Memory64K
 	alu        *alu.ALU
 	stepper    *components.Stepper
 	busOne     components.BusOne
+	busMinus1  components.BusMinusOne
 
 	mainBus       *components.Bus
 	tmpBus        *components.Bus
 	busOneOutput  *components.Bus
+	aluInputBBus  *components.Bus
 	controlBus    *components.Bus
 	accBus        *components.Bus
 	aluToFlagsBus *components.Bus
@@ -324,6 +466,31 @@ type CPU struct {
 
 	// CONTROL UNIT
 	// inc. gates, wiring, instruction decoding etc
+
+	// steps 1 - 6 are the stepper outputs when not extended, 7 - 12 when extended
+	stepGates         [12]circuit.ANDGate
+	normalStepANDGate circuit.ANDGate
+	extended          components.Bit
+	extendedNOTGate   circuit.NOTGate
+	extendNext        components.Bit
+	extendNextANDGate components.ANDGate3
+	extendNextNOTGate circuit.NOTGate
+
+	// the interrupting bit works like the extended bit, it runs the interrupt entry
+	// in steps 1 - 3 instead of fetching an instruction
+	interruptsEnabled    components.Bit
+	interrupting         components.Bit
+	interruptingNOTGate  circuit.NOTGate
+	interruptNext        components.Bit
+	interruptNextANDGate components.ANDGate4
+	interruptGates       [3]circuit.ANDGate
+
+	// once HALT sets the halted bit the stepper is not clocked any more
+	halted              components.Bit
+	haltedSetANDGate    circuit.ANDGate
+	haltedNOTGate       circuit.NOTGate
+	stepperClockANDGate circuit.ANDGate
+
 	step4Gates     [8]circuit.ANDGate
 	step4Gate3And  components.ANDGate3
 	step5Gates     [6]circuit.ANDGate
@@ -332,25 +499,46 @@ type CPU struct {
 	step6Gates2And circuit.ANDGate
 
 	instrDecoder3x8              InstructionDecoder3x8
+	extInstrDecoder4x16          ExtendedInstructionDecoder4x16
 	instructionDecoderEnables2x4 [2]components.Decoder2x4
 	instructionDecoderSet2x4     components.Decoder2x4
 
 	irInstructionANDGate components.ANDGate3
 	irInstructionNOTGate circuit.NOTGate
+	irExtendedNOTGate    circuit.NOTGate
+	irALUInstrANDGate    circuit.ANDGate
+
+	pushGates [3]circuit.ANDGate
+	popGates  [3]circuit.ANDGate
+	callGates [6]circuit.ANDGate
+	retGates  [3]circuit.ANDGate
+	eiGate    circuit.ANDGate
+	diGate    circuit.ANDGate
+	iretGates [2]circuit.ANDGate
+	subGates  [3]circuit.ANDGate
+	movGate   circuit.ANDGate
+	incGates  [2]circuit.ANDGate
+	decGates  [2]circuit.ANDGate
+	haltGate  circuit.ANDGate
 
 	ioBusEnableGate       circuit.ANDGate
-	registerAEnableORGate components.ORGate3
-	registerBEnableORGate components.ORGate4
-	registerBSetORGate    components.ORGate4
+	registerAEnableORGate components.ORGateN
+	registerBEnableORGate components.ORGateN
+	registerBSetORGate    components.ORGateN
 	registerAEnable       circuit.Wire
 	registerBEnable       circuit.Wire
-	accEnableORGate       components.ORGate4
+	accEnableORGate       components.ORGateN
 	accEnableANDGate      circuit.ANDGate
-	busOneEnableORGate    components.ORGate4
-	iarEnableORGate       components.ORGate4
+	busOneEnableORGate    components.ORGateN
+	busMinus1EnableORGate circuit.ORGate
+	iarEnableORGate       components.ORGateN
 	iarEnableANDGate      circuit.ANDGate
-	ramEnableORGate       components.ORGate5
+	ramEnableORGate       components.ORGateN
 	ramEnableANDGate      circuit.ANDGate
+	spEnableORGate        components.ORGate4
+	spEnableANDGate       circuit.ANDGate
+	savedIAREnableANDGate circuit.ANDGate
+	interruptAckANDGate   circuit.ANDGate
 	gpRegEnableANDGates   [8]components.ANDGate3
 	gpRegEnableORGates    [4]circuit.ORGate
 	gpRegSetANDGates      [4]components.ANDGate3
@@ -358,27 +546,41 @@ type CPU struct {
 	ioBusSetGate    circuit.ANDGate
 	irBit4NOTGate   circuit.NOTGate
 	irSetANDGate    circuit.ANDGate
-	marSetORGate    components.ORGate6
+	marSetORGate    components.ORGateN
 	marSetANDGate   circuit.ANDGate
-	iarSetORGate    components.ORGate6
+	iarSetORGate    components.ORGateN
 	iarSetANDGate   circuit.ANDGate
-	accSetORGate    components.ORGate4
+	accSetORGate    components.ORGateN
 	accSetANDGate   circuit.ANDGate
+	ramSetORGate    components.ORGate3
 	ramSetANDGate   circuit.ANDGate
+	spSetORGate     components.ORGate4
+	spSetANDGate    circuit.ANDGate
+	tmpSetORGate    circuit.ORGate
 	tmpSetANDGate   circuit.ANDGate
-	flagsSetORGate  circuit.ORGate
+	flagsSetORGate  components.ORGateN
 	flagsSetANDGate circuit.ANDGate
 	registerBSet    circuit.Wire
 
+	savedRegistersSetANDGate     circuit.ANDGate
+	interruptsEnabledSetORGate   components.ORGate4
+	interruptsEnabledSetANDGate  circuit.ANDGate
+	interruptsEnabledValueORGate circuit.ORGate
+
 	flagStateGates  [4]circuit.ANDGate
 	flagStateORGate components.ORGate4
 
 	aluOpAndGates [3]components.ANDGate3
 
 	carryTemp    components.Bit
+	carryORGate  circuit.ORGate
 	carryANDGate circuit.ANDGate
 
+	aluSubtractORGate circuit.ORGate
+
 	peripherals []io.Peripheral
+	// the peripherals that count cycles, ticked at the start of every round of the stepper
+	clocked []io.Clocked
 }
 
 func NewCPU(mainBus *components.Bus, memory *memory.Memory64K) *CPU {
@@ -398,6 +600,17 @@ func NewCPU(mainBus *components.Bus, memory *memory.Memory64K) *CPU {
 	c.ir = *components.NewRegister("IR", c.mainBus, c.controlBus)
 	c.ir.Disable()
 	c.iar = *components.NewRegister("IAR", c.mainBus, c.mainBus)
+	c.sp = *components.NewRegister("SP", c.mainBus, c.mainBus)
+	c.savedIAR = *components.NewRegister("SIAR", c.mainBus, c.mainBus)
+
+	// registers that are not initialised power up with all bits on, settle them now so
+	// they read the same before the first step as after it
+	for _, r := range []*components.Register{&c.gpReg0, &c.gpReg1, &c.gpReg2, &c.gpReg3, &c.iar, &c.sp, &c.memory.AddressRegister} {
+		runUpdateOn(r)
+	}
+	updateSetStatus(&c.savedIAR, true)
+	runUpdateOn(&c.savedIAR)
+	updateSetStatus(&c.savedIAR, false)
 
 	// Decoders
 	c.instructionDecoderEnables2x4[0] = *components.NewDecoder2x4()
@@ -405,6 +618,7 @@ func NewCPU(mainBus *components.Bus, memory *memory.Memory64K) *CPU {
 	c.instructionDecoderSet2x4 = *components.NewDecoder2x4()
 
 	c.instrDecoder3x8 = *NewInstructionDecoder3x8()
+	c.extInstrDecoder4x16 = *NewExtendedInstructionDecoder4x16()
 
 	// FLAGS
 	c.aluToFlagsBus = components.NewBus(BUS_WIDTH)
@@ -416,6 +630,12 @@ func NewCPU(mainBus *components.Bus, memory *memory.Memory64K) *CPU {
 	runUpdateOn(&c.flags)
 	updateSetStatus(&c.flags, false)
 
+	// the saved flags are restored through the same bus the ALU uses to set the flags
+	c.savedFlags = *components.NewRegister("SFLAGS", c.flagsBus, c.aluToFlagsBus)
+	updateSetStatus(&c.savedFlags, true)
+	runUpdateOn(&c.savedFlags)
+	updateSetStatus(&c.savedFlags, false)
+
 	// TMP
 	c.tmpBus = components.NewBus(BUS_WIDTH)
 	c.tmp = *components.NewRegister("TMP", c.mainBus, c.tmpBus)
@@ -429,45 +649,108 @@ func NewCPU(mainBus *components.Bus, memory *memory.Memory64K) *CPU {
 	c.busOneOutput = components.NewBus(BUS_WIDTH)
 	c.busOne = *components.NewBusOne(c.tmpBus, c.busOneOutput)
 
+	// BUS -1
+	c.aluInputBBus = components.NewBus(BUS_WIDTH)
+	c.busMinus1 = *components.NewBusMinusOne(c.busOneOutput, c.aluInputBBus)
+
 	// ACC
 	c.accBus = components.NewBus(BUS_WIDTH)
 	c.acc = *components.NewRegister("ACC", c.accBus, c.mainBus)
+	runUpdateOn(&c.acc)
+	runUpdateOn(&c.ir)
 
 	// ALU
-	c.alu = alu.NewALU(c.mainBus, c.busOneOutput, c.accBus, c.aluToFlagsBus)
+	c.alu = alu.NewALU(c.mainBus, c.aluInputBBus, c.accBus, c.aluToFlagsBus)
 
 	// IR Register Output
 	c.irInstructionANDGate = *components.NewANDGate3()
 	c.irInstructionNOTGate = *circuit.NewNOTGate()
 	c.irBit4NOTGate = *circuit.NewNOTGate()
+	c.irExtendedNOTGate = *circuit.NewNOTGate()
+	c.irALUInstrANDGate = *circuit.NewANDGate()
 
 	// Enables
-	c.registerAEnableORGate = *components.NewORGate3()
-	c.registerBEnableORGate = *components.NewORGate4()
-	c.registerBSetORGate = *components.NewORGate4()
-	c.accEnableORGate = *components.NewORGate4()
+	c.registerAEnableORGate = *components.NewORGateN(5)
+	c.registerBEnableORGate = *components.NewORGateN(8)
+	c.registerBSetORGate = *components.NewORGateN(9)
+	c.accEnableORGate = *components.NewORGateN(12)
 	c.accEnableANDGate = *circuit.NewANDGate()
-	c.busOneEnableORGate = *components.NewORGate4()
-	c.iarEnableORGate = *components.NewORGate4()
+	c.busOneEnableORGate = *components.NewORGateN(9)
+	c.busMinus1EnableORGate = *circuit.NewORGate()
+	c.iarEnableORGate = *components.NewORGateN(7)
 	c.iarEnableANDGate = *circuit.NewANDGate()
-	c.ramEnableORGate = *components.NewORGate5()
+	c.ramEnableORGate = *components.NewORGateN(9)
 	c.ramEnableANDGate = *circuit.NewANDGate()
+	c.spEnableORGate = *components.NewORGate4()
+	c.spEnableANDGate = *circuit.NewANDGate()
+	c.savedIAREnableANDGate = *circuit.NewANDGate()
+	c.interruptAckANDGate = *circuit.NewANDGate()
 
 	// Sets
 	c.irSetANDGate = *circuit.NewANDGate()
-	c.marSetORGate = *components.NewORGate6()
+	c.marSetORGate = *components.NewORGateN(12)
 	c.marSetANDGate = *circuit.NewANDGate()
-	c.iarSetORGate = *components.NewORGate6()
+	c.iarSetORGate = *components.NewORGateN(10)
 	c.iarSetANDGate = *circuit.NewANDGate()
-	c.accSetORGate = *components.NewORGate4()
+	c.accSetORGate = *components.NewORGateN(12)
 	c.accSetANDGate = *circuit.NewANDGate()
+	c.ramSetORGate = *components.NewORGate3()
 	c.ramSetANDGate = *circuit.NewANDGate()
+	c.spSetORGate = *components.NewORGate4()
+	c.spSetANDGate = *circuit.NewANDGate()
+	c.tmpSetORGate = *circuit.NewORGate()
 	c.tmpSetANDGate = *circuit.NewANDGate()
-	c.flagsSetORGate = *circuit.NewORGate()
+	c.flagsSetORGate = *components.NewORGateN(6)
 	c.flagsSetANDGate = *circuit.NewANDGate()
+	c.savedRegistersSetANDGate = *circuit.NewANDGate()
+	c.interruptsEnabledSetORGate = *components.NewORGate4()
+	c.interruptsEnabledSetANDGate = *circuit.NewANDGate()
+	c.interruptsEnabledValueORGate = *circuit.NewORGate()
 
 	c.carryTemp = *components.NewBit()
+	// settled like the registers, it powers up on
+	c.carryTemp.Update(false, false)
+	c.carryORGate = *circuit.NewORGate()
 	c.carryANDGate = *circuit.NewANDGate()
+	c.aluSubtractORGate = *circuit.NewORGate()
+
+	// EXTENDED STEPS
+	// both bits start off cleared so the CPU starts at step 1 of a normal instruction
+	c.extended = *components.NewBit()
+	c.extended.Update(false, true)
+	c.extendNext = *components.NewBit()
+	c.extendNext.Update(false, true)
+	c.extendedNOTGate = *circuit.NewNOTGate()
+	c.extendNextANDGate = *components.NewANDGate3()
+	c.extendNextNOTGate = *circuit.NewNOTGate()
+	c.normalStepANDGate = *circuit.NewANDGate()
+
+	// INTERRUPTS
+	// interrupts are disabled until the program enables them with EI
+	c.interruptsEnabled = *components.NewBit()
+	c.interruptsEnabled.Update(false, true)
+	c.interrupting = *components.NewBit()
+	c.interrupting.Update(false, true)
+	c.interruptNext = *components.NewBit()
+	c.interruptNext.Update(false, true)
+	c.interruptingNOTGate = *circuit.NewNOTGate()
+	c.interruptNextANDGate = *components.NewANDGate4()
+
+	for i := range c.interruptGates {
+		c.interruptGates[i] = *circuit.NewANDGate()
+	}
+
+	// HALT
+	c.halted = *components.NewBit()
+	c.halted.Update(false, true)
+	c.haltedSetANDGate = *circuit.NewANDGate()
+	c.haltedNOTGate = *circuit.NewNOTGate()
+	c.haltedNOTGate.Update(false)
+	c.stepperClockANDGate = *circuit.NewANDGate()
+
+	for i := range c.stepGates {
+		c.stepGates[i] = *circuit.NewANDGate()
+	}
 
 	for i := range c.step4Gates {
 		c.step4Gates[i] = *circuit.NewANDGate()
@@ -505,6 +788,38 @@ func NewCPU(mainBus *components.Bus, memory *memory.Memory64K) *CPU {
 		c.aluOpAndGates[i] = *components.NewANDGate3()
 	}
 
+	for i := range c.pushGates {
+		c.pushGates[i] = *circuit.NewANDGate()
+	}
+
+	for i := range c.popGates {
+		c.popGates[i] = *circuit.NewANDGate()
+	}
+
+	for i := range c.callGates {
+		c.callGates[i] = *circuit.NewANDGate()
+	}
+
+	for i := range c.retGates {
+		c.retGates[i] = *circuit.NewANDGate()
+	}
+
+	c.eiGate = *circuit.NewANDGate()
+	c.diGate = *circuit.NewANDGate()
+	for i := range c.iretGates {
+		c.iretGates[i] = *circuit.NewANDGate()
+	}
+
+	for i := range c.subGates {
+		c.subGates[i] = *circuit.NewANDGate()
+	}
+	c.movGate = *circuit.NewANDGate()
+	for i := range c.incGates {
+		c.incGates[i] = *circuit.NewANDGate()
+		c.decGates[i] = *circuit.NewANDGate()
+	}
+	c.haltGate = *circuit.NewANDGate()
+
 	c.ioBus = components.NewIOBus()
 	c.ioBusEnableGate = *circuit.NewANDGate()
 	c.ioBusSetGate = *circuit.NewANDGate()
@@ -517,6 +832,9 @@ func NewCPU(mainBus *components.Bus, memory *memory.Memory64K) *CPU {
 func (c *CPU) ConnectPeripheral(p io.Peripheral) {
 	p.Connect(c.ioBus, c.mainBus)
 	c.peripherals = append(c.peripherals, p)
+	if clocked, ok := p.(io.Clocked); ok {
+		c.clocked = append(c.clocked, clocked)
+	}
 }
 
 // Jump IAR
@@ -531,7 +849,258 @@ func (c *CPU) SetIAR(address uint16) {
 	c.clearMainBus()
 }
 
+// IAR returns the current value of the instruction address register
+func (c *CPU) IAR() uint16 {
+	return c.iar.Value()
+}
+
+// Set the stack pointer, the stack grows downwards from this address
+func (c *CPU) SetSP(address uint16) {
+	c.mainBus.SetValue(address)
+
+	updateSetStatus(&c.sp, true)
+	runUpdateOn(&c.sp)
+	updateSetStatus(&c.sp, false)
+	runUpdateOn(&c.sp)
+
+	c.clearMainBus()
+}
+
+// SP returns the current value of the stack pointer
+func (c *CPU) SP() uint16 {
+	return c.sp.Value()
+}
+
+// HasExtendedSteps returns true if the instruction that has just been through steps 1 - 6
+// of the stepper needs another round of the stepper to complete, either for steps 7 - 12
+// or to enter an interrupt handler
+func (c *CPU) HasExtendedSteps() bool {
+	return c.extendNext.Get() || c.interruptNext.Get()
+}
+
+// InterruptsEnabled returns true if the CPU will take interrupts requested by the interrupt controller
+func (c *CPU) InterruptsEnabled() bool {
+	return c.interruptsEnabled.Get()
+}
+
+// AtInstructionStart returns true if the next step will fetch a new instruction, i.e. the
+// stepper is back at step 1 and the previous instruction does not need another round
+func (c *CPU) AtInstructionStart() bool {
+	return c.StepperPosition() == 1 && !c.HasExtendedSteps()
+}
+
+// Halted returns true once a HALT instruction has stopped the stepper
+func (c *CPU) Halted() bool {
+	return c.halted.Get()
+}
+
+// StepperPosition returns which of the 6 steps of the stepper (1 - 6) runs next
+func (c *CPU) StepperPosition() int {
+	for i := 0; i < 6; i++ {
+		if c.stepper.GetOutputWire(i) {
+			return i + 1
+		}
+	}
+	// no output is on until the stepper is clocked for the first time
+	return 1
+}
+
+// Register returns the value of general purpose register R0 - R3
+func (c *CPU) Register(index int) uint16 {
+	return c.generalPurposeRegister(index).Value()
+}
+
+// SetRegister puts value into general purpose register R0 - R3
+func (c *CPU) SetRegister(index int, value uint16) {
+	r := c.generalPurposeRegister(index)
+	c.mainBus.SetValue(value)
+
+	updateSetStatus(r, true)
+	runUpdateOn(r)
+	updateSetStatus(r, false)
+	runUpdateOn(r)
+
+	c.clearMainBus()
+}
+
+func (c *CPU) generalPurposeRegister(index int) *components.Register {
+	switch index {
+	case 0:
+		return &c.gpReg0
+	case 1:
+		return &c.gpReg1
+	case 2:
+		return &c.gpReg2
+	case 3:
+		return &c.gpReg3
+	}
+	panic(fmt.Sprintf("unknown general purpose register R%d", index))
+}
+
+// Flags returns the carry, A larger, equal and zero flags
+func (c *CPU) Flags() (carry, aLarger, equal, zero bool) {
+	return c.flagsBus.GetOutputWire(FLAGS_BUS_CARRY),
+		c.flagsBus.GetOutputWire(FLAGS_BUS_A_LARGER),
+		c.flagsBus.GetOutputWire(FLAGS_BUS_EQUAL),
+		c.flagsBus.GetOutputWire(FLAGS_BUS_ZERO)
+}
+
+// SetFlags overwrites the flags register, the ALU puts its own flags back on the bus
+// the next time it is updated
+func (c *CPU) SetFlags(carry, aLarger, equal, zero bool) {
+	c.aluToFlagsBus.SetInputWire(FLAGS_BUS_CARRY, carry)
+	c.aluToFlagsBus.SetInputWire(FLAGS_BUS_A_LARGER, aLarger)
+	c.aluToFlagsBus.SetInputWire(FLAGS_BUS_EQUAL, equal)
+	c.aluToFlagsBus.SetInputWire(FLAGS_BUS_ZERO, zero)
+
+	updateSetStatus(&c.flags, true)
+	runUpdateOn(&c.flags)
+	updateSetStatus(&c.flags, false)
+	runUpdateOn(&c.flags)
+}
+
+// MAR returns the current value of the memory address register
+func (c *CPU) MAR() uint16 {
+	return c.memory.AddressRegister.Value()
+}
+
+// SetMAR puts address into the memory address register
+func (c *CPU) SetMAR(address uint16) {
+	c.mainBus.SetValue(address)
+
+	updateSetStatus(&c.memory.AddressRegister, true)
+	runUpdateOn(c.memory)
+	updateSetStatus(&c.memory.AddressRegister, false)
+	runUpdateOn(c.memory)
+
+	c.clearMainBus()
+}
+
+// ReadMemory returns the value stored in RAM at address
+func (c *CPU) ReadMemory(address uint16) uint16 {
+	return c.memory.Peek(address)
+}
+
+// WriteMemory stores value in RAM at address, the memory address register is restored afterwards
+// so this is safe to call between any two steps
+func (c *CPU) WriteMemory(address, value uint16) {
+	mar := c.MAR()
+
+	c.SetMAR(address)
+	c.mainBus.SetValue(value)
+	c.memory.Set()
+	c.memory.Update()
+	c.memory.Unset()
+	c.memory.Update()
+	c.clearMainBus()
+
+	c.SetMAR(mar)
+}
+
+// State returns the registers and latches of the CPU, see Processor
+func (c *CPU) State() State {
+	s := State{
+		IAR:      c.iar.Value(),
+		IR:       c.ir.Value(),
+		SP:       c.sp.Value(),
+		MAR:      c.MAR(),
+		TMP:      c.tmp.Value(),
+		ACC:      c.acc.Value(),
+		SavedIAR: c.savedIAR.Value(),
+
+		CarryTemp:         c.carryTemp.Get(),
+		InterruptsEnabled: c.interruptsEnabled.Get(),
+		Extended:          c.extended.Get(),
+		ExtendNext:        c.extendNext.Get(),
+		Interrupting:      c.interrupting.Get(),
+		InterruptNext:     c.interruptNext.Get(),
+		Halted:            c.halted.Get(),
+	}
+
+	for i := range s.Registers {
+		s.Registers[i] = c.Register(i)
+	}
+	s.Flags[0], s.Flags[1], s.Flags[2], s.Flags[3] = c.Flags()
+	for i := range s.SavedFlags {
+		s.SavedFlags[i] = c.savedFlags.Bit(FLAGS_BUS_CARRY + i)
+	}
+
+	for i := 0; i < STEPS_PER_ROUND; i++ {
+		if c.stepper.GetOutputWire(i) {
+			s.Steps = i + 1
+		}
+	}
+	return s
+}
+
+// SetState restores a state returned by State. The gates between the latches are settled the same
+// way as at the end of a step, so the CPU carries on exactly where the state was taken
+func (c *CPU) SetState(s State) {
+	c.stepper = components.NewStepper()
+	for i := 0; i < s.Steps; i++ {
+		c.stepper.Update(true)
+		c.stepper.Update(false)
+	}
+
+	for i, value := range s.Registers {
+		c.SetRegister(i, value)
+	}
+	c.SetIAR(s.IAR)
+	c.SetSP(s.SP)
+	c.SetMAR(s.MAR)
+	loadRegister(&c.ir, c.mainBus, s.IR)
+	loadRegister(&c.tmp, c.mainBus, s.TMP)
+	loadRegister(&c.savedIAR, c.mainBus, s.SavedIAR)
+	loadRegister(&c.acc, c.accBus, s.ACC)
+	c.clearMainBus()
+
+	// the saved flags are set from the flags bus, which the flags register puts back afterwards
+	for i, on := range s.SavedFlags {
+		c.flagsBus.SetInputWire(FLAGS_BUS_CARRY+i, on)
+	}
+	updateSetStatus(&c.savedFlags, true)
+	runUpdateOn(&c.savedFlags)
+	updateSetStatus(&c.savedFlags, false)
+	runUpdateOn(&c.savedFlags)
+	c.SetFlags(s.Flags[0], s.Flags[1], s.Flags[2], s.Flags[3])
+
+	for bit, value := range map[*components.Bit]bool{
+		&c.carryTemp:         s.CarryTemp,
+		&c.interruptsEnabled: s.InterruptsEnabled,
+		&c.extended:          s.Extended,
+		&c.extendNext:        s.ExtendNext,
+		&c.interrupting:      s.Interrupting,
+		&c.interruptNext:     s.InterruptNext,
+		&c.halted:            s.Halted,
+	} {
+		bit.Update(value, true)
+		bit.Update(value, false)
+	}
+	c.haltedNOTGate.Update(c.halted.Get())
+
+	// the decoders and the IO bus are read before they are updated in a step
+	c.updateInstructionDecoder3x8()
+	c.updateExtendedInstructionDecoder4x16()
+	c.updateIOBus()
+	c.updatePeripherals()
+
+	c.clockState = false
+	c.step(c.clockState)
+}
+
+func loadRegister(r *components.Register, bus *components.Bus, value uint16) {
+	bus.SetValue(value)
+	updateSetStatus(r, true)
+	runUpdateOn(r)
+	updateSetStatus(r, false)
+	runUpdateOn(r)
+}
+
 func (c *CPU) Step() {
+	if c.startsRound() {
+		c.tickPeripherals()
+	}
+
 	for i := 0; i < 2; i++ {
 		if c.clockState {
 			c.clockState = false
@@ -544,9 +1113,16 @@ func (c *CPU) Step() {
 }
 
 func (c *CPU) String() string {
-	return fmt.Sprintf("STEPPER: %s\n%s\n%s\n%s\n%s\n%s\n%s\n%s\n%s\n%s\nBUS1: %s\n%s\n%s\n",
+	return fmt.Sprintf("STEPPER: %s EXTENDED: %v INTERRUPTING: %v INTERRUPTS ENABLED: %v HALTED: %v\n%s\n%s\n%s\n%s\n%s\n%s\n%s\n%s\n%s\n%s\n%s\n%s\nBUS1: %s\n%s\n%s\n",
 		c.stepper.String(),
+		c.extended.Get(),
+		c.interrupting.Get(),
+		c.interruptsEnabled.Get(),
+		c.halted.Get(),
 		c.iar.String(),
+		c.sp.String(),
+		c.savedIAR.String(),
+		c.savedFlags.String(),
 		c.memory.AddressRegister.String(),
 		c.ir.String(),
 		c.acc.String(),
@@ -562,10 +1138,15 @@ func (c *CPU) String() string {
 }
 
 func (c *CPU) step(clockState bool) {
-	c.stepper.Update(clockState)
+	c.stepperClockANDGate.Update(clockState, c.haltedNOTGate.Output())
+	c.stepper.Update(c.stepperClockANDGate.Output())
+	c.updateExtended()
+	c.updateInterrupt()
+	c.runStepGates()
 	c.runStep4Gates()
 	c.runStep5Gates()
 	c.runStep6Gates()
+	c.runExtendedInstructionGates()
 
 	c.runEnable(clockState)
 	c.updateStates()
@@ -595,6 +1176,12 @@ func (c *CPU) updateStates() {
 	// IAR
 	runUpdateOn(&c.iar)
 
+	// SAVED IAR
+	runUpdateOn(&c.savedIAR)
+
+	// SP
+	runUpdateOn(&c.sp)
+
 	// MAR
 	runUpdateOn(&c.memory.AddressRegister)
 
@@ -613,9 +1200,16 @@ func (c *CPU) updateStates() {
 	// BUS1
 	runUpdateOn(&c.busOne)
 
+	// BUS-1
+	runUpdateOn(&c.busMinus1)
+
 	// ALU
 	c.updateALU()
 
+	// SAVED FLAGS
+	// after the ALU, so when restoring the flags it is the saved flags that end up on the bus
+	runUpdateOn(&c.savedFlags)
+
 	// ACC
 	runUpdateOn(&c.acc)
 
@@ -632,6 +1226,7 @@ func (c *CPU) updateStates() {
 	runUpdateOn(&c.gpReg3)
 
 	c.updateInstructionDecoder3x8()
+	c.updateExtendedInstructionDecoder4x16()
 	c.updateIOBus()
 	c.updatePeripherals()
 }
@@ -642,70 +1237,184 @@ func (c *CPU) updatePeripherals() {
 	}
 }
 
+// startsRound returns true if the next step is step 1, the stepper is on step 6 or has not been clocked yet.
+// A halted CPU never starts another round
+func (c *CPU) startsRound() bool {
+	for i := 0; i < STEPS_PER_ROUND-1; i++ {
+		if c.stepper.GetOutputWire(i) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *CPU) tickPeripherals() {
+	for _, p := range c.clocked {
+		p.Tick()
+	}
+}
+
 func (c *CPU) updateIOBus() {
 	c.ioBus.Update(c.ir.Bit(12), c.ir.Bit(13))
 }
 
 func (c *CPU) updateALU() {
 	//update ALU operation based on instruction register
-	c.aluOpAndGates[2].Update(c.ir.Bit(9), c.ir.Bit(8), c.stepper.GetOutputWire(4))
-	c.aluOpAndGates[1].Update(c.ir.Bit(10), c.ir.Bit(8), c.stepper.GetOutputWire(4))
-	c.aluOpAndGates[0].Update(c.ir.Bit(11), c.ir.Bit(8), c.stepper.GetOutputWire(4))
+	c.aluOpAndGates[2].Update(c.ir.Bit(9), c.irALUInstrANDGate.Output(), c.stepGates[4].Output())
+	c.aluOpAndGates[1].Update(c.ir.Bit(10), c.irALUInstrANDGate.Output(), c.stepGates[4].Output())
+	c.aluOpAndGates[0].Update(c.ir.Bit(11), c.irALUInstrANDGate.Output(), c.stepGates[4].Output())
 
 	c.alu.Op[2].Update(c.aluOpAndGates[2].Output())
 	c.alu.Op[1].Update(c.aluOpAndGates[1].Output())
 	c.alu.Op[0].Update(c.aluOpAndGates[0].Output())
 
+	// SUB and DEC use the ALU's adder as a subtractor
+	c.aluSubtractORGate.Update(c.subGates[1].Output(), c.decGates[0].Output())
+	c.alu.Subtract.Update(c.aluSubtractORGate.Output())
+
 	c.alu.CarryIn.Update(c.carryANDGate.Output())
 	c.alu.Update()
 
 }
 
 func (c *CPU) updateInstructionDecoder3x8() {
+	c.irExtendedNOTGate.Update(c.ir.Bit(7))
+	c.irALUInstrANDGate.Update(c.ir.Bit(8), c.irExtendedNOTGate.Output())
+
 	c.instrDecoder3x8.bit0NOTGate.Update(c.ir.Bit(8))
 
 	c.instrDecoder3x8.decoder.Update(c.ir.Bit(9), c.ir.Bit(10), c.ir.Bit(11))
 
 	for i := 0; i < 8; i++ {
-		c.instrDecoder3x8.selectorGates[i].Update(c.instrDecoder3x8.decoder.GetOutputWire(i), c.instrDecoder3x8.bit0NOTGate.Output())
+		c.instrDecoder3x8.selectorGates[i].Update(c.instrDecoder3x8.decoder.GetOutputWire(i), c.instrDecoder3x8.bit0NOTGate.Output(), c.irExtendedNOTGate.Output())
+	}
+}
+
+func (c *CPU) updateExtendedInstructionDecoder4x16() {
+	c.extInstrDecoder4x16.decoder.Update(c.ir.Bit(8), c.ir.Bit(9), c.ir.Bit(10), c.ir.Bit(11))
+
+	for i := 0; i < 16; i++ {
+		c.extInstrDecoder4x16.selectorGates[i].Update(c.extInstrDecoder4x16.decoder.GetOutputWire(i), c.ir.Bit(7))
+	}
+}
+
+// The extended bit is only latched at step 1 from a value that is only latched at step 6,
+// so it is stable for a whole round of the stepper. Instructions that need more than 6
+// steps request the extended steps, which always run with the extended bit cleared after
+func (c *CPU) updateExtended() {
+	c.extendedNOTGate.Update(c.extended.Get())
+	c.interruptingNOTGate.Update(c.interrupting.Get())
+
+	// the IR still holds the last instruction during the interrupt entry so it must not extend again
+	c.extendNextANDGate.Update(c.extendedNOTGate.Output(), c.extInstrDecoder4x16.selectorGates[2].Output(), c.interruptingNOTGate.Output())
+	c.extendNextNOTGate.Update(c.extendNextANDGate.Output())
+	c.extendNext.Update(c.extendNextANDGate.Output(), c.stepper.GetOutputWire(5))
+
+	c.extended.Update(c.extendNext.Get(), c.stepper.GetOutputWire(0))
+	c.extendedNOTGate.Update(c.extended.Get())
+}
+
+// Interrupts are only taken between instructions, so the interrupting bit is latched the same
+// way as the extended bit, but only once the instruction does not need any more steps
+func (c *CPU) updateInterrupt() {
+	c.interruptNextANDGate.Update(
+		c.interruptsEnabled.Get(),
+		c.ioBus.IsInterruptRequested(),
+		c.extendNextNOTGate.Output(),
+		c.interruptingNOTGate.Output(),
+	)
+	c.interruptNext.Update(c.interruptNextANDGate.Output(), c.stepper.GetOutputWire(5))
+
+	c.interrupting.Update(c.interruptNext.Get(), c.stepper.GetOutputWire(0))
+	c.interruptingNOTGate.Update(c.interrupting.Get())
+}
+
+func (c *CPU) runStepGates() {
+	c.normalStepANDGate.Update(c.extendedNOTGate.Output(), c.interruptingNOTGate.Output())
+
+	for i := 0; i < 6; i++ {
+		c.stepGates[i].Update(c.stepper.GetOutputWire(i), c.normalStepANDGate.Output())
+		c.stepGates[i+6].Update(c.stepper.GetOutputWire(i), c.extended.Get())
+	}
+
+	for i := range c.interruptGates {
+		c.interruptGates[i].Update(c.stepper.GetOutputWire(i), c.interrupting.Get())
 	}
 }
 
 func (c *CPU) runStep4Gates() {
-	c.step4Gates[0].Update(c.stepper.GetOutputWire(3), c.ir.Bit(8))
+	c.step4Gates[0].Update(c.stepGates[3].Output(), c.irALUInstrANDGate.Output())
 
 	gate := 1
 	for selector := 0; selector < 7; selector++ {
-		c.step4Gates[gate].Update(c.stepper.GetOutputWire(3), c.instrDecoder3x8.selectorGates[selector].Output())
+		c.step4Gates[gate].Update(c.stepGates[3].Output(), c.instrDecoder3x8.selectorGates[selector].Output())
 		gate++
 	}
 
-	c.step4Gate3And.Update(c.stepper.GetOutputWire(3), c.instrDecoder3x8.selectorGates[7].Output(), c.ir.Bit(12))
+	c.step4Gate3And.Update(c.stepGates[3].Output(), c.instrDecoder3x8.selectorGates[7].Output(), c.ir.Bit(12))
 	c.irBit4NOTGate.Update(c.ir.Bit(12))
 }
 
 func (c *CPU) runStep5Gates() {
-	c.step5Gates[0].Update(c.stepper.GetOutputWire(4), c.ir.Bit(8))
-	c.step5Gates[1].Update(c.stepper.GetOutputWire(4), c.instrDecoder3x8.selectorGates[0].Output())
-	c.step5Gates[2].Update(c.stepper.GetOutputWire(4), c.instrDecoder3x8.selectorGates[1].Output())
-	c.step5Gates[3].Update(c.stepper.GetOutputWire(4), c.instrDecoder3x8.selectorGates[2].Output())
+	c.step5Gates[0].Update(c.stepGates[4].Output(), c.irALUInstrANDGate.Output())
+	c.step5Gates[1].Update(c.stepGates[4].Output(), c.instrDecoder3x8.selectorGates[0].Output())
+	c.step5Gates[2].Update(c.stepGates[4].Output(), c.instrDecoder3x8.selectorGates[1].Output())
+	c.step5Gates[3].Update(c.stepGates[4].Output(), c.instrDecoder3x8.selectorGates[2].Output())
 
-	c.step5Gates[4].Update(c.stepper.GetOutputWire(4), c.instrDecoder3x8.selectorGates[4].Output())
-	c.step5Gates[5].Update(c.stepper.GetOutputWire(4), c.instrDecoder3x8.selectorGates[5].Output())
+	c.step5Gates[4].Update(c.stepGates[4].Output(), c.instrDecoder3x8.selectorGates[4].Output())
+	c.step5Gates[5].Update(c.stepGates[4].Output(), c.instrDecoder3x8.selectorGates[5].Output())
 
-	c.step5Gate3And.Update(c.stepper.GetOutputWire(4), c.instrDecoder3x8.selectorGates[7].Output(), c.irBit4NOTGate.Output())
+	c.step5Gate3And.Update(c.stepGates[4].Output(), c.instrDecoder3x8.selectorGates[7].Output(), c.irBit4NOTGate.Output())
 }
 
 func (c *CPU) runStep6Gates() {
-	c.step6Gates[0].Update(c.stepper.GetOutputWire(5), c.ir.Bit(8), c.irInstructionNOTGate.Output())
-	c.step6Gates2And.Update(c.stepper.GetOutputWire(5), c.instrDecoder3x8.selectorGates[2].Output())
-	c.step6Gates[1].Update(c.stepper.GetOutputWire(5), c.instrDecoder3x8.selectorGates[5].Output(), c.flagStateORGate.Output())
+	c.step6Gates[0].Update(c.stepGates[5].Output(), c.irALUInstrANDGate.Output(), c.irInstructionNOTGate.Output())
+	c.step6Gates2And.Update(c.stepGates[5].Output(), c.instrDecoder3x8.selectorGates[2].Output())
+	c.step6Gates[1].Update(c.stepGates[5].Output(), c.instrDecoder3x8.selectorGates[5].Output(), c.flagStateORGate.Output())
+}
+
+func (c *CPU) runExtendedInstructionGates() {
+	// PUSH, POP and RET run in steps 4 - 6
+	for i := 0; i < 3; i++ {
+		c.pushGates[i].Update(c.stepGates[i+3].Output(), c.extInstrDecoder4x16.selectorGates[0].Output())
+		c.popGates[i].Update(c.stepGates[i+3].Output(), c.extInstrDecoder4x16.selectorGates[1].Output())
+		c.retGates[i].Update(c.stepGates[i+3].Output(), c.extInstrDecoder4x16.selectorGates[3].Output())
+	}
+
+	// CALL runs in steps 4 - 9
+	for i := 0; i < 6; i++ {
+		c.callGates[i].Update(c.stepGates[i+3].Output(), c.extInstrDecoder4x16.selectorGates[2].Output())
+	}
+
+	c.eiGate.Update(c.stepGates[3].Output(), c.extInstrDecoder4x16.selectorGates[4].Output())
+	c.diGate.Update(c.stepGates[3].Output(), c.extInstrDecoder4x16.selectorGates[5].Output())
+	for i := range c.iretGates {
+		c.iretGates[i].Update(c.stepGates[i+3].Output(), c.extInstrDecoder4x16.selectorGates[6].Output())
+	}
+
+	// SUB runs through the ALU like the other ALU instructions, with the ALU op left at ADD
+	for i := range c.subGates {
+		c.subGates[i].Update(c.stepGates[i+3].Output(), c.extInstrDecoder4x16.selectorGates[7].Output())
+	}
+	c.movGate.Update(c.stepGates[3].Output(), c.extInstrDecoder4x16.selectorGates[8].Output())
+
+	// INC and DEC put the register through the ALU with BUS1 as the other input
+	for i := range c.incGates {
+		c.incGates[i].Update(c.stepGates[i+3].Output(), c.extInstrDecoder4x16.selectorGates[9].Output())
+		c.decGates[i].Update(c.stepGates[i+3].Output(), c.extInstrDecoder4x16.selectorGates[10].Output())
+	}
+
+	c.haltGate.Update(c.stepGates[3].Output(), c.extInstrDecoder4x16.selectorGates[11].Output())
 }
 
 func (c *CPU) runEnable(state bool) {
 	c.runEnableOnIO(state)
 	c.runEnableOnIAR(state)
+	c.runEnableOnSP(state)
+	c.runEnableOnSavedRegisters(state)
+	c.runEnableOnInterruptAcknowledge(state)
 	c.runEnableOnBusOne(state)
+	c.runEnableOnBusMinusOne()
 	c.runEnableOnACC(state)
 	c.runEnableOnRAM(state)
 	c.runEnableOnRegisterB()
@@ -719,40 +1428,120 @@ func (c *CPU) runEnableOnIO(state bool) {
 }
 
 func (c *CPU) runEnableOnRegisterB() {
-	c.registerBEnableORGate.Update(c.step4Gates[0].Output(), c.step5Gates[2].Output(), c.step4Gates[4].Output(), c.step4Gate3And.Output())
+	c.registerBEnableORGate.Update(
+		c.step4Gates[0].Output(),
+		c.step5Gates[2].Output(),
+		c.step4Gates[4].Output(),
+		c.step4Gate3And.Output(),
+		c.pushGates[2].Output(),
+		c.subGates[0].Output(),
+		c.incGates[0].Output(),
+		c.decGates[0].Output(),
+	)
 	c.registerBEnable.Update(c.registerBEnableORGate.Output())
 }
 
 func (c *CPU) runEnableOnRegisterA() {
-	c.registerAEnableORGate.Update(c.step4Gates[1].Output(), c.step4Gates[2].Output(), c.step5Gates[0].Output())
+	c.registerAEnableORGate.Update(
+		c.step4Gates[1].Output(),
+		c.step4Gates[2].Output(),
+		c.step5Gates[0].Output(),
+		c.subGates[1].Output(),
+		c.movGate.Output(),
+	)
 	c.registerAEnable.Update(c.registerAEnableORGate.Output())
 }
 
 func (c *CPU) runEnableOnBusOne(state bool) {
-	c.busOneEnableORGate.Update(c.stepper.GetOutputWire(0), c.step4Gates[7].Output(), c.step4Gates[6].Output(), c.step4Gates[3].Output())
+	c.busOneEnableORGate.Update(
+		c.stepGates[0].Output(),
+		c.step4Gates[7].Output(),
+		c.step4Gates[6].Output(),
+		c.step4Gates[3].Output(),
+		c.popGates[0].Output(),
+		c.callGates[2].Output(),
+		c.retGates[0].Output(),
+		c.incGates[0].Output(),
+		c.decGates[0].Output(),
+	)
 	updateEnableStatus(&c.busOne, c.busOneEnableORGate.Output())
 }
 
+func (c *CPU) runEnableOnBusMinusOne() {
+	c.busMinus1EnableORGate.Update(c.pushGates[0].Output(), c.callGates[0].Output())
+	updateEnableStatus(&c.busMinus1, c.busMinus1EnableORGate.Output())
+}
+
 func (c *CPU) runEnableOnACC(state bool) {
-	c.accEnableORGate.Update(c.stepper.GetOutputWire(2), c.step5Gates[5].Output(), c.step6Gates2And.Output(), c.step6Gates[0].Output())
+	c.accEnableORGate.Update(
+		c.stepGates[2].Output(),
+		c.step5Gates[5].Output(),
+		c.step6Gates2And.Output(),
+		c.step6Gates[0].Output(),
+		c.pushGates[1].Output(),
+		c.popGates[2].Output(),
+		c.callGates[1].Output(),
+		c.callGates[3].Output(),
+		c.retGates[2].Output(),
+		c.subGates[2].Output(),
+		c.incGates[1].Output(),
+		c.decGates[1].Output(),
+	)
 	c.accEnableANDGate.Update(state, c.accEnableORGate.Output())
 
 	updateEnableStatus(&c.acc, c.accEnableANDGate.Output())
 }
 
 func (c *CPU) runEnableOnIAR(state bool) {
-	c.iarEnableORGate.Update(c.stepper.GetOutputWire(0), c.step4Gates[3].Output(), c.step4Gates[5].Output(), c.step4Gates[6].Output())
+	c.iarEnableORGate.Update(
+		c.stepGates[0].Output(),
+		c.step4Gates[3].Output(),
+		c.step4Gates[5].Output(),
+		c.step4Gates[6].Output(),
+		c.callGates[2].Output(),
+		c.callGates[4].Output(),
+		c.interruptGates[0].Output(),
+	)
 	c.iarEnableANDGate.Update(state, c.iarEnableORGate.Output())
 	updateEnableStatus(&c.iar, c.iarEnableANDGate.Output())
 }
 
+func (c *CPU) runEnableOnSP(state bool) {
+	c.spEnableORGate.Update(
+		c.pushGates[0].Output(),
+		c.popGates[0].Output(),
+		c.callGates[0].Output(),
+		c.retGates[0].Output(),
+	)
+	c.spEnableANDGate.Update(state, c.spEnableORGate.Output())
+	updateEnableStatus(&c.sp, c.spEnableANDGate.Output())
+}
+
+func (c *CPU) runEnableOnSavedRegisters(state bool) {
+	c.savedIAREnableANDGate.Update(state, c.iretGates[0].Output())
+	updateEnableStatus(&c.savedIAR, c.savedIAREnableANDGate.Output())
+
+	// like BUS1 the saved flags are not enabled by the clock, they have to stay on the
+	// flags bus until the FLAGS register is set
+	updateEnableStatus(&c.savedFlags, c.iretGates[1].Output())
+}
+
+func (c *CPU) runEnableOnInterruptAcknowledge(state bool) {
+	c.interruptAckANDGate.Update(state, c.interruptGates[1].Output())
+	c.ioBus.UpdateInterruptAcknowledge(c.interruptAckANDGate.Output())
+}
+
 func (c *CPU) runEnableOnRAM(state bool) {
 	c.ramEnableORGate.Update(
-		c.stepper.GetOutputWire(1),
+		c.stepGates[1].Output(),
 		c.step6Gates[1].Output(),
 		c.step5Gates[4].Output(),
 		c.step5Gates[3].Output(),
 		c.step5Gates[1].Output(),
+		c.popGates[1].Output(),
+		c.callGates[5].Output(),
+		c.retGates[1].Output(),
+		c.interruptGates[2].Output(),
 	)
 	c.ramEnableANDGate.Update(state, c.ramEnableORGate.Output())
 	updateEnableStatus(c.memory, c.ramEnableANDGate.Output())
@@ -797,6 +1586,9 @@ func (c *CPU) runSet(state bool) {
 	c.runSetOnIO(state)
 	c.runSetOnMAR(state)
 	c.runSetOnIAR(state)
+	c.runSetOnSP(state)
+	c.runSetOnSavedRegisters(state)
+	c.runSetOnInterruptsEnabled(state)
 	c.runSetOnIR(state)
 	c.runSetOnACC(state)
 	c.runSetOnRAM(state)
@@ -804,6 +1596,7 @@ func (c *CPU) runSet(state bool) {
 	c.runSetOnFLAGS(state)
 	c.runSetOnRegisterB()
 	c.runSetGeneralPurposeRegisters(state)
+	c.runSetOnHalted(state)
 }
 
 func (c *CPU) refreshFlagStateGates() {
@@ -841,12 +1634,18 @@ func (c *CPU) runSetOnIO(state bool) {
 
 func (c *CPU) runSetOnMAR(state bool) {
 	c.marSetORGate.Update(
-		c.stepper.GetOutputWire(0),
+		c.stepGates[0].Output(),
 		c.step4Gates[3].Output(),
 		c.step4Gates[6].Output(),
 		c.step4Gates[1].Output(),
 		c.step4Gates[2].Output(),
 		c.step4Gates[5].Output(),
+		c.pushGates[1].Output(),
+		c.popGates[0].Output(),
+		c.callGates[1].Output(),
+		c.callGates[4].Output(),
+		c.retGates[0].Output(),
+		c.interruptGates[1].Output(),
 	)
 	c.marSetANDGate.Update(state, c.marSetORGate.Output())
 	updateSetStatus(&c.memory.AddressRegister, c.marSetANDGate.Output())
@@ -854,28 +1653,70 @@ func (c *CPU) runSetOnMAR(state bool) {
 
 func (c *CPU) runSetOnIAR(state bool) {
 	c.iarSetORGate.Update(
-		c.stepper.GetOutputWire(2),
+		c.stepGates[2].Output(),
 		c.step4Gates[4].Output(),
 		c.step5Gates[4].Output(),
 		c.step5Gates[5].Output(),
 		c.step6Gates2And.Output(),
 		c.step6Gates[1].Output(),
+		c.callGates[5].Output(),
+		c.retGates[1].Output(),
+		c.interruptGates[2].Output(),
+		c.iretGates[0].Output(),
 	)
 	c.iarSetANDGate.Update(state, c.iarSetORGate.Output())
 	updateSetStatus(&c.iar, c.iarSetANDGate.Output())
 }
 
+func (c *CPU) runSetOnSP(state bool) {
+	c.spSetORGate.Update(
+		c.pushGates[1].Output(),
+		c.popGates[2].Output(),
+		c.callGates[1].Output(),
+		c.retGates[2].Output(),
+	)
+	c.spSetANDGate.Update(state, c.spSetORGate.Output())
+	updateSetStatus(&c.sp, c.spSetANDGate.Output())
+}
+
+func (c *CPU) runSetOnSavedRegisters(state bool) {
+	c.savedRegistersSetANDGate.Update(state, c.interruptGates[0].Output())
+	updateSetStatus(&c.savedIAR, c.savedRegistersSetANDGate.Output())
+	updateSetStatus(&c.savedFlags, c.savedRegistersSetANDGate.Output())
+}
+
+// interrupts are disabled by DI and when an interrupt is taken, enabled by EI and IRET
+func (c *CPU) runSetOnInterruptsEnabled(state bool) {
+	c.interruptsEnabledSetORGate.Update(
+		c.eiGate.Output(),
+		c.diGate.Output(),
+		c.iretGates[1].Output(),
+		c.interruptGates[0].Output(),
+	)
+	c.interruptsEnabledSetANDGate.Update(state, c.interruptsEnabledSetORGate.Output())
+	c.interruptsEnabledValueORGate.Update(c.eiGate.Output(), c.iretGates[1].Output())
+	c.interruptsEnabled.Update(c.interruptsEnabledValueORGate.Output(), c.interruptsEnabledSetANDGate.Output())
+}
+
 func (c *CPU) runSetOnIR(state bool) {
-	c.irSetANDGate.Update(state, c.stepper.GetOutputWire(1))
+	c.irSetANDGate.Update(state, c.stepGates[1].Output())
 	updateSetStatus(&c.ir, c.irSetANDGate.Output())
 }
 
 func (c *CPU) runSetOnACC(state bool) {
 	c.accSetORGate.Update(
-		c.stepper.GetOutputWire(0),
+		c.stepGates[0].Output(),
 		c.step4Gates[3].Output(),
 		c.step4Gates[6].Output(),
 		c.step5Gates[0].Output(),
+		c.pushGates[0].Output(),
+		c.popGates[0].Output(),
+		c.callGates[0].Output(),
+		c.callGates[2].Output(),
+		c.retGates[0].Output(),
+		c.subGates[1].Output(),
+		c.incGates[0].Output(),
+		c.decGates[0].Output(),
 	)
 	c.accSetANDGate.Update(state, c.accSetORGate.Output())
 	updateSetStatus(&c.acc, c.accSetANDGate.Output())
@@ -885,18 +1726,24 @@ func (c *CPU) runSetOnFLAGS(state bool) {
 	c.flagsSetORGate.Update(
 		c.step5Gates[0].Output(),
 		c.step4Gates[7].Output(),
+		c.iretGates[1].Output(),
+		c.subGates[1].Output(),
+		c.incGates[0].Output(),
+		c.decGates[0].Output(),
 	)
 	c.flagsSetANDGate.Update(state, c.flagsSetORGate.Output())
 	updateSetStatus(&c.flags, c.flagsSetANDGate.Output())
 }
 
 func (c *CPU) runSetOnRAM(state bool) {
-	c.ramSetANDGate.Update(state, c.step5Gates[2].Output())
+	c.ramSetORGate.Update(c.step5Gates[2].Output(), c.pushGates[2].Output(), c.callGates[3].Output())
+	c.ramSetANDGate.Update(state, c.ramSetORGate.Output())
 	updateSetStatus(c.memory, c.ramSetANDGate.Output())
 }
 
 func (c *CPU) runSetOnTMP(state bool) {
-	c.tmpSetANDGate.Update(state, c.step4Gates[0].Output())
+	c.tmpSetORGate.Update(c.step4Gates[0].Output(), c.subGates[0].Output())
+	c.tmpSetANDGate.Update(state, c.tmpSetORGate.Output())
 	updateSetStatus(&c.tmp, c.tmpSetANDGate.Output())
 
 	// We will add a new memory bit called
@@ -904,7 +1751,15 @@ func (c *CPU) runSetOnTMP(state bool) {
 	// we just added above. It will be set in step 4, the same time that the TMP register gets
 	// set. Thus, the ALU instruction will have a carry input that cannot change during step 5.
 	c.carryTemp.Update(c.flagsBus.GetOutputWire(FLAGS_BUS_CARRY), c.tmpSetANDGate.Output())
-	c.carryANDGate.Update(c.carryTemp.Get(), c.step5Gates[0].Output())
+	c.carryORGate.Update(c.step5Gates[0].Output(), c.subGates[1].Output())
+	c.carryANDGate.Update(c.carryTemp.Get(), c.carryORGate.Output())
+}
+
+// the halted bit is only cleared by restoring a state, nothing else runs once it is set
+func (c *CPU) runSetOnHalted(state bool) {
+	c.haltedSetANDGate.Update(state, c.haltGate.Output())
+	c.halted.Update(c.haltGate.Output(), c.haltedSetANDGate.Output())
+	c.haltedNOTGate.Update(c.halted.Get())
 }
 
 func (c *CPU) runSetOnRegisterB() {
@@ -913,6 +1768,11 @@ func (c *CPU) runSetOnRegisterB() {
 		c.step6Gates[0].Output(),
 		c.step5Gates[3].Output(),
 		c.step5Gate3And.Output(),
+		c.popGates[1].Output(),
+		c.subGates[2].Output(),
+		c.movGate.Output(),
+		c.incGates[1].Output(),
+		c.decGates[1].Output(),
 	)
 
 	c.registerBSet.Update(c.registerBSetORGate.Output())
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/djhworld/simple-computer/components"
+	"github.com/djhworld/simple-computer/io"
 	"github.com/djhworld/simple-computer/memory"
 )
 
@@ -863,6 +864,103 @@ func testShift(instruction uint16, inputRegisters [4]uint16, expectedOutputRegis
 	checkRegisters(c, expectedOutputRegisters[0], expectedOutputRegisters[1], expectedOutputRegisters[2], expectedOutputRegisters[3], t)
 }
 
+func TestSUB(t *testing.T) {
+	ClearMem()
+	var inputs [4]uint16 = [4]uint16{0x0092, 0x0091, 0xFF45, 0x00AF}
+
+	var instruction uint16 = 0x0170
+	for a := 0; a < 4; a++ {
+		for b := 0; b < 4; b++ {
+			testSUB(instruction, inputs, a, b, t)
+			instruction++
+		}
+	}
+}
+
+func testSUB(instruction uint16, inputRegisters [4]uint16, a, b int, t *testing.T) {
+	c := SetUpCPU()
+
+	setMemoryLocation(c, 0x0000, instruction)
+	setRegisters(c, inputRegisters)
+	c.SetIAR(0x0000)
+
+	doFetchDecodeExecute(c)
+
+	expectedRegisters := inputRegisters
+	expectedRegisters[b] = inputRegisters[a] - inputRegisters[b]
+	checkRegisters(c, expectedRegisters[0], expectedRegisters[1], expectedRegisters[2], expectedRegisters[3], t)
+	checkFlagsRegister(c, inputRegisters[a] < inputRegisters[b], inputRegisters[a] > inputRegisters[b], inputRegisters[a] == inputRegisters[b], expectedRegisters[b] == 0, t)
+}
+
+func TestSUBWithBorrow(t *testing.T) {
+	ClearMem()
+	c := SetUpCPU()
+
+	setMemoryLocation(c, 0x0000, 0x0171) // SUB R0, R1
+	setMemoryLocation(c, 0x0001, 0x0171) // SUB R0, R1
+
+	c.SetIAR(0x0000)
+
+	setRegisters(c, [4]uint16{0x0001, 0x0002, 0x0000, 0x0000})
+	doFetchDecodeExecute(c)
+	checkRegisters(c, 0x0001, 0xFFFF, 0x0000, 0x0000, t)
+
+	setRegisters(c, [4]uint16{0x0005, 0x0002, 0x0000, 0x0000}) // the borrow is taken off the result
+	doFetchDecodeExecute(c)
+	checkRegisters(c, 0x0005, 0x0002, 0x0000, 0x0000, t)
+}
+
+func TestMOV(t *testing.T) {
+	ClearMem()
+	var inputs [4]uint16 = [4]uint16{0x0001, 0x0002, 0x0003, 0x0004}
+
+	var instruction uint16 = 0x0180
+	for a := 0; a < 4; a++ {
+		for b := 0; b < 4; b++ {
+			expectedRegisters := inputs
+			expectedRegisters[b] = inputs[a]
+			testInstruction(instruction, inputs, expectedRegisters, t)
+			instruction++
+		}
+	}
+}
+
+func TestINC(t *testing.T) {
+	ClearMem()
+	var inputs [4]uint16 = [4]uint16{0x0000, 0x00FF, 0xFFFE, 0xFFFF}
+	testInstruction(0x0190, inputs, [4]uint16{0x0001, 0x00FF, 0xFFFE, 0xFFFF}, t)
+	testInstruction(0x0191, inputs, [4]uint16{0x0000, 0x0100, 0xFFFE, 0xFFFF}, t)
+	testInstruction(0x0192, inputs, [4]uint16{0x0000, 0x00FF, 0xFFFF, 0xFFFF}, t)
+	testInstruction(0x0193, inputs, [4]uint16{0x0000, 0x00FF, 0xFFFE, 0x0000}, t)
+}
+
+func TestDEC(t *testing.T) {
+	ClearMem()
+	var inputs [4]uint16 = [4]uint16{0x0000, 0x0100, 0x0001, 0xFFFF}
+	testInstruction(0x01A0, inputs, [4]uint16{0xFFFF, 0x0100, 0x0001, 0xFFFF}, t)
+	testInstruction(0x01A1, inputs, [4]uint16{0x0000, 0x00FF, 0x0001, 0xFFFF}, t)
+	testInstruction(0x01A2, inputs, [4]uint16{0x0000, 0x0100, 0x0000, 0xFFFF}, t)
+	testInstruction(0x01A3, inputs, [4]uint16{0x0000, 0x0100, 0x0001, 0xFFFE}, t)
+}
+
+func TestINCAndDECSetFlags(t *testing.T) {
+	ClearMem()
+	c := SetUpCPU()
+
+	setMemoryLocation(c, 0x0000, 0x0190) // INC R0
+	setMemoryLocation(c, 0x0001, 0x01A0) // DEC R0
+	setRegisters(c, [4]uint16{0xFFFF, 0x0000, 0x0000, 0x0000})
+	c.SetIAR(0x0000)
+
+	doFetchDecodeExecute(c)
+	checkRegister(c, 0, 0x0000, t)
+	checkFlagsRegister(c, true, true, false, true, t)
+
+	doFetchDecodeExecute(c)
+	checkRegister(c, 0, 0xFFFF, t)
+	checkFlagsRegister(c, true, false, false, false, t)
+}
+
 func TestSubtract(t *testing.T) {
 	ClearMem()
 	testSubtract(0, 0, t)
@@ -939,6 +1037,423 @@ func testMultiply(inputA, inputB uint16, t *testing.T) {
 	checkRegister(c, 2, inputA*inputB, t)
 }
 
+func TestPUSH(t *testing.T) {
+	ClearMem()
+	testPUSH(0x0100, [4]uint16{0x00AB, 0x0001, 0x0002, 0x0003}, 0x00AB, t)
+	testPUSH(0x0101, [4]uint16{0x0000, 0xF0AB, 0x0002, 0x0003}, 0xF0AB, t)
+	testPUSH(0x0102, [4]uint16{0x0000, 0x0001, 0x12AB, 0x0003}, 0x12AB, t)
+	testPUSH(0x0103, [4]uint16{0x0000, 0x0001, 0x0002, 0xFFFF}, 0xFFFF, t)
+}
+
+func testPUSH(instruction uint16, inputRegisters [4]uint16, expectedValue uint16, t *testing.T) {
+	c := SetUpCPU()
+
+	setMemoryLocation(c, 0x0000, instruction)
+	setRegisters(c, inputRegisters)
+
+	c.SetIAR(0x0000)
+	c.SetSP(0x0200)
+
+	doFetchDecodeExecute(c)
+
+	// registers shouldn't change
+	checkRegisters(c, inputRegisters[0], inputRegisters[1], inputRegisters[2], inputRegisters[3], t)
+	checkIAR(c, 0x0001, t)
+	checkSP(c, 0x01FF, t)
+	checkMemoryLocation(c, 0x01FF, expectedValue, t)
+}
+
+func TestPOP(t *testing.T) {
+	ClearMem()
+	zeros := [4]uint16{0, 0, 0, 0}
+	testPOP(0x0110, zeros, [4]uint16{0xCAFE, 0x0000, 0x0000, 0x0000}, t)
+	testPOP(0x0111, zeros, [4]uint16{0x0000, 0xCAFE, 0x0000, 0x0000}, t)
+	testPOP(0x0112, zeros, [4]uint16{0x0000, 0x0000, 0xCAFE, 0x0000}, t)
+	testPOP(0x0113, zeros, [4]uint16{0x0000, 0x0000, 0x0000, 0xCAFE}, t)
+}
+
+func testPOP(instruction uint16, inputRegisters, expectedRegisters [4]uint16, t *testing.T) {
+	c := SetUpCPU()
+
+	setMemoryLocation(c, 0x0000, instruction)
+	setMemoryLocation(c, 0x01FF, 0xCAFE)
+	setRegisters(c, inputRegisters)
+
+	c.SetIAR(0x0000)
+	c.SetSP(0x01FF)
+
+	doFetchDecodeExecute(c)
+
+	checkRegisters(c, expectedRegisters[0], expectedRegisters[1], expectedRegisters[2], expectedRegisters[3], t)
+	checkIAR(c, 0x0001, t)
+	checkSP(c, 0x0200, t)
+}
+
+func TestPUSHThenPOPReversesOrder(t *testing.T) {
+	ClearMem()
+	c := SetUpCPU()
+
+	setMemoryLocation(c, 0x0000, 0x0100) // PUSH R0
+	setMemoryLocation(c, 0x0001, 0x0101) // PUSH R1
+	setMemoryLocation(c, 0x0002, 0x0102) // PUSH R2
+	setMemoryLocation(c, 0x0003, 0x0103) // PUSH R3
+	setMemoryLocation(c, 0x0004, 0x0110) // POP R0
+	setMemoryLocation(c, 0x0005, 0x0111) // POP R1
+	setMemoryLocation(c, 0x0006, 0x0112) // POP R2
+	setMemoryLocation(c, 0x0007, 0x0113) // POP R3
+
+	setRegisters(c, [4]uint16{0x000A, 0x000B, 0x000C, 0x000D})
+	c.SetIAR(0x0000)
+	c.SetSP(0xFEFE)
+
+	for i := 0; i < 4; i++ {
+		doFetchDecodeExecute(c)
+	}
+	checkSP(c, 0xFEFA, t)
+
+	for i := 0; i < 4; i++ {
+		doFetchDecodeExecute(c)
+	}
+
+	checkRegisters(c, 0x000D, 0x000C, 0x000B, 0x000A, t)
+	checkSP(c, 0xFEFE, t)
+	checkIAR(c, 0x0008, t)
+}
+
+func TestCALLAndRET(t *testing.T) {
+	ClearMem()
+	c := SetUpCPU()
+
+	setMemoryLocation(c, 0x0000, 0x0120) // CALL
+	setMemoryLocation(c, 0x0001, 0x0050) // ...addr 0x0050
+	setMemoryLocation(c, 0x0050, 0x0130) // RET
+
+	inputRegisters := [4]uint16{0x0001, 0x0002, 0x0003, 0x0004}
+	setRegisters(c, inputRegisters)
+	c.SetIAR(0x0000)
+	c.SetSP(0x0400)
+
+	doFetchDecodeExecute(c)
+
+	checkIAR(c, 0x0050, t)
+	checkSP(c, 0x03FF, t)
+	checkMemoryLocation(c, 0x03FF, 0x0002, t)
+
+	doFetchDecodeExecute(c)
+
+	checkIAR(c, 0x0002, t)
+	checkSP(c, 0x0400, t)
+
+	// registers shouldn't change
+	checkRegisters(c, inputRegisters[0], inputRegisters[1], inputRegisters[2], inputRegisters[3], t)
+}
+
+func TestCALLUsesExtendedSteps(t *testing.T) {
+	ClearMem()
+	c := SetUpCPU()
+
+	setMemoryLocation(c, 0x0000, 0x0120) // CALL
+	setMemoryLocation(c, 0x0001, 0x0050) // ...addr 0x0050
+	c.SetIAR(0x0000)
+	c.SetSP(0x0400)
+
+	for i := 0; i < 6; i++ {
+		c.Step()
+	}
+
+	if !c.HasExtendedSteps() {
+		t.Log("Expected CALL to continue into the extended steps")
+		t.FailNow()
+	}
+
+	for i := 0; i < 6; i++ {
+		c.Step()
+	}
+
+	if c.HasExtendedSteps() {
+		t.Log("Expected CALL to have completed after the extended steps")
+		t.FailNow()
+	}
+	checkIAR(c, 0x0050, t)
+}
+
+func TestNestedCALL(t *testing.T) {
+	ClearMem()
+	c := SetUpCPU()
+
+	// main
+	setMemoryLocation(c, 0x0000, 0x0120) // CALL
+	setMemoryLocation(c, 0x0001, 0x0020) // ...outer
+	setMemoryLocation(c, 0x0002, 0x0023) // DATA R3
+	setMemoryLocation(c, 0x0003, 0x0004) // ...4
+
+	// outer
+	setMemoryLocation(c, 0x0020, 0x0020) // DATA R0
+	setMemoryLocation(c, 0x0021, 0x0001) // ...1
+	setMemoryLocation(c, 0x0022, 0x0120) // CALL
+	setMemoryLocation(c, 0x0023, 0x0040) // ...middle
+	setMemoryLocation(c, 0x0024, 0x0130) // RET
+
+	// middle
+	setMemoryLocation(c, 0x0040, 0x0120) // CALL
+	setMemoryLocation(c, 0x0041, 0x0060) // ...inner
+	setMemoryLocation(c, 0x0042, 0x0022) // DATA R2
+	setMemoryLocation(c, 0x0043, 0x0003) // ...3
+	setMemoryLocation(c, 0x0044, 0x0130) // RET
+
+	// inner
+	setMemoryLocation(c, 0x0060, 0x0021) // DATA R1
+	setMemoryLocation(c, 0x0061, 0x0002) // ...2
+	setMemoryLocation(c, 0x0062, 0x0130) // RET
+
+	setRegisters(c, [4]uint16{0, 0, 0, 0})
+	c.SetIAR(0x0000)
+	c.SetSP(0x0400)
+
+	for i := 0; i < 100; i++ {
+		doFetchDecodeExecute(c)
+		if c.iar.Value() == 0x0004 {
+			break
+		}
+	}
+
+	checkIAR(c, 0x0004, t)
+	checkRegisters(c, 0x0001, 0x0002, 0x0003, 0x0004, t)
+	checkSP(c, 0x0400, t)
+
+	// return addresses are left behind on the stack
+	checkMemoryLocation(c, 0x03FF, 0x0002, t)
+	checkMemoryLocation(c, 0x03FE, 0x0024, t)
+	checkMemoryLocation(c, 0x03FD, 0x0042, t)
+}
+
+func TestEIAndDI(t *testing.T) {
+	ClearMem()
+	c := SetUpCPU()
+
+	setMemoryLocation(c, 0x0000, 0x0140) // EI
+	setMemoryLocation(c, 0x0001, 0x0150) // DI
+	c.SetIAR(0x0000)
+
+	if c.InterruptsEnabled() {
+		t.Log("Expected interrupts to be disabled at start up")
+		t.FailNow()
+	}
+
+	doFetchDecodeExecute(c)
+
+	if !c.InterruptsEnabled() {
+		t.Log("Expected EI to enable interrupts")
+		t.FailNow()
+	}
+
+	doFetchDecodeExecute(c)
+
+	if c.InterruptsEnabled() {
+		t.Log("Expected DI to disable interrupts")
+		t.FailNow()
+	}
+	checkIAR(c, 0x0002, t)
+}
+
+func TestHALTStopsTheStepper(t *testing.T) {
+	ClearMem()
+	c := SetUpCPU()
+
+	setMemoryLocation(c, 0x0000, 0x01B0) // HALT
+	setMemoryLocation(c, 0x0001, 0x0020) // DATA R0
+	setMemoryLocation(c, 0x0002, 0x0001) // ...1
+	setRegisters(c, [4]uint16{0x0003, 0, 0, 0})
+	c.SetIAR(0x0000)
+
+	for i := 0; i < 3; i++ {
+		c.Step()
+	}
+	if c.Halted() {
+		t.Log("Expected the CPU not to halt before step 4 of HALT")
+		t.FailNow()
+	}
+
+	for i := 0; i < 60; i++ {
+		c.Step()
+	}
+
+	if !c.Halted() {
+		t.Log("Expected HALT to halt the CPU")
+		t.FailNow()
+	}
+	if c.State().Steps != 4 {
+		t.Logf("Expected the stepper to stop at step 4 but it is at step %d", c.State().Steps)
+		t.FailNow()
+	}
+	checkIAR(c, 0x0001, t)
+	checkRegisters(c, 0x0003, 0, 0, 0, t)
+}
+
+func TestInterruptSavesAndRestoresIARAndFlags(t *testing.T) {
+	ClearMem()
+	c := SetUpCPU()
+	c.ConnectPeripheral(io.NewInterruptController())
+	peripheral := NewInterruptingPeripheral(2)
+	c.ConnectPeripheral(peripheral)
+
+	setMemoryLocation(c, 0x0000, 0x0020) // DATA R0
+	setMemoryLocation(c, 0x0001, 0x0001) // ...interrupt controller
+	setMemoryLocation(c, 0x0002, 0x007C) // OUT Addr, R0
+	setMemoryLocation(c, 0x0003, 0x0021) // DATA R1
+	setMemoryLocation(c, 0x0004, 0x0004) // ...unmask line 2
+	setMemoryLocation(c, 0x0005, 0x0079) // OUT Data, R1
+	setMemoryLocation(c, 0x0006, 0x00F4) // CMP R1, R0
+	setMemoryLocation(c, 0x0007, 0x0140) // EI
+	setMemoryLocation(c, 0x0008, 0x0060) // CLF
+	setMemoryLocation(c, 0x0482, 0x0050) // vector for line 2
+	setMemoryLocation(c, 0x0050, 0x0160) // IRET
+
+	c.SetIAR(0x0000)
+
+	for i := 0; i < 5; i++ {
+		doFetchDecodeExecute(c)
+	}
+	checkFlagsRegister(c, false, true, false, false, t)
+
+	peripheral.raised = true
+
+	// EI, then the interrupt is taken before CLF
+	doFetchDecodeExecute(c)
+
+	checkIAR(c, 0x0050, t)
+	if c.InterruptsEnabled() {
+		t.Log("Expected interrupts to be disabled in the interrupt handler")
+		t.FailNow()
+	}
+	if c.savedIAR.Value() != 0x0008 {
+		t.Logf("Expected saved IAR to have value of: %X but got %X", 0x0008, c.savedIAR.Value())
+		t.FailNow()
+	}
+
+	peripheral.raised = false
+
+	// IRET
+	doFetchDecodeExecute(c)
+
+	checkIAR(c, 0x0008, t)
+	checkFlagsRegister(c, false, true, false, false, t)
+	if !c.InterruptsEnabled() {
+		t.Log("Expected IRET to enable interrupts")
+		t.FailNow()
+	}
+
+	// CLF
+	doFetchDecodeExecute(c)
+
+	checkIAR(c, 0x0009, t)
+	checkFlagsRegister(c, false, false, false, false, t)
+}
+
+func TestInterruptNotTakenWhenDisabledOrMasked(t *testing.T) {
+	ClearMem()
+	c := SetUpCPU()
+	c.ConnectPeripheral(io.NewInterruptController())
+	peripheral := NewInterruptingPeripheral(2)
+	c.ConnectPeripheral(peripheral)
+	peripheral.raised = true
+
+	setMemoryLocation(c, 0x0000, 0x0140) // EI
+	setMemoryLocation(c, 0x0482, 0x0050) // vector for line 2
+
+	c.SetIAR(0x0000)
+
+	// line 2 is raised but masked
+	doFetchDecodeExecute(c)
+	doFetchDecodeExecute(c)
+
+	checkIAR(c, 0x0002, t)
+}
+
+func TestStateRestoresBetweenAnySteps(t *testing.T) {
+	program := map[uint16]uint16{
+		0x0000: 0x0020, // DATA R0
+		0x0001: 0x0001, // ...interrupt controller
+		0x0002: 0x007C, // OUT Addr, R0
+		0x0003: 0x0021, // DATA R1
+		0x0004: 0x0004, // ...unmask line 2
+		0x0005: 0x0079, // OUT Data, R1
+		0x0006: 0x0140, // EI
+		0x0007: 0x0020, // DATA R0
+		0x0008: 0xFFFF, // ...0xFFFF
+		0x0009: 0x0081, // ADD R0, R1
+		0x000A: 0x0081, // ADD R0, R1 with the carry
+		0x000B: 0x0120, // CALL
+		0x000C: 0x0020, // ...addr 0x0020
+		0x000D: 0x0058, // JMPF C
+		0x000E: 0x0007, // ...addr 0x0007
+		0x000F: 0x0040, // JMP
+		0x0010: 0x0007, // ...addr 0x0007
+		0x0020: 0x0101, // PUSH R1
+		0x0021: 0x0171, // SUB R0, R1
+		0x0022: 0x0112, // POP R2
+		0x0023: 0x0130, // RET
+		0x0030: 0x0160, // IRET
+		0x0482: 0x0030, // vector for line 2
+	}
+
+	var cpus []*CPU
+	var controllers []*io.InterruptController
+	var peripherals []*InterruptingPeripheral
+	for i := 0; i < 2; i++ {
+		bus := components.NewBus(BUS_WIDTH)
+		c := NewCPU(bus, memory.NewMemory64K(bus))
+		controller := io.NewInterruptController()
+		c.ConnectPeripheral(controller)
+		peripheral := NewInterruptingPeripheral(2)
+		c.ConnectPeripheral(peripheral)
+
+		for address, value := range program {
+			c.WriteMemory(address, value)
+		}
+		c.SetIAR(0x0000)
+		c.SetSP(0x0400)
+
+		cpus = append(cpus, c)
+		controllers = append(controllers, controller)
+		peripherals = append(peripherals, peripheral)
+	}
+	c, restored := cpus[0], cpus[1]
+
+	// restore the second CPU from the first every 13 steps, so every step of the stepper is
+	// restored from, then check they stay the same. The second CPU is moved on a few steps
+	// first so none of its gates are left over from the state it is restored to
+	for steps := 0; steps < 400; steps++ {
+		if steps%13 == 0 {
+			for i := 0; i < 1+steps%5; i++ {
+				restored.Step()
+			}
+			restored.SetState(c.State())
+			controllers[1].SetState(controllers[0].State())
+			for address := uint16(0x03F0); address < 0x0400; address++ {
+				restored.WriteMemory(address, c.ReadMemory(address))
+			}
+		}
+
+		// an interrupt is raised in the middle of an instruction
+		for _, p := range peripherals {
+			p.raised = steps >= 200 && steps < 212
+		}
+		c.Step()
+		restored.Step()
+
+		if expected, actual := c.State(), restored.State(); expected != actual {
+			t.Logf("Step %d: expected the restored CPU to be in state\n%+v\nbut got\n%+v", steps, expected, actual)
+			t.FailNow()
+		}
+	}
+
+	if c.savedIAR.Value() == 0x0000 || c.ReadMemory(0x03FF) == 0x0000 {
+		t.Log("Expected the program to have taken the interrupt and called the routine")
+		t.FailNow()
+	}
+}
+
 func TestIOInputInstruction(t *testing.T) {
 	ClearMem()
 	// IN Data, RB
@@ -1076,9 +1591,36 @@ func (p *DumbPeripheral) updateSet() {
 	}
 }
 
+// Peripheral that raises its interrupt line while raised is true
+type InterruptingPeripheral struct {
+	ioBus  *components.IOBus
+	line   int
+	raised bool
+}
+
+func NewInterruptingPeripheral(line int) *InterruptingPeripheral {
+	p := new(InterruptingPeripheral)
+	p.line = line
+	return p
+}
+
+func (p *InterruptingPeripheral) Connect(ioBus *components.IOBus, mainBus *components.Bus) {
+	p.ioBus = ioBus
+}
+
+func (p *InterruptingPeripheral) Update() {
+	p.ioBus.UpdateInterruptLine(p.line, p.raised)
+}
+
 func doFetchDecodeExecute(c *CPU) {
-	for i := 0; i < 6; i++ {
-		c.Step()
+	for {
+		for i := 0; i < 6; i++ {
+			c.Step()
+		}
+
+		if !c.HasExtendedSteps() {
+			break
+		}
 	}
 }
 
@@ -1154,6 +1696,40 @@ func checkIAR(c *CPU, expValue uint16, t *testing.T) {
 	}
 }
 
+func checkSP(c *CPU, expValue uint16, t *testing.T) {
+	if c.sp.Value() != expValue {
+		t.Logf("Expected SP to have value of: %X but got %X", expValue, c.sp.Value())
+		t.FailNow()
+	}
+}
+
+func checkMemoryLocation(c *CPU, address uint16, expValue uint16, t *testing.T) {
+	c.memory.AddressRegister.Set()
+	c.mainBus.SetValue(address)
+	c.memory.Update()
+
+	c.memory.AddressRegister.Unset()
+	c.memory.Update()
+
+	c.memory.Enable()
+	c.memory.Update()
+	c.memory.Disable()
+	c.memory.Update()
+
+	var value uint16
+	for i := 0; i < BUS_WIDTH; i++ {
+		if c.mainBus.GetOutputWire(BUS_WIDTH - 1 - i) {
+			value = value | (1 << uint16(i))
+		}
+	}
+	c.clearMainBus()
+
+	if value != expValue {
+		t.Logf("Expected memory location %X to have value of: %X but got %X", address, expValue, value)
+		t.FailNow()
+	}
+}
+
 func checkIR(c *CPU, expValue uint16, t *testing.T) {
 	if c.ir.Value() != expValue {
 		t.Logf("Expected IR to have value of: %X but got %X", expValue, c.ir.Value())
@@ -0,0 +1,71 @@
+package cpu
+
+import (
+	"github.com/djhworld/simple-computer/io"
+)
+
+// every round of the stepper has 6 steps, the stepper position is the step that runs next
+const STEPS_PER_ROUND = 6
+
+// Processor is the model of the CPU the computer runs. CPU simulates every gate of the
+// CPU and its memory, the behavioural package has a model that runs the same instruction set
+// an instruction at a time. Both models take the same number of steps for every instruction
+// and are in the same state at the start of every instruction
+type Processor interface {
+	ConnectPeripheral(p io.Peripheral)
+	Step()
+
+	HasExtendedSteps() bool
+	AtInstructionStart() bool
+	StepperPosition() int
+	InterruptsEnabled() bool
+	Halted() bool
+
+	IAR() uint16
+	SetIAR(address uint16)
+	SP() uint16
+	SetSP(address uint16)
+	MAR() uint16
+	SetMAR(address uint16)
+	Register(index int) uint16
+	SetRegister(index int, value uint16)
+	Flags() (carry, aLarger, equal, zero bool)
+	SetFlags(carry, aLarger, equal, zero bool)
+
+	ReadMemory(address uint16) uint16
+	WriteMemory(address, value uint16)
+
+	State() State
+	SetState(s State)
+
+	String() string
+}
+
+// State is everything a Processor holds outside of its RAM, including the latches that carry
+// an instruction from one step to the next, so a processor can be restored between any two steps.
+// TMP, ACC and CarryTemp are only used by the gate level CPU
+type State struct {
+	Registers [4]uint16
+	IAR       uint16
+	IR        uint16
+	SP        uint16
+	MAR       uint16
+	TMP       uint16
+	ACC       uint16
+	SavedIAR  uint16
+
+	// carry, A larger, equal and zero
+	Flags      [4]bool
+	SavedFlags [4]bool
+	CarryTemp  bool
+
+	InterruptsEnabled bool
+	Extended          bool
+	ExtendNext        bool
+	Interrupting      bool
+	InterruptNext     bool
+	Halted            bool
+
+	// the number of steps of the current round of the stepper that have run, 0 - 6
+	Steps int
+}
@@ -0,0 +1,415 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/djhworld/simple-computer/computer"
+)
+
+const PROMPT = "(debug) "
+
+// how many words are printed by mem when no count is given, and per line
+const MEMORY_WORDS = 8
+
+const HELP = `commands:
+  continue, c               run until a breakpoint is hit or pause is entered
+  pause                     pause the computer
+  step, s [n]               run n instructions (default 1)
+  tick, t [n]               run n steps of the stepper (default 1)
+  break, b <addr|label>     set a breakpoint, the computer pauses before fetching the instruction
+  delete, d <addr|label>    remove a breakpoint
+  breakpoints               list the breakpoints
+  regs, r                   print R0 - R3, FLAGS, IAR, MAR and SP
+  set <reg> <value>         set R0 - R3, IAR, MAR or FLAGS (flags as letters, e.g. CZ or -)
+  mem, m <addr|label> [n]   print n words of RAM (default 8)
+  poke <addr|label> <value>...  write values to RAM starting at addr
+  state                     print the full state of the CPU
+  help, h                   print this message
+an empty line repeats the last command, commands other than pause that are entered while
+the computer is running are run when it next pauses`
+
+// Debugger is a REPL that controls the computer through its step hook. Commands are read
+// on a separate goroutine but only ever run in between two steps of the computer, so the
+// state is never inspected or changed while the CPU is half way through updating it
+type Debugger struct {
+	out      io.Writer
+	commands chan string
+	pending  []string
+	detached bool
+
+	breakpoints map[uint16]bool
+
+	started     bool
+	paused      bool
+	lastCommand string
+
+	// the number of steps the computer has taken since it was last resumed
+	stepsSinceResume int
+	// pause once this many more instructions have completed (step) or steps have been taken (tick)
+	instructionsToRun int
+	stepsToRun        int
+}
+
+// NewDebugger returns a debugger reading commands from in, the computer starts paused.
+// If the computer has debug info its labels can be used as breakpoints and addresses are
+// shown with their label and source line
+func NewDebugger(in io.Reader, out io.Writer) *Debugger {
+	d := new(Debugger)
+	d.out = out
+	d.commands = make(chan string)
+	d.breakpoints = make(map[uint16]bool)
+
+	go d.readCommands(in)
+	return d
+}
+
+func (d *Debugger) readCommands(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		d.commands <- scanner.Text()
+	}
+	close(d.commands)
+}
+
+// BeforeStep is called by the computer before every step, it blocks for as long as the debugger is paused
+func (d *Debugger) BeforeStep(c *computer.SimpleComputer) {
+	if !d.started {
+		d.started = true
+		d.pause(c, "")
+	}
+
+	if !d.paused && d.stepsSinceResume > 0 {
+		d.checkForPause(c)
+	}
+
+	if !d.paused && d.commands != nil {
+		d.readWhileRunning(c)
+	}
+
+	for d.paused {
+		fmt.Fprint(d.out, PROMPT)
+		if len(d.pending) > 0 {
+			command := d.pending[0]
+			d.pending = d.pending[1:]
+			d.handle(c, command)
+		} else if d.commands == nil {
+			// stdin has gone away, let the computer run without the debugger
+			d.detached = true
+			d.paused = false
+		} else if command, ok := <-d.commands; ok {
+			d.handle(c, command)
+		} else {
+			d.commands = nil
+		}
+	}
+
+	d.stepsSinceResume++
+}
+
+// readWhileRunning only acts on pause, any other command typed while the computer is
+// running is kept until it next pauses
+func (d *Debugger) readWhileRunning(c *computer.SimpleComputer) {
+	select {
+	case command, ok := <-d.commands:
+		if !ok {
+			d.commands = nil
+		} else if strings.TrimSpace(command) == "pause" {
+			d.handle(c, command)
+		} else {
+			d.pending = append(d.pending, command)
+		}
+	default:
+	}
+}
+
+func (d *Debugger) checkForPause(c *computer.SimpleComputer) {
+	if d.stepsToRun > 0 && d.stepsSinceResume >= d.stepsToRun {
+		d.pause(c, "")
+		return
+	}
+
+	if !c.CPU().AtInstructionStart() {
+		return
+	}
+
+	if d.breakpoints[c.IAR()] {
+		d.pause(c, "breakpoint ")
+		return
+	}
+
+	if d.instructionsToRun > 0 {
+		d.instructionsToRun--
+		if d.instructionsToRun == 0 {
+			d.pause(c, "")
+		}
+	}
+}
+
+func (d *Debugger) pause(c *computer.SimpleComputer, reason string) {
+	if d.detached {
+		return
+	}
+	d.paused = true
+	d.instructionsToRun = 0
+	d.stepsToRun = 0
+	fmt.Fprintf(d.out, "%spaused at %s\n", reason, d.location(c))
+}
+
+func (d *Debugger) resume() {
+	d.paused = false
+	d.stepsSinceResume = 0
+}
+
+func (d *Debugger) handle(c *computer.SimpleComputer, command string) {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		command = d.lastCommand
+	}
+	d.lastCommand = command
+
+	if err := d.execute(c, command); err != nil {
+		fmt.Fprintln(d.out, "error:", err)
+	}
+}
+
+func (d *Debugger) execute(c *computer.SimpleComputer, command string) error {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil
+	}
+	args := fields[1:]
+
+	switch strings.ToLower(fields[0]) {
+	case "continue", "c":
+		d.resume()
+	case "pause":
+		d.pause(c, "")
+	case "step", "s":
+		n, err := countArgument(args)
+		if err != nil {
+			return err
+		}
+		d.resume()
+		d.instructionsToRun = n
+	case "tick", "t":
+		n, err := countArgument(args)
+		if err != nil {
+			return err
+		}
+		d.resume()
+		d.stepsToRun = n
+	case "break", "b":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: break <addr|label>")
+		}
+		address, err := d.parseAddress(c, args[0])
+		if err != nil {
+			return err
+		}
+		d.breakpoints[address] = true
+		fmt.Fprintf(d.out, "breakpoint at %s\n", c.Describe(address))
+	case "delete", "d":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: delete <addr|label>")
+		}
+		address, err := d.parseAddress(c, args[0])
+		if err != nil {
+			return err
+		}
+		if !d.breakpoints[address] {
+			return fmt.Errorf("no breakpoint at %s", c.Describe(address))
+		}
+		delete(d.breakpoints, address)
+	case "breakpoints":
+		d.printBreakpoints(c)
+	case "regs", "r":
+		d.printRegisters(c)
+	case "set":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: set <R0-R3|IAR|MAR|FLAGS> <value>")
+		}
+		return d.setRegister(c, strings.ToUpper(args[0]), args[1])
+	case "mem", "m":
+		if len(args) < 1 || len(args) > 2 {
+			return fmt.Errorf("usage: mem <addr|label> [n]")
+		}
+		address, err := d.parseAddress(c, args[0])
+		if err != nil {
+			return err
+		}
+		n := MEMORY_WORDS
+		if len(args) == 2 {
+			if n, err = countArgument(args[1:]); err != nil {
+				return err
+			}
+		}
+		d.printMemory(c, address, n)
+	case "poke":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: poke <addr|label> <value>...")
+		}
+		address, err := d.parseAddress(c, args[0])
+		if err != nil {
+			return err
+		}
+		for i, arg := range args[1:] {
+			value, err := parseValue(arg)
+			if err != nil {
+				return err
+			}
+			c.WriteMemory(address+uint16(i), value)
+		}
+	case "state":
+		fmt.Fprintf(d.out, "LOCATION: %s\n%s\n", d.location(c), c.CPU().String())
+	case "help", "h":
+		fmt.Fprintln(d.out, HELP)
+	default:
+		return fmt.Errorf("unknown command '%s', type help for a list of commands", fields[0])
+	}
+	return nil
+}
+
+func (d *Debugger) setRegister(c *computer.SimpleComputer, register, value string) error {
+	cpu := c.CPU()
+
+	if register == "FLAGS" {
+		carry, aLarger, equal, zero, err := parseFlags(value)
+		if err != nil {
+			return err
+		}
+		cpu.SetFlags(carry, aLarger, equal, zero)
+		return nil
+	}
+
+	v, err := parseValue(value)
+	if err != nil {
+		return err
+	}
+
+	switch register {
+	case "R0", "R1", "R2", "R3":
+		cpu.SetRegister(int(register[1]-'0'), v)
+	case "IAR":
+		cpu.SetIAR(v)
+	case "MAR":
+		cpu.SetMAR(v)
+	default:
+		return fmt.Errorf("unknown register '%s'", register)
+	}
+	return nil
+}
+
+func (d *Debugger) printRegisters(c *computer.SimpleComputer) {
+	cpu := c.CPU()
+	fmt.Fprintf(d.out, "R0: 0x%04X  R1: 0x%04X  R2: 0x%04X  R3: 0x%04X\n", cpu.Register(0), cpu.Register(1), cpu.Register(2), cpu.Register(3))
+	fmt.Fprintf(d.out, "FLAGS: %s  IAR: %s  MAR: 0x%04X  SP: 0x%04X\n", formatFlags(cpu.Flags()), c.Describe(cpu.IAR()), cpu.MAR(), cpu.SP())
+}
+
+func (d *Debugger) printMemory(c *computer.SimpleComputer, address uint16, n int) {
+	for i := 0; i < n; i++ {
+		a := address + uint16(i)
+		if i%MEMORY_WORDS == 0 {
+			if i > 0 {
+				fmt.Fprintln(d.out)
+			}
+			fmt.Fprintf(d.out, "0x%04X:", a)
+		}
+		fmt.Fprintf(d.out, " 0x%04X", c.ReadMemory(a))
+	}
+	fmt.Fprintln(d.out)
+}
+
+func (d *Debugger) printBreakpoints(c *computer.SimpleComputer) {
+	if len(d.breakpoints) == 0 {
+		fmt.Fprintln(d.out, "no breakpoints")
+		return
+	}
+
+	addresses := []int{}
+	for address := range d.breakpoints {
+		addresses = append(addresses, int(address))
+	}
+	sort.Ints(addresses)
+
+	for _, address := range addresses {
+		fmt.Fprintln(d.out, c.Describe(uint16(address)))
+	}
+}
+
+// location describes where the computer is, including the step of the stepper if it is half way through an instruction
+func (d *Debugger) location(c *computer.SimpleComputer) string {
+	cpu := c.CPU()
+	if cpu.AtInstructionStart() {
+		return c.Describe(cpu.IAR())
+	}
+	return fmt.Sprintf("%s, stepper at step %d", c.Describe(cpu.IAR()), cpu.StepperPosition())
+}
+
+// parseAddress accepts a label or a number in any base understood by strconv (e.g. 0x0500)
+func (d *Debugger) parseAddress(c *computer.SimpleComputer, arg string) (uint16, error) {
+	if debugInfo := c.DebugInfo(); debugInfo != nil {
+		if address, ok := debugInfo.Labels[arg]; ok {
+			return address, nil
+		}
+	}
+	address, err := strconv.ParseUint(arg, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not a label or a 16 bit address", arg)
+	}
+	return uint16(address), nil
+}
+
+func parseValue(arg string) (uint16, error) {
+	value, err := strconv.ParseUint(arg, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not a 16 bit value", arg)
+	}
+	return uint16(value), nil
+}
+
+func countArgument(args []string) (int, error) {
+	if len(args) == 0 {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("'%s' is not a positive count", args[0])
+	}
+	return n, nil
+}
+
+// formatFlags prints the flags in the same order as the JMP[CAEZ] instructions, unset flags are shown as -
+func formatFlags(carry, aLarger, equal, zero bool) string {
+	flags := []byte("----")
+	for i, set := range []bool{carry, aLarger, equal, zero} {
+		if set {
+			flags[i] = "CAEZ"[i]
+		}
+	}
+	return string(flags)
+}
+
+func parseFlags(arg string) (carry, aLarger, equal, zero bool, err error) {
+	for _, flag := range strings.ToUpper(arg) {
+		switch flag {
+		case 'C':
+			carry = true
+		case 'A':
+			aLarger = true
+		case 'E':
+			equal = true
+		case 'Z':
+			zero = true
+		case '-':
+		default:
+			err = fmt.Errorf("unknown flag '%c', flags are any of CAEZ", flag)
+			return
+		}
+	}
+	return
+}
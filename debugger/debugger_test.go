@@ -0,0 +1,96 @@
+package debugger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/djhworld/simple-computer/asm"
+	"github.com/djhworld/simple-computer/computer"
+)
+
+const TEST_PROGRAM = `
+	DATA R0, 0x0001
+	DATA R1, 0x0000
+loop:
+	ADD R0, R1
+	JMP loop
+`
+
+func TestDebugger(t *testing.T) {
+	commands := strings.Join([]string{
+		"break loop",
+		"c",
+		"regs",
+		"c",
+		"",
+		"regs",
+		"delete loop",
+		"s",
+		"t 2",
+		"set R2 0x1234",
+		"set FLAGS CZ",
+		"poke 0x0600 0x00AA 0x00BB",
+		"mem 0x0600 2",
+		"regs",
+		"bogus",
+		"c",
+	}, "\n")
+
+	var out bytes.Buffer
+	c := newTestComputer(t)
+	c.SetStepHook(NewDebugger(strings.NewReader(commands), &out))
+	c.RunHeadless(computer.HeadlessConfig{MaxInstructions: 50})
+
+	output := out.String()
+
+	for _, expected := range []string{
+		"paused at 0x0500 (test.asm:2)\n",
+		"breakpoint at 0x0504 loop (test.asm:5)\n",
+		"breakpoint paused at 0x0504 loop (test.asm:5)\n",
+		"R0: 0x0001  R1: 0x0000  R2: 0xFFFF  R3: 0xFFFF\n",
+		"R0: 0x0001  R1: 0x0002  R2: 0xFFFF  R3: 0xFFFF\n",
+		"paused at 0x0505 loop+1 (test.asm:6)\n",
+		"paused at 0x0506 loop+2 (test.asm:6), stepper at step 3\n",
+		"0x0600: 0x00AA 0x00BB\n",
+		"R0: 0x0001  R1: 0x0003  R2: 0x1234  R3: 0xFFFF\nFLAGS: C--Z  IAR: 0x0506 loop+2 (test.asm:6)  MAR: 0x0505  SP: 0xFEFE\n",
+		"error: unknown command 'bogus'",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Logf("Expected output to contain %q but got:\n%s", expected, output)
+			t.FailNow()
+		}
+	}
+
+	if value := c.ReadMemory(0x0601); value != 0x00BB {
+		t.Logf("Expected poke to write 0x00BB to 0x0601 but got 0x%04X", value)
+		t.FailNow()
+	}
+}
+
+func newTestComputer(t *testing.T) *computer.SimpleComputer {
+	parser := asm.Parser{Filename: "test.asm"}
+	instructions, err := parser.Parse(strings.NewReader(TEST_PROGRAM))
+	if err != nil {
+		t.Logf("Error parsing program: %v", err)
+		t.FailNow()
+	}
+
+	assembler := asm.Assembler{}
+	bin, err := assembler.Process(computer.CODE_REGION_START, instructions)
+	if err != nil {
+		t.Logf("Error assembling program: %v", err)
+		t.FailNow()
+	}
+
+	debugInfo, err := assembler.DebugInfo(computer.CODE_REGION_START, instructions, parser.Positions())
+	if err != nil {
+		t.Logf("Error creating debug info: %v", err)
+		t.FailNow()
+	}
+
+	c := computer.NewComputer(nil, nil, computer.GATE_LEVEL_CPU)
+	c.LoadToRAM(computer.CODE_REGION_START, bin)
+	c.SetDebugInfo(debugInfo)
+	return c
+}
@@ -0,0 +1,315 @@
+package disassembler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/djhworld/simple-computer/asm"
+)
+
+// the return address the CALL pseudo instruction used to put in R3 before it jumped to the routine,
+// programs assembled before CALL was an instruction of its own still contain DATA R3 + JMP pairs
+const LEGACY_CALL_RETURN_REGISTER = asm.REG3
+
+// the most words of data put on one .word line
+const WORDS_PER_LINE = 8
+
+var registers = []asm.REGISTER{asm.REG0, asm.REG1, asm.REG2, asm.REG3}
+
+// the ALU instructions in the order of their op code (bits 9 - 11 of the instruction)
+const (
+	ALU_ADD = iota
+	ALU_SHR
+	ALU_SHL
+	ALU_NOT
+	ALU_AND
+	ALU_OR
+	ALU_XOR
+	ALU_CMP
+)
+
+type decoded struct {
+	address     uint16
+	size        int
+	instruction asm.Instruction
+	// the address a JMP, JMPF or CALL goes to, it is replaced with a label once all instructions are decoded
+	target    uint16
+	hasTarget bool
+}
+
+// Disassemble turns a program that is loaded at offset back into instructions that assemble to
+// the same words. The targets of jumps and calls are given a label, the labels from debugInfo are
+// used when they are available (debugInfo can be nil). Words that aren't an instruction, or jump
+// somewhere that isn't an instruction, are taken to be data and kept with .word
+func Disassemble(offset uint16, program []uint16, debugInfo *asm.DebugInfo) ([]asm.Instruction, error) {
+	decodedInstructions := []decoded{}
+	starts := map[uint16]bool{}
+
+	for i := 0; i < len(program); {
+		address := offset + uint16(i)
+		d, err := decode(address, program[i:])
+		if err != nil {
+			d = data(address, program[i:i+1])
+		}
+
+		decodedInstructions = append(decodedInstructions, d)
+		starts[address] = true
+		i += d.size
+	}
+
+	end := offset + uint16(len(program))
+	starts[end] = true
+
+	labels := map[uint16][]string{}
+	if debugInfo != nil {
+		for name, address := range debugInfo.Labels {
+			if starts[address] {
+				labels[address] = append(labels[address], name)
+			}
+		}
+		for _, names := range labels {
+			sort.Strings(names)
+		}
+	}
+
+	for n, d := range decodedInstructions {
+		if !d.hasTarget {
+			continue
+		}
+		if !starts[d.target] {
+			start := int(d.address - offset)
+			decodedInstructions[n] = data(d.address, program[start:start+d.size])
+			continue
+		}
+		if len(labels[d.target]) == 0 {
+			labels[d.target] = []string{fmt.Sprintf("L%04X", d.target)}
+		}
+	}
+
+	instructions := []asm.Instruction{}
+	for i := 0; i < len(decodedInstructions); i++ {
+		d := decodedInstructions[i]
+		for _, name := range labels[d.address] {
+			instructions = append(instructions, asm.DEFLABEL{name})
+		}
+
+		if i+1 < len(decodedInstructions) && isLegacyCall(d, decodedInstructions[i+1]) {
+			jump := decodedInstructions[i+1]
+			if len(labels[jump.address]) == 0 {
+				instructions = append(instructions, legacyCall(d, jump, labels[jump.target][0])...)
+				i++
+				continue
+			}
+		}
+
+		if words, ok := d.instruction.(asm.WORDS); ok && len(labels[d.address]) == 0 && len(instructions) > 0 {
+			if last, ok := instructions[len(instructions)-1].(asm.WORDS); ok && len(last.Values)+len(words.Values) <= WORDS_PER_LINE {
+				last.Values = append(last.Values, words.Values...)
+				instructions[len(instructions)-1] = last
+				continue
+			}
+		}
+
+		instructions = append(instructions, withLabel(d, labels))
+	}
+
+	for _, name := range labels[end] {
+		instructions = append(instructions, asm.DEFLABEL{name})
+	}
+
+	return instructions, nil
+}
+
+// Format renders the instructions as assembly source in the same layout as the generator
+func Format(instructions []asm.Instruction) string {
+	result := asm.Instructions{}
+	result.Add(instructions...)
+	return result.String()
+}
+
+// isLegacyCall is true for DATA R3, <address after the JMP> followed by a JMP
+func isLegacyCall(data, jump decoded) bool {
+	d, ok := data.instruction.(asm.DATA)
+	if !ok || d.ToRegister != LEGACY_CALL_RETURN_REGISTER {
+		return false
+	}
+	if _, ok := jump.instruction.(asm.JMP); !ok {
+		return false
+	}
+
+	returnAddress := d.Data.(asm.NUMBER).Value
+	return returnAddress == jump.address+uint16(jump.size)
+}
+
+// legacyCall keeps the DATA R3 + JMP pair so the program assembles to the same words, the
+// return address is given a symbol so it reads as a call
+func legacyCall(data, jump decoded, routine string) []asm.Instruction {
+	returnAddress := data.instruction.(asm.DATA).Data.(asm.NUMBER).Value
+	symbol := fmt.Sprintf("RETURN-%04X", returnAddress)
+
+	return []asm.Instruction{
+		asm.DEFSYMBOL{symbol, returnAddress},
+		asm.DATA{LEGACY_CALL_RETURN_REGISTER, asm.SYMBOL{symbol}},
+		asm.JMP{asm.LABEL{routine}},
+	}
+}
+
+func withLabel(d decoded, labels map[uint16][]string) asm.Instruction {
+	if !d.hasTarget {
+		return d.instruction
+	}
+
+	label := asm.LABEL{labels[d.target][0]}
+	switch ins := d.instruction.(type) {
+	case asm.JMP:
+		ins.JumpLoc = label
+		return ins
+	case asm.JMPF:
+		ins.JumpLoc = label
+		return ins
+	case asm.CALL:
+		ins.Routine = label
+		return ins
+	}
+	return d.instruction
+}
+
+// data keeps words as they are with a .word
+func data(address uint16, words []uint16) decoded {
+	w := asm.WORDS{}
+	for _, word := range words {
+		w.Values = append(w.Values, asm.NUMBER{word})
+	}
+	return decoded{address: address, size: len(words), instruction: w}
+}
+
+// decode decodes the instruction at the start of words, see the header of cpu.go for the encodings
+func decode(address uint16, words []uint16) (decoded, error) {
+	word := words[0]
+	d := decoded{address: address, size: 1}
+
+	a := registers[(word>>2)&0x3]
+	b := registers[word&0x3]
+
+	switch {
+	case word <= 0x000F:
+		d.instruction = asm.LOAD{a, b}
+	case word <= 0x001F:
+		d.instruction = asm.STORE{a, b}
+	case word >= 0x0020 && word <= 0x0023:
+		d.instruction = asm.DATA{b, asm.NUMBER{}}
+		d.size = 2
+	case word >= 0x0030 && word <= 0x0033:
+		d.instruction = asm.JR{b}
+	case word == 0x0040:
+		d.instruction = asm.JMP{}
+		d.size = 2
+		d.hasTarget = true
+	case word >= 0x0051 && word <= 0x005F:
+		d.instruction = asm.JMPF{flags(word), asm.LABEL{}}
+		d.size = 2
+		d.hasTarget = true
+	case word == 0x0060:
+		d.instruction = asm.CLF{}
+	case word >= 0x0070 && word <= 0x0077:
+		d.instruction = asm.IN{ioMode(word), b}
+	case word >= 0x0078 && word <= 0x007F:
+		d.instruction = asm.OUT{ioMode(word), b}
+	case word >= 0x0080 && word <= 0x00FF:
+		ins, err := decodeALU(word, a, b)
+		if err != nil {
+			return d, fmt.Errorf("%v at 0x%04X", err, address)
+		}
+		d.instruction = ins
+	case word >= 0x0100 && word <= 0x0103:
+		d.instruction = asm.PUSH{b}
+	case word >= 0x0110 && word <= 0x0113:
+		d.instruction = asm.POP{b}
+	case word == 0x0120:
+		d.instruction = asm.CALL{}
+		d.size = 2
+		d.hasTarget = true
+	case word == 0x0130:
+		d.instruction = asm.RET{}
+	case word == 0x0140:
+		d.instruction = asm.EI{}
+	case word == 0x0150:
+		d.instruction = asm.DI{}
+	case word == 0x0160:
+		d.instruction = asm.IRET{}
+	case word >= 0x0170 && word <= 0x017F:
+		d.instruction = asm.SUB{a, b}
+	case word >= 0x0180 && word <= 0x018F:
+		d.instruction = asm.MOV{a, b}
+	case word >= 0x0190 && word <= 0x0193:
+		d.instruction = asm.INC{b}
+	case word >= 0x01A0 && word <= 0x01A3:
+		d.instruction = asm.DEC{b}
+	case word == 0x01B0:
+		d.instruction = asm.HALT{}
+	default:
+		return d, fmt.Errorf("unknown instruction 0x%04X at 0x%04X", word, address)
+	}
+
+	if d.size == 2 {
+		if len(words) < 2 {
+			return d, fmt.Errorf("%s at 0x%04X is missing its second word", d.instruction, address)
+		}
+		if data, ok := d.instruction.(asm.DATA); ok {
+			data.Data = asm.NUMBER{words[1]}
+			d.instruction = data
+		} else {
+			d.target = words[1]
+		}
+	}
+
+	return d, nil
+}
+
+func decodeALU(word uint16, a, b asm.REGISTER) (asm.Instruction, error) {
+	op := (word >> 4) & 0x7
+	switch op {
+	case ALU_ADD:
+		return asm.ADD{a, b}, nil
+	case ALU_AND:
+		return asm.AND{a, b}, nil
+	case ALU_OR:
+		return asm.OR{a, b}, nil
+	case ALU_XOR:
+		return asm.XOR{a, b}, nil
+	case ALU_CMP:
+		return asm.CMP{a, b}, nil
+	}
+
+	// the assembler always uses the same register for A and B in single register ALU instructions
+	if a != b {
+		return nil, fmt.Errorf("single register instruction 0x%04X uses two different registers", word)
+	}
+
+	switch op {
+	case ALU_SHR:
+		return asm.SHR{a}, nil
+	case ALU_SHL:
+		return asm.SHL{a}, nil
+	default:
+		return asm.NOT{a}, nil
+	}
+}
+
+func flags(word uint16) []string {
+	result := []string{}
+	for i, flag := range strings.Split("CAEZ", "") {
+		if word&(0x8>>uint(i)) != 0 {
+			result = append(result, flag)
+		}
+	}
+	return result
+}
+
+func ioMode(word uint16) asm.IO_MODE {
+	if word&0x4 != 0 {
+		return asm.ADDRESS_MODE
+	}
+	return asm.DATA_MODE
+}
@@ -0,0 +1,202 @@
+package disassembler
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/djhworld/simple-computer/asm"
+	"github.com/djhworld/simple-computer/computer"
+)
+
+const EVERY_INSTRUCTION = `
+start:
+	LD R0, R1
+	ST R2, R3
+	DATA R1, 0x1234
+	JR R2
+	JMP end
+	JMPC start
+	JMPAEZ end
+	CLF
+	IN Data, R0
+	IN Addr, R1
+	OUT Data, R2
+	OUT Addr, R3
+	ADD R0, R1
+	SHR R1
+	SHL R2
+	NOT R3
+	AND R1, R0
+	OR R2, R1
+	XOR R3, R2
+	CMP R0, R3
+	PUSH R1
+	POP R2
+	CALL start
+	RET
+	EI
+	DI
+	IRET
+	SUB R1, R2
+	MOV R3, R0
+	INC R1
+	DEC R2
+	HALT
+end:
+`
+
+func TestRoundTripEveryInstruction(t *testing.T) {
+	bin, _ := assemble(t, EVERY_INSTRUCTION)
+	checkRoundTrip(t, bin, nil)
+}
+
+func TestRoundTripPrograms(t *testing.T) {
+	files, err := filepath.Glob("../_programs/*.bin")
+	if err != nil || len(files) == 0 {
+		t.Logf("Could not find any programs: %v", err)
+		t.FailNow()
+	}
+
+	for _, file := range files {
+		bin, err := computer.ReadBinFile(file)
+		if err != nil {
+			t.Logf("Error reading %s: %v", file, err)
+			t.FailNow()
+		}
+		checkRoundTrip(t, bin, nil)
+	}
+}
+
+func TestDisassembleWithDebugInfo(t *testing.T) {
+	bin, debugInfo := assemble(t, `
+	DATA R0, 0x0001
+main-loop:
+	ADD R0, R1
+	JMPZ done
+	JMP main-loop
+done:
+	CLF
+	`)
+
+	instructions := checkRoundTrip(t, bin, debugInfo)
+	expected := "\tDATA R0, 0x0001\n\nmain-loop:\n\tADD R0, R1\n\tJMPZ done\n\tJMP main-loop\n\ndone:\n\tCLF\n"
+	if source := Format(instructions); source != expected {
+		t.Logf("Expected\n%s\nbut got\n%s", expected, source)
+		t.FailNow()
+	}
+}
+
+func TestDisassembleGeneratesLabels(t *testing.T) {
+	bin, _ := assemble(t, `
+loop:
+	CALL routine
+	JMP loop
+routine:
+	RET
+	`)
+
+	instructions := checkRoundTrip(t, bin, nil)
+	expected := []asm.Instruction{
+		asm.DEFLABEL{"L0500"},
+		asm.CALL{asm.LABEL{"L0504"}},
+		asm.JMP{asm.LABEL{"L0500"}},
+		asm.DEFLABEL{"L0504"},
+		asm.RET{},
+	}
+	if !reflect.DeepEqual(instructions, expected) {
+		t.Logf("Expected %v but got %v", expected, instructions)
+		t.FailNow()
+	}
+}
+
+func TestDisassembleLegacyCall(t *testing.T) {
+	// DATA R3, 0x0504; JMP 0x0505; CLF; RET
+	bin := []uint16{0x0023, 0x0504, 0x0040, 0x0505, 0x0060, 0x0130}
+
+	instructions := checkRoundTrip(t, bin, nil)
+	expected := []asm.Instruction{
+		asm.DEFSYMBOL{"RETURN-0504", 0x0504},
+		asm.DATA{asm.REG3, asm.SYMBOL{"RETURN-0504"}},
+		asm.JMP{asm.LABEL{"L0505"}},
+		asm.CLF{},
+		asm.DEFLABEL{"L0505"},
+		asm.RET{},
+	}
+	if !reflect.DeepEqual(instructions, expected) {
+		t.Logf("Expected %v but got %v", expected, instructions)
+		t.FailNow()
+	}
+}
+
+func TestDisassembleDataAsWords(t *testing.T) {
+	words := func(values ...uint16) asm.WORDS {
+		w := asm.WORDS{}
+		for _, value := range values {
+			w.Values = append(w.Values, asm.NUMBER{value})
+		}
+		return w
+	}
+
+	for _, test := range []struct {
+		bin      []uint16
+		expected []asm.Instruction
+	}{
+		{[]uint16{0x0050, 0x0500}, []asm.Instruction{words(0x0050, 0x0500)}},                    // JMP without flags
+		{[]uint16{0x00A1}, []asm.Instruction{words(0x00A1)}},                                    // SHL with two registers
+		{[]uint16{0x01C0}, []asm.Instruction{words(0x01C0)}},                                    // unknown
+		{[]uint16{0x0020}, []asm.Instruction{words(0x0020)}},                                    // DATA without its value
+		{[]uint16{0x0040, 0x0501, 0x0060}, []asm.Instruction{words(0x0040, 0x0501), asm.CLF{}}}, // jumps into the middle of the JMP
+		{[]uint16{0x0040, 0x0600}, []asm.Instruction{words(0x0040, 0x0600)}},                    // jumps outside the program
+		// a string, the characters that are instructions are kept as them
+		{[]uint16{'H', 'e', 'l', 'l', 'o', ',', ' ', 'w', 'o'}, []asm.Instruction{
+			words('H', 'e', 'l', 'l', 'o', ','), asm.DATA{asm.REG0, asm.NUMBER{'w'}}, words('o'),
+		}},
+	} {
+		instructions := checkRoundTrip(t, test.bin, nil)
+		if !reflect.DeepEqual(instructions, test.expected) {
+			t.Logf("Expected %v but got %v disassembling %v", test.expected, instructions, test.bin)
+			t.FailNow()
+		}
+	}
+}
+
+// checkRoundTrip disassembles the program, parses and assembles the result and checks it matches
+func checkRoundTrip(t *testing.T, bin []uint16, debugInfo *asm.DebugInfo) []asm.Instruction {
+	instructions, err := Disassemble(computer.CODE_REGION_START, bin, debugInfo)
+	if err != nil {
+		t.Logf("Error disassembling: %v", err)
+		t.FailNow()
+	}
+
+	reassembled, _ := assemble(t, Format(instructions))
+	if !reflect.DeepEqual(reassembled, bin) {
+		t.Logf("Expected the disassembly to assemble to the same program\n%s", Format(instructions))
+		t.FailNow()
+	}
+	return instructions
+}
+
+func assemble(t *testing.T, source string) ([]uint16, *asm.DebugInfo) {
+	p := asm.Parser{}
+	instructions, err := p.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Logf("Error parsing: %v", err)
+		t.FailNow()
+	}
+
+	a := asm.Assembler{}
+	debugInfo, err := a.DebugInfo(computer.CODE_REGION_START, instructions, p.Positions())
+	if err != nil {
+		t.Logf("Error creating debug info: %v", err)
+		t.FailNow()
+	}
+
+	bin, err := a.Process(computer.CODE_REGION_START, instructions)
+	if err != nil {
+		t.Logf("Error assembling: %v", err)
+		t.FailNow()
+	}
+	return bin, debugInfo
+}
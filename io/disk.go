@@ -0,0 +1,352 @@
+package io
+
+import (
+	"encoding/binary"
+	goio "io"
+	"log"
+	"os"
+
+	"github.com/djhworld/simple-computer/circuit"
+	"github.com/djhworld/simple-computer/components"
+)
+
+// the IO address of the disk controller
+const DISK_ADDRESS = 0x0003
+
+// the number of words in a sector, a sector is 512 bytes of the disk image
+const SECTOR_SIZE = 256
+
+// [cpu] <-------------> disk controller <-------------> disk <-------------> [image file]
+//        read/write                       read/write           read/write
+//
+// OUT Addr 0x0003 selects the controller and starts a transfer, the first OUT Data after that is
+// the sector number. After the sector the words of the sector are read with IN Data or written
+// with OUT Data one at a time from the start of the sector, going back to the start after the
+// last word. Reading and writing can be mixed, each moves on to the next word of the sector
+type DiskController struct {
+	// the words read from the disk are put on this bus
+	DiskInBus *components.Bus
+
+	ioBus   *components.IOBus
+	mainBus *components.Bus
+	disk    *Disk
+
+	sectorBus         *components.Bus
+	offsetInBus       *components.Bus
+	offsetBus         *components.Bus
+	sectorRegister    components.Register
+	offsetRegister    components.Register
+	dataRegister      components.Register
+	offsetIncrementer components.Adder
+
+	controllerActiveBit *components.Bit
+	// set once the sector has been given, the next OUT Data is a word rather than the sector
+	transferBit *components.Bit
+
+	addressSelectAndGate  components.ANDGate8
+	addressSelectNOTGates [6]circuit.NOTGate
+
+	isAddressOutputModeGate components.ANDGate3
+	startTransferGate       circuit.ANDGate
+	transferNOTGate         circuit.NOTGate
+
+	isDataOutputModeGate components.ANDGate3
+	isDataInputModeGate  components.ANDGate3
+	dataModeNOTGates     [2]circuit.NOTGate
+	sectorSetGate        components.ANDGate3
+	writeGate            components.ANDGate3
+	readGate             components.ANDGate3
+	nextWordGate         circuit.ORGate
+}
+
+func NewDiskController() *DiskController {
+	d := new(DiskController)
+	d.DiskInBus = components.NewBus(BUS_WIDTH)
+	return d
+}
+
+// ConnectDisk puts a disk in the controller, without one reads return 0 and writes are lost
+func (d *DiskController) ConnectDisk(disk *Disk) {
+	d.disk = disk
+}
+
+func (d *DiskController) Connect(ioBus *components.IOBus, mainBus *components.Bus) {
+	d.ioBus = ioBus
+	d.mainBus = mainBus
+
+	d.sectorBus = components.NewBus(BUS_WIDTH)
+	d.offsetInBus = components.NewBus(BUS_WIDTH)
+	d.offsetBus = components.NewBus(BUS_WIDTH)
+
+	// the sector and offset registers are always enabled so the disk can see them
+	d.sectorRegister = *components.NewRegister("DSR", d.mainBus, d.sectorBus)
+	d.sectorRegister.Enable()
+	d.sectorRegister.Update()
+	d.offsetRegister = *components.NewRegister("DOR", d.offsetInBus, d.offsetBus)
+	d.offsetRegister.Enable()
+	d.offsetRegister.Update()
+	d.dataRegister = *components.NewRegister("DDR", d.DiskInBus, d.mainBus)
+
+	// adding 0x0000 with the carry in set moves the offset on by one
+	d.offsetIncrementer = *components.NewAdder()
+
+	d.controllerActiveBit = components.NewBit()
+	d.controllerActiveBit.Update(false, true)
+	d.controllerActiveBit.Update(false, false)
+	d.transferBit = components.NewBit()
+	d.transferBit.Update(false, true)
+	d.transferBit.Update(false, false)
+
+	d.addressSelectAndGate = *components.NewANDGate8()
+	for n := range d.addressSelectNOTGates {
+		d.addressSelectNOTGates[n] = *circuit.NewNOTGate()
+	}
+
+	d.isAddressOutputModeGate = *components.NewANDGate3()
+	d.startTransferGate = *circuit.NewANDGate()
+	d.transferNOTGate = *circuit.NewNOTGate()
+
+	d.isDataOutputModeGate = *components.NewANDGate3()
+	d.isDataInputModeGate = *components.NewANDGate3()
+	for n := range d.dataModeNOTGates {
+		d.dataModeNOTGates[n] = *circuit.NewNOTGate()
+	}
+	d.sectorSetGate = *components.NewANDGate3()
+	d.writeGate = *components.NewANDGate3()
+	d.readGate = *components.NewANDGate3()
+	d.nextWordGate = *circuit.NewORGate()
+}
+
+// DiskControllerState is the sector and word of the transfer and the latches of the controller,
+// the contents of the disk are in its image file
+type DiskControllerState struct {
+	Sector   uint16
+	Offset   uint16
+	Transfer bool
+	Selected bool
+}
+
+func (d *DiskController) State() DiskControllerState {
+	return DiskControllerState{d.sectorRegister.Value(), d.offsetRegister.Value(), d.transferBit.Get(), d.controllerActiveBit.Get()}
+}
+
+func (d *DiskController) SetState(s DiskControllerState) {
+	// the sector register is set from the main bus, which is left as it was
+	value := d.mainBus.Value()
+	d.mainBus.SetValue(s.Sector)
+	d.setRegister(&d.sectorRegister)
+	d.mainBus.SetValue(value)
+
+	d.offsetInBus.SetValue(s.Offset)
+	d.setRegister(&d.offsetRegister)
+
+	d.transferBit.Update(s.Transfer, true)
+	d.transferBit.Update(s.Transfer, false)
+	d.controllerActiveBit.Update(s.Selected, true)
+	d.controllerActiveBit.Update(s.Selected, false)
+}
+
+func (d *DiskController) Update() {
+	d.updateActive()
+	// the words are transferred before the sector is set, so the sector is not also written as the first word
+	d.updateTransfer()
+	d.updateSector()
+}
+
+func (d *DiskController) updateActive() {
+	// check if bus = 0x0003
+	for n := range d.addressSelectNOTGates {
+		d.addressSelectNOTGates[n].Update(d.mainBus.GetOutputWire(n + 8))
+	}
+	d.addressSelectAndGate.Update(
+		d.addressSelectNOTGates[0].Output(),
+		d.addressSelectNOTGates[1].Output(),
+		d.addressSelectNOTGates[2].Output(),
+		d.addressSelectNOTGates[3].Output(),
+		d.addressSelectNOTGates[4].Output(),
+		d.addressSelectNOTGates[5].Output(),
+		d.mainBus.GetOutputWire(14),
+		d.mainBus.GetOutputWire(15),
+	)
+
+	d.isAddressOutputModeGate.Update(
+		d.ioBus.IsSet(),
+		d.ioBus.IsAddressMode(),
+		d.ioBus.IsOutputMode(),
+	)
+
+	d.controllerActiveBit.Update(d.addressSelectAndGate.Output(), d.isAddressOutputModeGate.Output())
+
+	// selecting the controller starts a new transfer, waiting for the sector
+	d.startTransferGate.Update(d.addressSelectAndGate.Output(), d.isAddressOutputModeGate.Output())
+	d.transferBit.Update(false, d.startTransferGate.Output())
+}
+
+func (d *DiskController) updateTransfer() {
+	d.dataModeNOTGates[0].Update(d.ioBus.GetOutputWire(components.DATA_OR_ADDRESS))
+	d.dataModeNOTGates[1].Update(d.ioBus.GetOutputWire(components.MODE))
+	d.isDataOutputModeGate.Update(
+		d.ioBus.IsSet(),
+		d.dataModeNOTGates[0].Output(),
+		d.ioBus.IsOutputMode(),
+	)
+	d.isDataInputModeGate.Update(
+		d.ioBus.IsEnable(),
+		d.dataModeNOTGates[0].Output(),
+		d.dataModeNOTGates[1].Output(),
+	)
+	d.writeGate.Update(d.controllerActiveBit.Get(), d.isDataOutputModeGate.Output(), d.transferBit.Get())
+	d.readGate.Update(d.controllerActiveBit.Get(), d.isDataInputModeGate.Output(), d.transferBit.Get())
+
+	if d.writeGate.Output() && d.disk != nil {
+		d.disk.Write(d.sectorBus.Value(), d.offsetBus.Value(), d.mainBus.Value())
+	}
+
+	if d.readGate.Output() {
+		d.DiskInBus.SetValue(0x0000)
+		if d.disk != nil {
+			d.DiskInBus.SetValue(d.disk.Read(d.sectorBus.Value(), d.offsetBus.Value()))
+		}
+		d.dataRegister.Set()
+		d.dataRegister.Enable()
+		d.dataRegister.Update()
+		d.dataRegister.Disable()
+		d.dataRegister.Unset()
+		d.dataRegister.Update()
+	}
+
+	d.nextWordGate.Update(d.writeGate.Output(), d.readGate.Output())
+	if d.nextWordGate.Output() {
+		d.nextWord()
+	}
+}
+
+func (d *DiskController) updateSector() {
+	// the first OUT Data of a transfer is the sector
+	d.transferNOTGate.Update(d.transferBit.Get())
+	d.sectorSetGate.Update(d.controllerActiveBit.Get(), d.isDataOutputModeGate.Output(), d.transferNOTGate.Output())
+
+	if d.sectorSetGate.Output() {
+		d.setRegister(&d.sectorRegister)
+		d.offsetInBus.SetValue(0x0000)
+		d.setRegister(&d.offsetRegister)
+		d.transferBit.Update(true, true)
+		d.transferBit.Update(true, false)
+	}
+}
+
+// nextWord moves the offset on to the next word of the sector, after the last word it goes back to 0
+func (d *DiskController) nextWord() {
+	for n := 0; n < BUS_WIDTH; n++ {
+		d.offsetIncrementer.SetInputWire(n, d.offsetBus.GetOutputWire(n))
+	}
+	d.offsetIncrementer.Update(true)
+
+	// the offset is the low 8 bits
+	for n := 0; n < BUS_WIDTH; n++ {
+		d.offsetInBus.SetInputWire(n, n >= 8 && d.offsetIncrementer.GetOutputWire(n))
+	}
+	d.setRegister(&d.offsetRegister)
+}
+
+func (d *DiskController) setRegister(r *components.Register) {
+	r.Set()
+	r.Update()
+	r.Unset()
+	r.Update()
+}
+
+// DiskImage holds the sectors of a disk one after the other, e.g. an *os.File
+type DiskImage interface {
+	goio.ReaderAt
+	goio.WriterAt
+}
+
+// Disk reads and writes the words of a disk image, every word is 2 bytes big endian. Reading past the end
+// of the image returns 0, writing past the end makes the image longer.
+// The sector being transferred is kept in memory, writes go straight through to the image
+type Disk struct {
+	image DiskImage
+
+	sector uint16
+	loaded bool
+	buffer [SECTOR_SIZE]uint16
+	// the number of words of the loaded sector that are in the image, the rest is past its end
+	inImage int
+}
+
+func NewDisk(image DiskImage) *Disk {
+	d := new(Disk)
+	d.image = image
+	return d
+}
+
+// OpenDisk opens the image file filename, it is created if it does not exist
+func OpenDisk(filename string) (*Disk, error) {
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewDisk(f), nil
+}
+
+// Close closes the image if it is a file
+func (d *Disk) Close() error {
+	if closer, ok := d.image.(goio.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Read returns the word at offset in sector
+func (d *Disk) Read(sector, offset uint16) uint16 {
+	d.load(sector)
+	return d.buffer[offset%SECTOR_SIZE]
+}
+
+// Write stores value at offset in sector
+func (d *Disk) Write(sector, offset, value uint16) {
+	d.load(sector)
+	offset %= SECTOR_SIZE
+	// a word past the end of the image is written even if it is 0 so that the image gets longer
+	if d.buffer[offset] == value && int(offset) < d.inImage {
+		return
+	}
+
+	d.buffer[offset] = value
+	if int(offset) >= d.inImage {
+		d.inImage = int(offset) + 1
+	}
+	var bytes [2]byte
+	binary.BigEndian.PutUint16(bytes[:], value)
+	if _, err := d.image.WriteAt(bytes[:], d.position(sector, offset)); err != nil {
+		log.Printf("error writing sector %d of the disk: %v", sector, err)
+	}
+}
+
+func (d *Disk) load(sector uint16) {
+	if d.loaded && d.sector == sector {
+		return
+	}
+
+	var bytes [SECTOR_SIZE * 2]byte
+	n, err := d.image.ReadAt(bytes[:], d.position(sector, 0))
+	if err != nil && err != goio.EOF {
+		log.Printf("error reading sector %d of the disk: %v", sector, err)
+	}
+	// the rest of a sector past the end of the image is 0
+	for i := n; i < len(bytes); i++ {
+		bytes[i] = 0
+	}
+
+	for i := range d.buffer {
+		d.buffer[i] = binary.BigEndian.Uint16(bytes[i*2:])
+	}
+	d.sector = sector
+	d.loaded = true
+	d.inImage = n / 2
+}
+
+func (d *Disk) position(sector, offset uint16) int64 {
+	return (int64(sector)*SECTOR_SIZE + int64(offset)) * 2
+}
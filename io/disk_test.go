@@ -0,0 +1,149 @@
+package io
+
+import (
+	goio "io"
+	"testing"
+
+	"github.com/djhworld/simple-computer/components"
+)
+
+// memoryImage is a disk image in memory that grows when written past its end, like a file
+type memoryImage struct {
+	bytes []byte
+}
+
+func (m *memoryImage) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(m.bytes)) {
+		return 0, goio.EOF
+	}
+	n := copy(p, m.bytes[off:])
+	if n < len(p) {
+		return n, goio.EOF
+	}
+	return n, nil
+}
+
+func (m *memoryImage) WriteAt(p []byte, off int64) (int, error) {
+	for int64(len(m.bytes)) < off+int64(len(p)) {
+		m.bytes = append(m.bytes, 0)
+	}
+	return copy(m.bytes[off:], p), nil
+}
+
+func TestDiskControllerWritesAndReadsSectors(t *testing.T) {
+	ioBus := components.NewIOBus()
+	mainBus := components.NewBus(BUS_WIDTH)
+
+	image := &memoryImage{}
+	controller := NewDiskController()
+	controller.Connect(ioBus, mainBus)
+	controller.ConnectDisk(NewDisk(image))
+
+	selectIO(ioBus, mainBus, controller, DISK_ADDRESS)
+	writeData(ioBus, mainBus, controller, 0x0002)
+	writeData(ioBus, mainBus, controller, 0x1234)
+	writeData(ioBus, mainBus, controller, 0xABCD)
+
+	if len(image.bytes) != 2*SECTOR_SIZE*2+4 {
+		t.Logf("Expected the words to be written to sector 2 but the image is %d bytes", len(image.bytes))
+		t.FailNow()
+	}
+	if b := image.bytes[2*SECTOR_SIZE*2:]; b[0] != 0x12 || b[1] != 0x34 || b[2] != 0xAB || b[3] != 0xCD {
+		t.Logf("Expected the words to be written big endian but got % X", b)
+		t.FailNow()
+	}
+
+	// selecting the controller again starts a new transfer
+	selectIO(ioBus, mainBus, controller, DISK_ADDRESS)
+	writeData(ioBus, mainBus, controller, 0x0002)
+	for _, expected := range []uint16{0x1234, 0xABCD, 0x0000} {
+		if actual := readData(ioBus, mainBus, controller); actual != expected {
+			t.Logf("Expected to read 0x%04X but got 0x%04X", expected, actual)
+			t.FailNow()
+		}
+	}
+}
+
+func TestDiskControllerGoesBackToTheStartOfTheSector(t *testing.T) {
+	ioBus := components.NewIOBus()
+	mainBus := components.NewBus(BUS_WIDTH)
+
+	controller := NewDiskController()
+	controller.Connect(ioBus, mainBus)
+	controller.ConnectDisk(NewDisk(&memoryImage{}))
+
+	selectIO(ioBus, mainBus, controller, DISK_ADDRESS)
+	writeData(ioBus, mainBus, controller, 0x0001)
+	for n := 0; n < SECTOR_SIZE; n++ {
+		writeData(ioBus, mainBus, controller, uint16(n+1))
+	}
+
+	if controller.State().Offset != 0 {
+		t.Logf("Expected the offset to go back to 0 but got %+v", controller.State())
+		t.FailNow()
+	}
+	if actual := readData(ioBus, mainBus, controller); actual != 0x0001 {
+		t.Logf("Expected to read the first word again but got 0x%04X", actual)
+		t.FailNow()
+	}
+}
+
+func TestDiskWritingZerosPastTheEndMakesTheImageLonger(t *testing.T) {
+	image := &memoryImage{}
+	disk := NewDisk(image)
+
+	disk.Write(1, 0, 0x0000)
+	disk.Write(1, 1, 0x0000)
+	if len(image.bytes) != SECTOR_SIZE*2+4 {
+		t.Logf("Expected the image to grow to the end of the words written but it is %d bytes", len(image.bytes))
+		t.FailNow()
+	}
+
+	// the words in the image that don't change aren't written again
+	image.bytes[SECTOR_SIZE*2] = 0xFF
+	disk.Write(1, 0, 0x0000)
+	if image.bytes[SECTOR_SIZE*2] != 0xFF {
+		t.Logf("Expected a word that is already 0 not to be written again")
+		t.FailNow()
+	}
+}
+
+func TestDiskControllerWithoutDiskReadsZero(t *testing.T) {
+	ioBus := components.NewIOBus()
+	mainBus := components.NewBus(BUS_WIDTH)
+
+	controller := NewDiskController()
+	controller.Connect(ioBus, mainBus)
+
+	selectIO(ioBus, mainBus, controller, DISK_ADDRESS)
+	writeData(ioBus, mainBus, controller, 0x0005)
+	writeData(ioBus, mainBus, controller, 0xFFFF)
+
+	mainBus.SetValue(0xFFFF)
+	if actual := readData(ioBus, mainBus, controller); actual != 0x0000 {
+		t.Logf("Expected to read 0x0000 but got 0x%04X", actual)
+		t.FailNow()
+	}
+	if s := controller.State(); s.Sector != 0x0005 || s.Offset != 2 || !s.Transfer {
+		t.Logf("Expected the transfer to move on without a disk but got %+v", s)
+		t.FailNow()
+	}
+}
+
+func writeData(ioBus *components.IOBus, mainBus *components.Bus, p Peripheral, value uint16) {
+	mainBus.SetValue(value)
+	ioBus.Set()
+	ioBus.Update(true, false)
+	p.Update()
+	ioBus.Unset()
+	p.Update()
+}
+
+func readData(ioBus *components.IOBus, mainBus *components.Bus, p Peripheral) uint16 {
+	ioBus.Enable()
+	ioBus.Update(false, false)
+	p.Update()
+	ioBus.Disable()
+	p.Update()
+	return mainBus.Value()
+}
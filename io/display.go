@@ -8,6 +8,34 @@ import (
 	"github.com/djhworld/simple-computer/components"
 )
 
+// the word of the display RAM that picks what is on the screen, one of the DISPLAY_MODE values
+const DISPLAY_MODE_ADDRESS = 0x12C0
+
+// the display modes, in pixel mode every bit of 0x0000 - 0x12BF is a pixel, in text mode every
+// word of the text buffer is a character drawn with the font ROM and in the colour modes every
+// 2 or 4 bits of the colour buffer are a pixel that is an index into the palette
+const (
+	DISPLAY_MODE_PIXEL       = 0x0000
+	DISPLAY_MODE_TEXT        = 0x0001
+	DISPLAY_MODE_COLOUR_2BPP = 0x0002
+	DISPLAY_MODE_COLOUR_4BPP = 0x0003
+)
+
+// the text buffer holds TEXT_ROWS rows of TEXT_COLUMNS characters, bits 0 - 6 of a word are the character code
+const (
+	TEXT_BUFFER_ADDRESS = 0x1300
+	TEXT_COLUMNS        = 30
+	TEXT_ROWS           = 20
+)
+
+// the palette of the colour modes is PALETTE_SIZE words from PALETTE_ADDRESS, every word is a colour
+// as 0x0RGB. The pixels start at COLOUR_BUFFER_ADDRESS, the leftmost pixel is in the highest bits of a word
+const (
+	PALETTE_ADDRESS       = 0x12D0
+	PALETTE_SIZE          = 16
+	COLOUR_BUFFER_ADDRESS = 0x4000
+)
+
 // [cpu] -------> display adapter --------> display RAM <--------- screen control ---------> [screenChannel]
 //       write                     write                   read                     write
 type DisplayAdapter struct {
@@ -66,6 +94,50 @@ func (k *DisplayAdapter) Connect(ioBus *components.IOBus, mainBus *components.Bu
 	}
 }
 
+// DisplayAdapterState is the contents of the display RAM and the latches of the adapter
+type DisplayAdapterState struct {
+	RAM          []uint16
+	InputAddress uint16
+	Selected     bool
+	// the next OUT Data goes to the display RAM rather than the input address register
+	WriteToRAM bool
+}
+
+func (k *DisplayAdapter) State() DisplayAdapterState {
+	s := DisplayAdapterState{
+		RAM:          make([]uint16, DISPLAY_RAM_SIZE),
+		InputAddress: k.displayRAM.InputAddressRegister.Value(),
+		Selected:     k.displayAdapterActiveBit.Get(),
+		WriteToRAM:   k.writeToRAM.Get(),
+	}
+	for address := range s.RAM {
+		s.RAM[address] = k.displayRAM.peek(uint16(address))
+	}
+	return s
+}
+
+func (k *DisplayAdapter) SetState(s DisplayAdapterState) {
+	// the display RAM is written from the main bus, which is left as it was
+	value := k.mainBus.Value()
+	for address, word := range s.RAM {
+		if k.displayRAM.peek(uint16(address)) != word {
+			k.displayRAM.poke(uint16(address), word)
+		}
+	}
+
+	k.mainBus.SetValue(s.InputAddress)
+	k.displayRAM.InputAddressRegister.Set()
+	k.displayRAM.InputAddressRegister.Update()
+	k.displayRAM.InputAddressRegister.Unset()
+	k.displayRAM.InputAddressRegister.Update()
+	k.mainBus.SetValue(value)
+
+	k.displayAdapterActiveBit.Update(s.Selected, true)
+	k.displayAdapterActiveBit.Update(s.Selected, false)
+	k.writeToRAM.Update(s.WriteToRAM, true)
+	k.writeToRAM.Update(s.WriteToRAM, false)
+}
+
 func (k *DisplayAdapter) Update() {
 	// check if bus = 0x0007
 	k.addressSelectNOTGates[0].Update(k.mainBus.GetOutputWire(8))
@@ -151,19 +223,38 @@ func (k *DisplayAdapter) String() string {
 	return ""
 }
 
+// Colour is the red, green and blue of a colour of the palette
+type Colour struct {
+	R, G, B uint8
+}
+
+// NewColour converts a colour stored as 0x0RGB in the display RAM
+func NewColour(rgb uint16) Colour {
+	return Colour{uint8(rgb>>8&0x0F) * 0x11, uint8(rgb>>4&0x0F) * 0x11, uint8(rgb&0x0F) * 0x11}
+}
+
+// the palette of the pixel and text modes, 0 is a pixel that is off and 1 is a pixel that is on
+var MONO_PALETTE = [PALETTE_SIZE]Colour{{50, 50, 50}, {220, 220, 220}}
+
+// Frame is a picture of the screen, every pixel is an index into the palette
+type Frame struct {
+	//y, x
+	Pixels  [160][240]byte
+	Palette [PALETTE_SIZE]Colour
+}
+
 type ScreenControl struct {
 	adapter    *DisplayAdapter
 	inputBus   *components.Bus
-	outputChan chan *[160][240]byte
+	outputChan chan *Frame
 
 	clock <-chan time.Time
 	quit  chan bool
 
-	//y, x
-	output [160][240]byte
+	output Frame
 }
 
-func NewScreenControl(adapter *DisplayAdapter, outputChan chan *[160][240]byte, quit chan bool) *ScreenControl {
+func NewScreenControl(adapter *DisplayAdapter, outputChan chan *Frame, quit chan bool) *ScreenControl {
 	s := new(ScreenControl)
 	s.adapter = adapter
 	s.clock = time.Tick(33 * time.Millisecond)
@@ -186,7 +277,31 @@ func (s *ScreenControl) Run() {
 	}
 }
 
+// Frame renders the display RAM and returns a copy of the resulting frame
+func (s *ScreenControl) Frame() *Frame {
+	s.Update()
+	frame := s.output
+	return &frame
+}
+
 func (s *ScreenControl) Update() {
+	switch s.readRAM(DISPLAY_MODE_ADDRESS) {
+	case DISPLAY_MODE_TEXT:
+		s.output.Palette = MONO_PALETTE
+		s.updateText()
+	case DISPLAY_MODE_COLOUR_2BPP:
+		s.updatePalette()
+		s.updateColour(2)
+	case DISPLAY_MODE_COLOUR_4BPP:
+		s.updatePalette()
+		s.updateColour(4)
+	default:
+		s.output.Palette = MONO_PALETTE
+		s.updatePixels()
+	}
+}
+
+func (s *ScreenControl) updatePixels() {
 	widthInBytes := uint16(30) // 30 * 8 = 240
 
 	y := uint16(0)
@@ -201,6 +316,54 @@ func (s *ScreenControl) Update() {
 	}
 }
 
+func (s *ScreenControl) updateText() {
+	for row := uint16(0); row < TEXT_ROWS; row++ {
+		for column := uint16(0); column < TEXT_COLUMNS; column++ {
+			glyph := FONT_ROM[s.readRAM(TEXT_BUFFER_ADDRESS+row*TEXT_COLUMNS+column)&0x7F]
+
+			for line, pixels := range glyph {
+				y := row*8 + uint16(line)
+				for b := uint16(0); b < 8; b++ {
+					s.output.Pixels[y][column*8+b] = (pixels >> (7 - b)) & 0x01
+				}
+			}
+		}
+	}
+}
+
+func (s *ScreenControl) updatePalette() {
+	for n := range s.output.Palette {
+		s.output.Palette[n] = NewColour(s.readRAM(PALETTE_ADDRESS + uint16(n)))
+	}
+}
+
+func (s *ScreenControl) updateColour(bitsPerPixel uint16) {
+	pixelsPerWord := 16 / bitsPerPixel
+	widthInWords := 240 / pixelsPerWord
+	mask := uint16(1)<<bitsPerPixel - 1
+
+	for y := uint16(0); y < 160; y++ {
+		for horizontal := uint16(0); horizontal < widthInWords; horizontal++ {
+			word := s.readRAM(COLOUR_BUFFER_ADDRESS + y*widthInWords + horizontal)
+			for p := uint16(0); p < pixelsPerWord; p++ {
+				shift := 16 - bitsPerPixel*(p+1)
+				s.output.Pixels[y][horizontal*pixelsPerWord+p] = byte(word >> shift & mask)
+			}
+		}
+	}
+}
+
+// readRAM returns the word at address of the display RAM
+func (s *ScreenControl) readRAM(address uint16) uint16 {
+	s.setOutputRAMAddress(address)
+	s.adapter.displayRAM.Enable()
+	s.adapter.displayRAM.UpdateOutgoing()
+	value := s.adapter.screenBus.Value()
+	s.adapter.displayRAM.Disable()
+	s.adapter.displayRAM.UpdateOutgoing()
+	return value
+}
+
 func (s *ScreenControl) setOutputRAMAddress(address uint16) {
 	s.adapter.screenBus.SetValue(address)
 	s.adapter.displayRAM.OutputAddressRegister.Set()
@@ -215,9 +378,9 @@ func (s *ScreenControl) renderPixelsFromRAM(y, x uint16) {
 
 	for b := 8; b < 16; b++ {
 		if s.adapter.screenBus.GetOutputWire(b) {
-			s.output[y][x] = 0x01
+			s.output.Pixels[y][x] = 0x01
 		} else {
-			s.output[y][x] = 0x00
+			s.output.Pixels[y][x] = 0x00
 		}
 		x++
 	}
@@ -51,6 +51,26 @@ func newDisplayRAM(inputBus, outputBus *components.Bus) *displayRAM {
 	return m
 }
 
+// the display RAM has a cell for every address, only the first 0x12C0 are on the screen in pixel mode,
+// the text buffer from TEXT_BUFFER_ADDRESS in text mode and the colour buffer from COLOUR_BUFFER_ADDRESS
+// in the colour modes
+const DISPLAY_RAM_SIZE = 0x10000
+
+// peek returns the value stored at address without going through the address registers
+func (m *displayRAM) peek(address uint16) uint16 {
+	row, col := memory.CellIndex(address)
+	return m.data[row][col].Value()
+}
+
+// poke stores value at address without going through the address registers, the value goes
+// through the input bus
+func (m *displayRAM) poke(address, value uint16) {
+	row, col := memory.CellIndex(address)
+	m.inputBus.SetValue(value)
+	m.data[row][col].Update(true, false)
+	m.data[row][col].Update(false, false)
+}
+
 func (m *displayRAM) Enable() {
 	m.enable.Update(true)
 }
@@ -0,0 +1,90 @@
+package io
+
+import (
+	"testing"
+
+	"github.com/djhworld/simple-computer/components"
+)
+
+func TestScreenControlDrawsTextBufferInTextMode(t *testing.T) {
+	ioBus := components.NewIOBus()
+	mainBus := components.NewBus(BUS_WIDTH)
+
+	adapter := NewDisplaydAdapter()
+	adapter.Connect(ioBus, mainBus)
+	screen := NewScreenControl(adapter, nil, nil)
+
+	selectIO(ioBus, mainBus, adapter, 0x0007)
+	writeDisplayRAM(ioBus, mainBus, adapter, 0x0000, 0xFFFF)
+	writeDisplayRAM(ioBus, mainBus, adapter, TEXT_BUFFER_ADDRESS+TEXT_COLUMNS+1, 'A')
+
+	// pixel mode until the mode is changed
+	if frame := screen.Frame(); frame.Pixels[0][0] != 0x01 || frame.Pixels[8][8] != 0x00 {
+		t.Logf("Expected the pixels of 0x0000 to be drawn in pixel mode")
+		t.FailNow()
+	}
+
+	writeDisplayRAM(ioBus, mainBus, adapter, DISPLAY_MODE_ADDRESS, DISPLAY_MODE_TEXT)
+	frame := screen.Frame()
+	for line, pixels := range FONT_ROM['A'] {
+		for b := 0; b < 8; b++ {
+			expected := (pixels >> uint(7-b)) & 0x01
+			if frame.Pixels[8+line][8+b] != expected {
+				t.Logf("Expected pixel %d of line %d of the 'A' in row 1 column 1 to be %d", b, line, expected)
+				t.FailNow()
+			}
+		}
+	}
+	if frame.Pixels[0][0] != 0x00 {
+		t.Logf("Expected the pixels of 0x0000 not to be drawn in text mode")
+		t.FailNow()
+	}
+}
+
+func writeDisplayRAM(ioBus *components.IOBus, mainBus *components.Bus, adapter *DisplayAdapter, address, value uint16) {
+	writeData(ioBus, mainBus, adapter, address)
+	writeData(ioBus, mainBus, adapter, value)
+}
+
+func TestScreenControlDrawsColourBufferWithPalette(t *testing.T) {
+	ioBus := components.NewIOBus()
+	mainBus := components.NewBus(BUS_WIDTH)
+
+	adapter := NewDisplaydAdapter()
+	adapter.Connect(ioBus, mainBus)
+	screen := NewScreenControl(adapter, nil, nil)
+
+	selectIO(ioBus, mainBus, adapter, 0x0007)
+	writeDisplayRAM(ioBus, mainBus, adapter, PALETTE_ADDRESS+1, 0x0F80)
+	writeDisplayRAM(ioBus, mainBus, adapter, PALETTE_ADDRESS+15, 0x0FFF)
+	writeDisplayRAM(ioBus, mainBus, adapter, COLOUR_BUFFER_ADDRESS, 0x1B2F)
+
+	writeDisplayRAM(ioBus, mainBus, adapter, DISPLAY_MODE_ADDRESS, DISPLAY_MODE_COLOUR_4BPP)
+	frame := screen.Frame()
+	for x, expected := range []byte{0x1, 0xB, 0x2, 0xF, 0x0} {
+		if frame.Pixels[0][x] != expected {
+			t.Logf("Expected pixel %d to be colour %d in 4 bit mode but got %d", x, expected, frame.Pixels[0][x])
+			t.FailNow()
+		}
+	}
+	if frame.Palette[1] != (Colour{0xFF, 0x88, 0x00}) || frame.Palette[15] != (Colour{0xFF, 0xFF, 0xFF}) {
+		t.Logf("Expected the palette to be read from the display RAM but got %v", frame.Palette)
+		t.FailNow()
+	}
+
+	writeDisplayRAM(ioBus, mainBus, adapter, DISPLAY_MODE_ADDRESS, DISPLAY_MODE_COLOUR_2BPP)
+	frame = screen.Frame()
+	// 0x1B2F = 00 01 10 11 00 10 11 11
+	for x, expected := range []byte{0, 1, 2, 3, 0, 2, 3, 3, 0} {
+		if frame.Pixels[0][x] != expected {
+			t.Logf("Expected pixel %d to be colour %d in 2 bit mode but got %d", x, expected, frame.Pixels[0][x])
+			t.FailNow()
+		}
+	}
+
+	writeDisplayRAM(ioBus, mainBus, adapter, DISPLAY_MODE_ADDRESS, DISPLAY_MODE_PIXEL)
+	if frame := screen.Frame(); frame.Palette != MONO_PALETTE {
+		t.Logf("Expected the pixel mode to use the mono palette but got %v", frame.Palette)
+		t.FailNow()
+	}
+}
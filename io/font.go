@@ -0,0 +1,102 @@
+package io
+
+// the font ROM of the text mode, the 8 lines of the glyph of every character code from 0x00 to 0x7F.
+// Bit 7 of a line is the leftmost pixel, codes without a glyph are blank and 0x7F is a solid block
+var FONT_ROM = [128][8]byte{
+	0x20: {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // space
+	0x21: {0x10, 0x10, 0x10, 0x10, 0x10, 0x00, 0x10, 0x00}, // !
+	0x22: {0x28, 0x28, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // "
+	0x23: {0x28, 0x28, 0x7C, 0x28, 0x7C, 0x28, 0x28, 0x00}, // #
+	0x24: {0x10, 0x7E, 0x90, 0x7C, 0x12, 0xFC, 0x10, 0x00}, // $
+	0x25: {0xC2, 0xC4, 0x08, 0x10, 0x20, 0x4C, 0x8C, 0x00}, // %
+	0x26: {0x38, 0x28, 0x38, 0xE0, 0x94, 0x88, 0xF4, 0x00}, // &
+	0x27: {0x20, 0x20, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00}, // '
+	0x28: {0x08, 0x10, 0x20, 0x20, 0x20, 0x10, 0x08, 0x00}, // (
+	0x29: {0x20, 0x10, 0x08, 0x08, 0x08, 0x10, 0x20, 0x00}, // )
+	0x2A: {0x00, 0x92, 0x54, 0x38, 0x38, 0x54, 0x92, 0x00}, // *
+	0x2B: {0x00, 0x10, 0x10, 0x7C, 0x30, 0x10, 0x00, 0x00}, // +
+	0x2C: {0x00, 0x00, 0x00, 0x00, 0x08, 0x08, 0x10, 0x00}, // ,
+	0x2D: {0x00, 0x00, 0x00, 0x7C, 0x00, 0x00, 0x00, 0x00}, // -
+	0x2E: {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00}, // .
+	0x2F: {0x02, 0x04, 0x08, 0x10, 0x20, 0x40, 0x80, 0x00}, // /
+	0x30: {0x7C, 0xE2, 0xA2, 0x92, 0x8A, 0x8E, 0x7C, 0x00}, // 0
+	0x31: {0x38, 0x58, 0x18, 0x18, 0x18, 0x18, 0x7E, 0x00}, // 1
+	0x32: {0x7C, 0x82, 0x1C, 0x20, 0x40, 0x80, 0xFE, 0x00}, // 2
+	0x33: {0x7C, 0x02, 0x02, 0x1E, 0x02, 0x02, 0xFC, 0x00}, // 3
+	0x34: {0x1C, 0x24, 0x44, 0x84, 0xFE, 0x04, 0x04, 0x00}, // 4
+	0x35: {0xFE, 0x80, 0xF8, 0x04, 0x02, 0x06, 0xFC, 0x00}, // 5
+	0x36: {0x3E, 0x40, 0xF8, 0x84, 0x82, 0x86, 0xFC, 0x00}, // 6
+	0x37: {0xFE, 0x02, 0x04, 0x08, 0x10, 0x20, 0x40, 0x00}, // 7
+	0x38: {0x7C, 0x82, 0x82, 0x7C, 0x82, 0x82, 0x7C, 0x00}, // 8
+	0x39: {0x7C, 0x82, 0x82, 0x7E, 0x02, 0x82, 0x7C, 0x00}, // 9
+	0x3A: {0x00, 0x10, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00}, // :
+	0x3B: {0x00, 0x10, 0x00, 0x00, 0x10, 0x20, 0x00, 0x00}, // ;
+	0x3C: {0x02, 0x04, 0x08, 0x10, 0x08, 0x04, 0x02, 0x00}, // <
+	0x3D: {0x00, 0x00, 0xFE, 0x00, 0xFE, 0x00, 0x00, 0x00}, // =
+	0x3E: {0x40, 0x20, 0x10, 0x08, 0x10, 0x20, 0x40, 0x00}, // >
+	0x3F: {0x7C, 0x42, 0x02, 0x04, 0x08, 0x00, 0x08, 0x00}, // ?
+	0x40: {0x7C, 0x8A, 0x9C, 0xA8, 0x98, 0x84, 0x78, 0x00}, // @
+	0x41: {0x7C, 0xC6, 0x82, 0xFE, 0x82, 0x82, 0x82, 0x00}, // A
+	0x42: {0xFC, 0x86, 0x82, 0xFE, 0x82, 0x86, 0xFC, 0x00}, // B
+	0x43: {0x7E, 0xC0, 0x80, 0x80, 0x80, 0xC0, 0x7E, 0x00}, // C
+	0x44: {0xF8, 0x86, 0x82, 0x82, 0x82, 0x86, 0xF8, 0x00}, // D
+	0x45: {0x7E, 0xC0, 0x80, 0xFE, 0x80, 0xC0, 0x7E, 0x00}, // E
+	0x46: {0x7E, 0x80, 0x80, 0xFC, 0x80, 0x80, 0x80, 0x00}, // F
+	0x47: {0x7E, 0x80, 0x80, 0x9C, 0x82, 0x82, 0xFE, 0x00}, // G
+	0x48: {0x82, 0x82, 0x82, 0xFE, 0x82, 0x82, 0x82, 0x00}, // H
+	0x49: {0xFE, 0x10, 0x10, 0x10, 0x10, 0x10, 0xFE, 0x00}, // I
+	0x4A: {0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0xFC, 0x00}, // J
+	0x4B: {0xC4, 0xC8, 0xF0, 0xE0, 0xD8, 0xC4, 0xC6, 0x00}, // K
+	0x4C: {0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x7E, 0x00}, // L
+	0x4D: {0x66, 0xAA, 0x92, 0x92, 0x82, 0x82, 0x82, 0x00}, // M
+	0x4E: {0xC2, 0xA2, 0x92, 0x92, 0x8A, 0x8A, 0x86, 0x00}, // N
+	0x4F: {0x7C, 0x82, 0x82, 0x82, 0x82, 0x82, 0x7C, 0x00}, // O
+	0x50: {0xFC, 0x82, 0x82, 0xFC, 0x80, 0x80, 0x80, 0x00}, // P
+	0x51: {0x78, 0x84, 0x84, 0x84, 0x94, 0x8C, 0x76, 0x07}, // Q
+	0x52: {0xFC, 0x82, 0x82, 0xFC, 0xA0, 0x90, 0x8E, 0x00}, // R
+	0x53: {0x7C, 0x80, 0x80, 0x7C, 0x04, 0x04, 0xF8, 0x00}, // S
+	0x54: {0xFE, 0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x00}, // T
+	0x55: {0xC6, 0x42, 0x42, 0x42, 0x42, 0x42, 0x3C, 0x00}, // U
+	0x56: {0x82, 0x82, 0x82, 0x82, 0x44, 0x6C, 0x10, 0x00}, // V
+	0x57: {0x82, 0x82, 0x82, 0x92, 0xBA, 0xAA, 0x44, 0x00}, // W
+	0x58: {0xC6, 0x44, 0x28, 0x10, 0x28, 0x44, 0xC6, 0x00}, // X
+	0x59: {0xC6, 0x44, 0x28, 0x10, 0x10, 0x10, 0x38, 0x00}, // Y
+	0x5A: {0xFE, 0x82, 0x0C, 0x38, 0x60, 0x82, 0x7E, 0x00}, // Z
+	0x5B: {0x30, 0x20, 0x20, 0x20, 0x20, 0x20, 0x30, 0x00}, // [
+	0x5C: {0x80, 0x40, 0x20, 0x10, 0x08, 0x04, 0x02, 0x00}, // \
+	0x5D: {0x30, 0x10, 0x10, 0x10, 0x10, 0x10, 0x30, 0x00}, // ]
+	0x5E: {0x10, 0x28, 0x44, 0x00, 0x00, 0x00, 0x00, 0x00}, // ^
+	0x5F: {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x7E, 0x00}, // _
+	0x60: {0x00, 0x20, 0x10, 0x08, 0x00, 0x00, 0x00, 0x00}, // `
+	0x61: {0x7C, 0xC6, 0x82, 0xFE, 0x82, 0x82, 0x82, 0x00}, // a
+	0x62: {0xFC, 0x86, 0x82, 0xFE, 0x82, 0x86, 0xFC, 0x00}, // b
+	0x63: {0x7E, 0xC0, 0x80, 0x80, 0x80, 0xC0, 0x7E, 0x00}, // c
+	0x64: {0xF8, 0x86, 0x82, 0x82, 0x82, 0x86, 0xF8, 0x00}, // d
+	0x65: {0x7E, 0xC0, 0x80, 0xFE, 0x80, 0xC0, 0x7E, 0x00}, // e
+	0x66: {0x7E, 0x80, 0x80, 0xFC, 0x80, 0x80, 0x80, 0x00}, // f
+	0x67: {0x7E, 0x80, 0x80, 0x9C, 0x82, 0x82, 0xFE, 0x00}, // g
+	0x68: {0x82, 0x82, 0x82, 0xFE, 0x82, 0x82, 0x82, 0x00}, // h
+	0x69: {0xFE, 0x10, 0x10, 0x10, 0x10, 0x10, 0xFE, 0x00}, // i
+	0x6A: {0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0xFC, 0x00}, // j
+	0x6B: {0xC4, 0xC8, 0xF0, 0xE0, 0xD8, 0xC4, 0xC6, 0x00}, // k
+	0x6C: {0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x7E, 0x00}, // l
+	0x6D: {0x66, 0xAA, 0x92, 0x92, 0x82, 0x82, 0x82, 0x00}, // m
+	0x6E: {0xC2, 0xA2, 0x92, 0x92, 0x8A, 0x8A, 0x86, 0x00}, // n
+	0x6F: {0x7C, 0x82, 0x82, 0x82, 0x82, 0x82, 0x7C, 0x00}, // o
+	0x70: {0xFC, 0x82, 0x82, 0xFC, 0x80, 0x80, 0x80, 0x00}, // p
+	0x71: {0x78, 0x84, 0x84, 0x84, 0x94, 0x8C, 0x76, 0x07}, // q
+	0x72: {0xFC, 0x82, 0x82, 0xFC, 0xA0, 0x90, 0x8E, 0x00}, // r
+	0x73: {0x7C, 0x80, 0x80, 0x7C, 0x04, 0x04, 0xF8, 0x00}, // s
+	0x74: {0xFE, 0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x00}, // t
+	0x75: {0xC6, 0x42, 0x42, 0x42, 0x42, 0x42, 0x3C, 0x00}, // u
+	0x76: {0x82, 0x82, 0x82, 0x82, 0x44, 0x6C, 0x10, 0x00}, // v
+	0x77: {0x82, 0x82, 0x82, 0x92, 0xBA, 0xAA, 0x44, 0x00}, // w
+	0x78: {0xC6, 0x44, 0x28, 0x10, 0x28, 0x44, 0xC6, 0x00}, // x
+	0x79: {0xC6, 0x44, 0x28, 0x10, 0x10, 0x10, 0x38, 0x00}, // y
+	0x7A: {0xFE, 0x82, 0x0C, 0x38, 0x60, 0x82, 0x7E, 0x00}, // z
+	0x7B: {0x10, 0x20, 0x60, 0x80, 0x60, 0x20, 0x10, 0x00}, // {
+	0x7C: {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x00}, // |
+	0x7D: {0x30, 0x08, 0x0C, 0x02, 0x0C, 0x08, 0x30, 0x00}, // }
+	0x7E: {0x00, 0x00, 0x00, 0x32, 0x4C, 0x00, 0x00, 0x00}, // ~
+	0x7F: {0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, // solid block
+}
@@ -0,0 +1,247 @@
+package io
+
+import (
+	"github.com/djhworld/simple-computer/circuit"
+	"github.com/djhworld/simple-computer/components"
+)
+
+// the vector table lives in the reserved memory below the user code region, the address
+// of the handler for interrupt line n is stored at INTERRUPT_VECTOR_TABLE_START + n
+const INTERRUPT_VECTOR_TABLE_START = 0x0480
+
+// interrupt lines of the peripherals, line 0 has the highest priority
+const (
+	KEYBOARD_INTERRUPT_LINE = 1
+	TIMER_INTERRUPT_LINE    = 2
+	SERIAL_INTERRUPT_LINE   = 3
+)
+
+// [peripherals] -------> interrupt controller -------> [cpu]
+//                raise                          request
+//
+// OUT Addr 0x0001 selects the controller, after that OUT Data sets the mask register
+// (bit n enables line n) and IN Data reads which lines are currently raised.
+// The lines are level triggered, a peripheral keeps its line raised until it has been serviced.
+// When the CPU acknowledges the request the controller puts the vector table address of the
+// highest priority unmasked line on the main bus
+type InterruptController struct {
+	ioBus   *components.IOBus
+	mainBus *components.Bus
+
+	linesBus       *components.Bus
+	maskBus        *components.Bus
+	vectorBus      *components.Bus
+	maskRegister   components.Register
+	linesRegister  components.Register
+	vectorRegister components.Register
+
+	controllerActiveBit *components.Bit
+
+	addressSelectAndGate  components.ANDGate8
+	addressSelectNOTGates [7]circuit.NOTGate
+
+	isAddressOutputModeGate components.ANDGate3
+	isDataOutputModeGate    components.ANDGate3
+	isDataInputModeGate     components.ANDGate3
+	dataModeNOTGates        [2]circuit.NOTGate
+	maskSetGate             circuit.ANDGate
+	linesEnableGate         circuit.ANDGate
+
+	pendingGates     [components.INTERRUPT_LINES]circuit.ANDGate
+	requestORGate    components.ORGateN
+	blockedORGates   [components.INTERRUPT_LINES]circuit.ORGate
+	blockedNOTGates  [components.INTERRUPT_LINES]circuit.NOTGate
+	selectGates      [components.INTERRUPT_LINES]circuit.ANDGate
+	vectorBitORGates [3]components.ORGate4
+}
+
+func NewInterruptController() *InterruptController {
+	i := new(InterruptController)
+	return i
+}
+
+func (i *InterruptController) Connect(ioBus *components.IOBus, mainBus *components.Bus) {
+	i.ioBus = ioBus
+	i.mainBus = mainBus
+
+	i.linesBus = components.NewBus(BUS_WIDTH)
+	i.maskBus = components.NewBus(BUS_WIDTH)
+	i.vectorBus = components.NewBus(BUS_WIDTH)
+	i.vectorBus.SetValue(INTERRUPT_VECTOR_TABLE_START)
+
+	// the mask register is always enabled, and we initialise it with value 0 so all lines are masked
+	i.maskRegister = *components.NewRegister("IMR", i.mainBus, i.maskBus)
+	i.maskRegister.Enable()
+	i.maskRegister.Set()
+	i.maskRegister.Update()
+	i.maskRegister.Unset()
+	i.maskRegister.Update()
+	i.linesRegister = *components.NewRegister("ILR", i.linesBus, i.mainBus)
+	i.vectorRegister = *components.NewRegister("IVR", i.vectorBus, i.mainBus)
+
+	i.controllerActiveBit = components.NewBit()
+	i.controllerActiveBit.Update(false, true)
+	i.controllerActiveBit.Update(false, false)
+
+	i.addressSelectAndGate = *components.NewANDGate8()
+	for n := range i.addressSelectNOTGates {
+		i.addressSelectNOTGates[n] = *circuit.NewNOTGate()
+	}
+
+	i.isAddressOutputModeGate = *components.NewANDGate3()
+	i.isDataOutputModeGate = *components.NewANDGate3()
+	i.isDataInputModeGate = *components.NewANDGate3()
+	for n := range i.dataModeNOTGates {
+		i.dataModeNOTGates[n] = *circuit.NewNOTGate()
+	}
+	i.maskSetGate = *circuit.NewANDGate()
+	i.linesEnableGate = *circuit.NewANDGate()
+
+	for n := 0; n < components.INTERRUPT_LINES; n++ {
+		i.pendingGates[n] = *circuit.NewANDGate()
+		i.blockedORGates[n] = *circuit.NewORGate()
+		i.blockedNOTGates[n] = *circuit.NewNOTGate()
+		i.selectGates[n] = *circuit.NewANDGate()
+	}
+	i.requestORGate = *components.NewORGateN(components.INTERRUPT_LINES)
+
+	for n := range i.vectorBitORGates {
+		i.vectorBitORGates[n] = *components.NewORGate4()
+	}
+}
+
+// InterruptControllerState is the mask register and whether the controller is selected
+type InterruptControllerState struct {
+	Mask     uint16
+	Selected bool
+}
+
+func (i *InterruptController) State() InterruptControllerState {
+	return InterruptControllerState{i.maskRegister.Value(), i.controllerActiveBit.Get()}
+}
+
+func (i *InterruptController) SetState(s InterruptControllerState) {
+	// the mask register is set from the main bus, which is left as it was
+	value := i.mainBus.Value()
+	i.mainBus.SetValue(s.Mask)
+	i.maskRegister.Set()
+	i.maskRegister.Update()
+	i.maskRegister.Unset()
+	i.maskRegister.Update()
+	i.mainBus.SetValue(value)
+
+	i.controllerActiveBit.Update(s.Selected, true)
+	i.controllerActiveBit.Update(s.Selected, false)
+}
+
+func (i *InterruptController) Update() {
+	i.updateActive()
+	i.updateMask()
+	i.updateLines()
+	i.updateRequest()
+	i.updateVector()
+}
+
+func (i *InterruptController) updateActive() {
+	// check if bus = 0x0001
+	for n := range i.addressSelectNOTGates {
+		i.addressSelectNOTGates[n].Update(i.mainBus.GetOutputWire(n + 8))
+	}
+	i.addressSelectAndGate.Update(
+		i.addressSelectNOTGates[0].Output(),
+		i.addressSelectNOTGates[1].Output(),
+		i.addressSelectNOTGates[2].Output(),
+		i.addressSelectNOTGates[3].Output(),
+		i.addressSelectNOTGates[4].Output(),
+		i.addressSelectNOTGates[5].Output(),
+		i.addressSelectNOTGates[6].Output(),
+		i.mainBus.GetOutputWire(15),
+	)
+
+	i.isAddressOutputModeGate.Update(
+		i.ioBus.IsSet(),
+		i.ioBus.IsAddressMode(),
+		i.ioBus.IsOutputMode(),
+	)
+
+	i.controllerActiveBit.Update(i.addressSelectAndGate.Output(), i.isAddressOutputModeGate.Output())
+}
+
+func (i *InterruptController) updateMask() {
+	i.dataModeNOTGates[0].Update(i.ioBus.GetOutputWire(components.DATA_OR_ADDRESS))
+	i.isDataOutputModeGate.Update(
+		i.ioBus.IsSet(),
+		i.dataModeNOTGates[0].Output(),
+		i.ioBus.IsOutputMode(),
+	)
+	i.maskSetGate.Update(i.controllerActiveBit.Get(), i.isDataOutputModeGate.Output())
+
+	if i.maskSetGate.Output() {
+		i.maskRegister.Set()
+		i.maskRegister.Update()
+		i.maskRegister.Unset()
+		i.maskRegister.Update()
+	}
+}
+
+func (i *InterruptController) updateLines() {
+	// line n is bit n of the value read with IN Data
+	for n := 0; n < components.INTERRUPT_LINES; n++ {
+		i.linesBus.SetInputWire(BUS_WIDTH-1-n, i.ioBus.IsInterruptLineRaised(n))
+	}
+
+	i.dataModeNOTGates[1].Update(i.ioBus.GetOutputWire(components.MODE))
+	i.isDataInputModeGate.Update(
+		i.ioBus.IsEnable(),
+		i.dataModeNOTGates[0].Output(),
+		i.dataModeNOTGates[1].Output(),
+	)
+	i.linesEnableGate.Update(i.controllerActiveBit.Get(), i.isDataInputModeGate.Output())
+
+	if i.linesEnableGate.Output() {
+		i.linesRegister.Set()
+		i.linesRegister.Enable()
+		i.linesRegister.Update()
+		i.linesRegister.Disable()
+		i.linesRegister.Unset()
+		i.linesRegister.Update()
+	}
+}
+
+func (i *InterruptController) updateRequest() {
+	var pending [components.INTERRUPT_LINES]bool
+	for n := 0; n < components.INTERRUPT_LINES; n++ {
+		i.pendingGates[n].Update(i.ioBus.IsInterruptLineRaised(n), i.maskBus.GetOutputWire(BUS_WIDTH-1-n))
+		pending[n] = i.pendingGates[n].Output()
+	}
+	i.requestORGate.Update(pending[:]...)
+	i.ioBus.UpdateInterruptRequest(i.requestORGate.Output())
+}
+
+func (i *InterruptController) updateVector() {
+	// a line is only selected if no line with a higher priority is pending
+	blocked := false
+	for n := 0; n < components.INTERRUPT_LINES; n++ {
+		i.blockedNOTGates[n].Update(blocked)
+		i.selectGates[n].Update(i.pendingGates[n].Output(), i.blockedNOTGates[n].Output())
+		i.blockedORGates[n].Update(blocked, i.pendingGates[n].Output())
+		blocked = i.blockedORGates[n].Output()
+	}
+
+	i.vectorBitORGates[0].Update(i.selectGates[1].Output(), i.selectGates[3].Output(), i.selectGates[5].Output(), i.selectGates[7].Output())
+	i.vectorBitORGates[1].Update(i.selectGates[2].Output(), i.selectGates[3].Output(), i.selectGates[6].Output(), i.selectGates[7].Output())
+	i.vectorBitORGates[2].Update(i.selectGates[4].Output(), i.selectGates[5].Output(), i.selectGates[6].Output(), i.selectGates[7].Output())
+
+	i.vectorBus.SetInputWire(BUS_WIDTH-1, i.vectorBitORGates[0].Output())
+	i.vectorBus.SetInputWire(BUS_WIDTH-2, i.vectorBitORGates[1].Output())
+	i.vectorBus.SetInputWire(BUS_WIDTH-3, i.vectorBitORGates[2].Output())
+
+	if i.ioBus.IsInterruptAcknowledged() {
+		i.vectorRegister.Set()
+		i.vectorRegister.Enable()
+		i.vectorRegister.Update()
+		i.vectorRegister.Disable()
+		i.vectorRegister.Unset()
+		i.vectorRegister.Update()
+	}
+}
@@ -0,0 +1,111 @@
+package io
+
+import (
+	"testing"
+
+	"github.com/djhworld/simple-computer/components"
+)
+
+func TestControllerRequestsInterruptForUnmaskedLines(t *testing.T) {
+	ioBus := components.NewIOBus()
+	mainBus := components.NewBus(BUS_WIDTH)
+
+	controller := NewInterruptController()
+	controller.Connect(ioBus, mainBus)
+
+	ioBus.UpdateInterruptLine(3, true)
+	controller.Update()
+
+	if ioBus.IsInterruptRequested() {
+		t.Log("Expected masked line not to request an interrupt")
+		t.FailNow()
+	}
+
+	setInterruptMask(ioBus, mainBus, controller, 0x0008)
+
+	if !ioBus.IsInterruptRequested() {
+		t.Log("Expected unmasked line to request an interrupt")
+		t.FailNow()
+	}
+
+	ioBus.UpdateInterruptLine(3, false)
+	controller.Update()
+
+	if ioBus.IsInterruptRequested() {
+		t.Log("Expected lowered line to stop requesting an interrupt")
+		t.FailNow()
+	}
+}
+
+func TestControllerPutsHighestPriorityVectorOnBusWhenAcknowledged(t *testing.T) {
+	ioBus := components.NewIOBus()
+	mainBus := components.NewBus(BUS_WIDTH)
+
+	controller := NewInterruptController()
+	controller.Connect(ioBus, mainBus)
+	setInterruptMask(ioBus, mainBus, controller, 0x00FF)
+
+	ioBus.UpdateInterruptLine(6, true)
+	ioBus.UpdateInterruptLine(5, true)
+	controller.Update()
+
+	ioBus.UpdateInterruptAcknowledge(true)
+	controller.Update()
+	ioBus.UpdateInterruptAcknowledge(false)
+
+	if !checkBus(mainBus, INTERRUPT_VECTOR_TABLE_START+5) {
+		t.FailNow()
+	}
+}
+
+func TestKeyboardAdapterRaisesInterruptUntilKeycodeIsRead(t *testing.T) {
+	ioBus := components.NewIOBus()
+	mainBus := components.NewBus(BUS_WIDTH)
+
+	adapter := NewKeyboardAdapter()
+	adapter.Connect(ioBus, mainBus)
+	adapter.Update()
+
+	if ioBus.IsInterruptLineRaised(KEYBOARD_INTERRUPT_LINE) {
+		t.FailNow()
+	}
+
+	adapter.KeyboardInBus.SetValue(0x0041)
+	adapter.Update()
+
+	if !ioBus.IsInterruptLineRaised(KEYBOARD_INTERRUPT_LINE) {
+		t.FailNow()
+	}
+
+	mainBus.SetValue(0x000F)
+	ioBus.Set()
+	ioBus.Update(true, true)
+	adapter.Update()
+	ioBus.Unset()
+	adapter.Update()
+
+	ioBus.Enable()
+	ioBus.Update(false, false)
+	adapter.Update()
+	adapter.Update()
+
+	if ioBus.IsInterruptLineRaised(KEYBOARD_INTERRUPT_LINE) {
+		t.FailNow()
+	}
+}
+
+func setInterruptMask(ioBus *components.IOBus, mainBus *components.Bus, controller *InterruptController, mask uint16) {
+	mainBus.SetValue(0x0001)
+	ioBus.Set()
+	ioBus.Update(true, true)
+	controller.Update()
+	ioBus.Unset()
+	controller.Update()
+
+	mainBus.SetValue(mask)
+	ioBus.Set()
+	ioBus.Update(true, false)
+	controller.Update()
+	ioBus.Unset()
+	controller.Update()
+}
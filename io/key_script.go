@@ -0,0 +1,259 @@
+package io
+
+import (
+	"bufio"
+	"fmt"
+	goio "io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/djhworld/simple-computer/components"
+)
+
+// the number of cycles to wait after every key typed with a type command, unless changed with delay
+const DEFAULT_TYPING_DELAY = 1000
+
+// the GLFW key codes of the characters that can be typed, letters are typed with the code of their upper case
+// form and characters that need shift can't be typed
+var TYPEABLE_KEYS = map[rune]int{
+	' ': 32, '\'': 39, ',': 44, '-': 45, '.': 46, '/': 47, ';': 59, '=': 61, '[': 91, '\\': 92, ']': 93, '`': 96,
+}
+
+// KeyScript is a list of key presses and waits replayed by a ScriptedKeyboard. A script has one command a line,
+// blank lines and lines starting with # are ignored
+//
+//	type <text>        press and release the key of every character of text, waiting the typing delay after each
+//	key <code>         press and release a key by its GLFW key code, e.g. 257 for enter
+//	down <code>        press a key
+//	up <code>          release a key
+//	wait <n>           wait n cycles of the CPU
+//	wait <duration>    wait for a time, e.g. 500ms or 2s
+//	delay <n>          set the typing delay in cycles, it starts at DEFAULT_TYPING_DELAY
+//
+// Like io.Keyboard only pressing a key reaches the keyboard adapter
+type KeyScript struct {
+	steps []keyScriptStep
+}
+
+// a key press or a wait for a number of cycles or a time
+type keyScriptStep struct {
+	key    *KeyPress
+	cycles int
+	wait   time.Duration
+}
+
+// ReadKeyScript parses the key script in filename
+func ReadKeyScript(filename string) (*KeyScript, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseKeyScript(f)
+}
+
+func ParseKeyScript(reader goio.Reader) (*KeyScript, error) {
+	script := new(KeyScript)
+	typingDelay := DEFAULT_TYPING_DELAY
+
+	scanner := bufio.NewScanner(reader)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		command, argument := line, ""
+		if n := strings.IndexAny(line, " \t"); n >= 0 {
+			command, argument = line[:n], strings.TrimSpace(line[n+1:])
+		}
+		if argument == "" {
+			return nil, fmt.Errorf("line %d: '%s' needs an argument", lineNo, command)
+		}
+
+		switch command {
+		case "type":
+			for _, r := range argument {
+				code, err := keyCode(r)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", lineNo, err)
+				}
+				script.Press(code, true)
+				script.Press(code, false)
+				script.Wait(typingDelay)
+			}
+		case "key", "down", "up":
+			code, err := strconv.ParseInt(argument, 0, 32)
+			if err != nil || code < 0 {
+				return nil, fmt.Errorf("line %d: invalid key code '%s'", lineNo, argument)
+			}
+			if command != "up" {
+				script.Press(int(code), true)
+			}
+			if command != "down" {
+				script.Press(int(code), false)
+			}
+		case "wait", "delay":
+			cycles, wait, err := parseWait(argument)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			if command == "delay" {
+				if wait > 0 {
+					return nil, fmt.Errorf("line %d: the typing delay is in cycles", lineNo)
+				}
+				typingDelay = cycles
+				continue
+			}
+			script.steps = append(script.steps, keyScriptStep{cycles: cycles, wait: wait})
+		default:
+			return nil, fmt.Errorf("line %d: unknown command '%s'", lineNo, command)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// Press adds pressing a key by its GLFW key code to the end of the script, or releasing it if down is false
+func (s *KeyScript) Press(code int, down bool) {
+	s.steps = append(s.steps, keyScriptStep{key: &KeyPress{code, down}})
+}
+
+// Wait adds waiting for a number of cycles to the end of the script
+func (s *KeyScript) Wait(cycles int) {
+	s.steps = append(s.steps, keyScriptStep{cycles: cycles})
+}
+
+func keyCode(r rune) (int, error) {
+	switch {
+	case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return int(r), nil
+	case r >= 'a' && r <= 'z':
+		return int(r - 'a' + 'A'), nil
+	}
+	if code, ok := TYPEABLE_KEYS[r]; ok {
+		return code, nil
+	}
+	return 0, fmt.Errorf("'%c' can't be typed, use key with its key code", r)
+}
+
+// parseWait parses a number of cycles or a duration such as 500ms
+func parseWait(argument string) (int, time.Duration, error) {
+	if cycles, err := strconv.Atoi(argument); err == nil && cycles >= 0 {
+		return cycles, 0, nil
+	}
+	if wait, err := time.ParseDuration(argument); err == nil && wait >= 0 {
+		return 0, wait, nil
+	}
+	return 0, 0, fmt.Errorf("invalid wait '%s', must be a number of cycles or a duration", argument)
+}
+
+// [script] ---------> scripted keyboard ---------> keyboard adapter <-------------> [cpu]
+//          replay                          write                     read/write
+//
+// ScriptedKeyboard replays a key script into the keyboard adapter. It is connected to the CPU like a
+// peripheral so that it is ticked every cycle, it does not use the IO bus
+type ScriptedKeyboard struct {
+	outBus *components.Bus
+	script *KeyScript
+
+	next int
+	// the cycles left to wait
+	cycles int
+	// the end of the time being waited for
+	until time.Time
+}
+
+func NewScriptedKeyboard(script *KeyScript) *ScriptedKeyboard {
+	k := new(ScriptedKeyboard)
+	k.script = script
+	return k
+}
+
+// ConnectTo connects the keyboard to the bus of the keyboard adapter
+func (k *ScriptedKeyboard) ConnectTo(bus *components.Bus) {
+	k.outBus = bus
+}
+
+func (k *ScriptedKeyboard) Connect(ioBus *components.IOBus, mainBus *components.Bus) {}
+
+func (k *ScriptedKeyboard) Update() {}
+
+// Tick replays the script up to the next wait
+func (k *ScriptedKeyboard) Tick() {
+	if k.cycles > 0 {
+		k.cycles--
+		return
+	}
+	if !k.until.IsZero() {
+		if time.Now().Before(k.until) {
+			return
+		}
+		k.until = time.Time{}
+	}
+
+	for k.next < len(k.script.steps) {
+		step := k.script.steps[k.next]
+		k.next++
+
+		switch {
+		case step.key != nil:
+			if step.key.IsDown && k.outBus != nil {
+				k.outBus.SetValue(uint16(step.key.Value))
+			}
+		case step.cycles > 0:
+			// this tick is the first cycle of the wait
+			k.cycles = step.cycles - 1
+			return
+		case step.wait > 0:
+			k.until = time.Now().Add(step.wait)
+			return
+		}
+	}
+}
+
+// Done returns true once the whole script has been replayed
+func (k *ScriptedKeyboard) Done() bool {
+	return k.next >= len(k.script.steps) && k.cycles == 0 && k.until.IsZero()
+}
+
+// ScriptedKeyboardState is how far through its script a scripted keyboard is. A wait for a time is kept as
+// the time that was left of it, so a restored keyboard waits for the rest of it
+type ScriptedKeyboardState struct {
+	Next    int
+	Cycles  int
+	Waiting bool
+	Left    time.Duration
+}
+
+func (k *ScriptedKeyboard) State() ScriptedKeyboardState {
+	s := ScriptedKeyboardState{Next: k.next, Cycles: k.cycles}
+	if !k.until.IsZero() {
+		s.Waiting = true
+		s.Left = time.Until(k.until)
+	}
+	return s
+}
+
+// SetState carries on from a state of a keyboard replaying the same script, it is an error if the script is
+// shorter than the state
+func (k *ScriptedKeyboard) SetState(s ScriptedKeyboardState) error {
+	if s.Next < 0 || s.Next > len(k.script.steps) {
+		return fmt.Errorf("the key script has %d steps but the keyboard was at step %d", len(k.script.steps), s.Next)
+	}
+	k.next = s.Next
+	k.cycles = s.Cycles
+	k.until = time.Time{}
+	if s.Waiting {
+		k.until = time.Now().Add(s.Left)
+	}
+	return nil
+}
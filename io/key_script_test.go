@@ -0,0 +1,82 @@
+package io
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/djhworld/simple-computer/components"
+)
+
+func TestKeyScriptIsReplayedAfterWaits(t *testing.T) {
+	script, err := ParseKeyScript(strings.NewReader(`
+# wait for the program to start
+wait 3
+delay 2
+type hi
+key 257
+`))
+	if err != nil {
+		t.Logf("Error parsing key script: %v", err)
+		t.FailNow()
+	}
+
+	bus := components.NewBus(BUS_WIDTH)
+	keyboard := NewScriptedKeyboard(script)
+	keyboard.ConnectTo(bus)
+
+	// the value on the bus after every tick, the program reads a key by clearing the bus
+	expected := []uint16{0, 0, 0, 'H', 0, 'I', 0, 257, 0}
+	for cycle, key := range expected {
+		keyboard.Tick()
+		if bus.Value() != key {
+			t.Logf("Expected 0x%04X on the bus at cycle %d but got 0x%04X", key, cycle, bus.Value())
+			t.FailNow()
+		}
+		bus.SetValue(0)
+	}
+
+	if !keyboard.Done() {
+		t.Logf("Expected the script to be done")
+		t.FailNow()
+	}
+}
+
+func TestKeyScriptRejectsInvalidLines(t *testing.T) {
+	for _, line := range []string{"type Hello!", "key enter", "wait soon", "delay 1s", "press 65", "wait"} {
+		if _, err := ParseKeyScript(strings.NewReader(line)); err == nil {
+			t.Logf("Expected '%s' to be rejected", line)
+			t.FailNow()
+		}
+	}
+}
+
+func TestScriptedKeyboardCarriesOnFromItsState(t *testing.T) {
+	script, err := ParseKeyScript(strings.NewReader("wait 2\nkey 65\nwait 2\nkey 66"))
+	if err != nil {
+		t.Logf("Error parsing key script: %v", err)
+		t.FailNow()
+	}
+
+	keyboard := NewScriptedKeyboard(script)
+	for i := 0; i < 4; i++ {
+		keyboard.Tick()
+	}
+
+	bus := components.NewBus(BUS_WIDTH)
+	restored := NewScriptedKeyboard(script)
+	restored.ConnectTo(bus)
+	if err := restored.SetState(keyboard.State()); err != nil {
+		t.Logf("Error restoring the state: %v", err)
+		t.FailNow()
+	}
+	restored.Tick()
+	if bus.Value() != 66 || !restored.Done() {
+		t.Logf("Expected the second key to be pressed on the next cycle but got 0x%04X", bus.Value())
+		t.FailNow()
+	}
+
+	if err := NewScriptedKeyboard(new(KeyScript)).SetState(keyboard.State()); err == nil {
+		t.Logf("Expected an error restoring the state of a longer script")
+		t.FailNow()
+	}
+}
@@ -34,6 +34,9 @@ type KeyboardAdapter struct {
 	notGatesForAndGate3 [2]circuit.NOTGate
 
 	andGate4 circuit.ANDGate
+
+	// raised while there is a keycode waiting to be read
+	interruptORGate components.ORGateN
 }
 
 func NewKeyboardAdapter() *KeyboardAdapter {
@@ -52,6 +55,7 @@ func (k *KeyboardAdapter) Connect(ioBus *components.IOBus, mainBus *components.B
 	k.andGate2 = *components.NewANDGate3()
 	k.andGate3 = *components.NewANDGate3()
 	k.andGate4 = *circuit.NewANDGate()
+	k.interruptORGate = *components.NewORGateN(BUS_WIDTH)
 	k.keycodeRegister = *components.NewRegister("KCR", k.KeyboardInBus, k.mainBus)
 
 	for i := range k.notGatesForAndGate1 {
@@ -63,9 +67,35 @@ func (k *KeyboardAdapter) Connect(ioBus *components.IOBus, mainBus *components.B
 	}
 }
 
+// KeyboardAdapterState is the key code waiting to be read and whether the adapter is selected
+type KeyboardAdapterState struct {
+	Keycode  uint16
+	Selected bool
+}
+
+func (k *KeyboardAdapter) State() KeyboardAdapterState {
+	return KeyboardAdapterState{k.KeyboardInBus.Value(), k.memoryBit.Get()}
+}
+
+func (k *KeyboardAdapter) SetState(s KeyboardAdapterState) {
+	k.KeyboardInBus.SetValue(s.Keycode)
+	k.memoryBit.Update(s.Selected, true)
+	k.memoryBit.Update(s.Selected, false)
+}
+
 func (k *KeyboardAdapter) Update() {
 	k.updateKeycodeReg()
 	k.update()
+	k.updateInterrupt()
+}
+
+func (k *KeyboardAdapter) updateInterrupt() {
+	var keycode [BUS_WIDTH]bool
+	for i := range keycode {
+		keycode[i] = k.KeyboardInBus.GetOutputWire(i)
+	}
+	k.interruptORGate.Update(keycode[:]...)
+	k.ioBus.UpdateInterruptLine(KEYBOARD_INTERRUPT_LINE, k.interruptORGate.Output())
 }
 
 func (k *KeyboardAdapter) update() {
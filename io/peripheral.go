@@ -8,3 +8,9 @@ type Peripheral interface {
 	Connect(*components.IOBus, *components.Bus)
 	Update()
 }
+
+// Clocked is a peripheral that counts the cycles of the CPU, Tick is called at the start of every
+// round of the stepper, before step 1. A halted CPU does not tick its peripherals
+type Clocked interface {
+	Tick()
+}
@@ -0,0 +1,270 @@
+package io
+
+import (
+	"bufio"
+	goio "io"
+	"log"
+	"os"
+
+	"github.com/djhworld/simple-computer/circuit"
+	"github.com/djhworld/simple-computer/components"
+)
+
+// the IO address of the serial adapter
+const SERIAL_ADDRESS = 0x0004
+
+// set in the value read with IN Data when a byte has been received, the byte is in bits 0 - 7
+const SERIAL_READY_BIT = 0x8000
+
+// [cpu] <-------------> serial adapter <-------------> serial port <-------------> [host reader/writer]
+//        read/write                       read/write                read/write
+//
+// OUT Addr 0x0004 selects the adapter, after that OUT Data sends bits 0 - 7 of the value and IN Data
+// reads the byte received with SERIAL_READY_BIT set, or 0 if there is no byte waiting, a byte is only read once.
+// The adapter raises SERIAL_INTERRUPT_LINE while a byte is waiting to be read
+type SerialAdapter struct {
+	// the byte received by the port with the ready bit, 0 while there is no byte waiting
+	SerialInBus *components.Bus
+	// the byte to send
+	SerialOutBus *components.Bus
+
+	ioBus   *components.IOBus
+	mainBus *components.Bus
+	port    *SerialPort
+
+	receiveRegister components.Register
+	sendRegister    components.Register
+
+	adapterActiveBit *components.Bit
+
+	addressSelectAndGate  components.ANDGate8
+	addressSelectNOTGates [7]circuit.NOTGate
+
+	isAddressOutputModeGate components.ANDGate3
+	isDataOutputModeGate    components.ANDGate3
+	isDataInputModeGate     components.ANDGate3
+	dataModeNOTGates        [2]circuit.NOTGate
+	sendGate                circuit.ANDGate
+	receiveGate             circuit.ANDGate
+	readyNOTGate            circuit.NOTGate
+}
+
+func NewSerialAdapter() *SerialAdapter {
+	s := new(SerialAdapter)
+	s.SerialInBus = components.NewBus(BUS_WIDTH)
+	s.SerialOutBus = components.NewBus(BUS_WIDTH)
+	return s
+}
+
+// ConnectPort connects the host side of the serial port, without one the bytes sent are lost and none are received
+func (s *SerialAdapter) ConnectPort(port *SerialPort) {
+	s.port = port
+}
+
+func (s *SerialAdapter) Connect(ioBus *components.IOBus, mainBus *components.Bus) {
+	s.ioBus = ioBus
+	s.mainBus = mainBus
+
+	s.receiveRegister = *components.NewRegister("SRR", s.SerialInBus, s.mainBus)
+	s.sendRegister = *components.NewRegister("SSR", s.mainBus, s.SerialOutBus)
+	s.sendRegister.Enable()
+
+	s.adapterActiveBit = components.NewBit()
+	s.adapterActiveBit.Update(false, true)
+	s.adapterActiveBit.Update(false, false)
+
+	s.addressSelectAndGate = *components.NewANDGate8()
+	for n := range s.addressSelectNOTGates {
+		s.addressSelectNOTGates[n] = *circuit.NewNOTGate()
+	}
+
+	s.isAddressOutputModeGate = *components.NewANDGate3()
+	s.isDataOutputModeGate = *components.NewANDGate3()
+	s.isDataInputModeGate = *components.NewANDGate3()
+	for n := range s.dataModeNOTGates {
+		s.dataModeNOTGates[n] = *circuit.NewNOTGate()
+	}
+	s.sendGate = *circuit.NewANDGate()
+	s.receiveGate = *circuit.NewANDGate()
+	s.readyNOTGate = *circuit.NewNOTGate()
+}
+
+// SerialAdapterState is the byte waiting to be read with the ready bit and whether the adapter is selected
+type SerialAdapterState struct {
+	Received uint16
+	Selected bool
+}
+
+func (s *SerialAdapter) State() SerialAdapterState {
+	return SerialAdapterState{s.SerialInBus.Value(), s.adapterActiveBit.Get()}
+}
+
+func (s *SerialAdapter) SetState(state SerialAdapterState) {
+	s.SerialInBus.SetValue(state.Received)
+	s.adapterActiveBit.Update(state.Selected, true)
+	s.adapterActiveBit.Update(state.Selected, false)
+}
+
+func (s *SerialAdapter) Update() {
+	s.updateActive()
+	s.updateSend()
+	s.updateReceive()
+	s.ioBus.UpdateInterruptLine(SERIAL_INTERRUPT_LINE, s.SerialInBus.GetOutputWire(0))
+}
+
+func (s *SerialAdapter) updateActive() {
+	// check if bus = 0x0004
+	for n := 0; n < 5; n++ {
+		s.addressSelectNOTGates[n].Update(s.mainBus.GetOutputWire(n + 8))
+	}
+	s.addressSelectNOTGates[5].Update(s.mainBus.GetOutputWire(14))
+	s.addressSelectNOTGates[6].Update(s.mainBus.GetOutputWire(15))
+	s.addressSelectAndGate.Update(
+		s.addressSelectNOTGates[0].Output(),
+		s.addressSelectNOTGates[1].Output(),
+		s.addressSelectNOTGates[2].Output(),
+		s.addressSelectNOTGates[3].Output(),
+		s.addressSelectNOTGates[4].Output(),
+		s.mainBus.GetOutputWire(13),
+		s.addressSelectNOTGates[5].Output(),
+		s.addressSelectNOTGates[6].Output(),
+	)
+
+	s.isAddressOutputModeGate.Update(
+		s.ioBus.IsSet(),
+		s.ioBus.IsAddressMode(),
+		s.ioBus.IsOutputMode(),
+	)
+
+	s.adapterActiveBit.Update(s.addressSelectAndGate.Output(), s.isAddressOutputModeGate.Output())
+}
+
+func (s *SerialAdapter) updateSend() {
+	s.dataModeNOTGates[0].Update(s.ioBus.GetOutputWire(components.DATA_OR_ADDRESS))
+	s.isDataOutputModeGate.Update(
+		s.ioBus.IsSet(),
+		s.dataModeNOTGates[0].Output(),
+		s.ioBus.IsOutputMode(),
+	)
+	s.sendGate.Update(s.adapterActiveBit.Get(), s.isDataOutputModeGate.Output())
+
+	if s.sendGate.Output() {
+		s.sendRegister.Set()
+		s.sendRegister.Update()
+		s.sendRegister.Unset()
+		s.sendRegister.Update()
+
+		if s.port != nil {
+			s.port.send(byte(s.SerialOutBus.Value()))
+		}
+	}
+}
+
+func (s *SerialAdapter) updateReceive() {
+	// take the next byte from the port once the last one has been read
+	s.readyNOTGate.Update(s.SerialInBus.GetOutputWire(0))
+	if s.readyNOTGate.Output() && s.port != nil {
+		if b, ok := s.port.receive(); ok {
+			s.SerialInBus.SetValue(SERIAL_READY_BIT | uint16(b))
+		}
+	}
+
+	s.dataModeNOTGates[1].Update(s.ioBus.GetOutputWire(components.MODE))
+	s.isDataInputModeGate.Update(
+		s.ioBus.IsEnable(),
+		s.dataModeNOTGates[0].Output(),
+		s.dataModeNOTGates[1].Output(),
+	)
+	s.receiveGate.Update(s.adapterActiveBit.Get(), s.isDataInputModeGate.Output())
+
+	if s.receiveGate.Output() {
+		s.receiveRegister.Set()
+
+		s.receiveRegister.Enable()
+		s.receiveRegister.Update()
+		s.receiveRegister.Disable()
+
+		// clear the register once everything is out
+		s.SerialInBus.SetValue(0x0000)
+		s.receiveRegister.Update()
+		s.receiveRegister.Unset()
+		s.receiveRegister.Update()
+	}
+}
+
+// SerialPort is the host side of the serial adapter, the bytes sent by the computer are written
+// to a writer and the bytes read from a reader are received by the computer
+type SerialPort struct {
+	out      goio.Writer
+	in       goio.Reader
+	received chan byte
+	quit     chan bool
+}
+
+// NewSerialPort returns a port that writes to out and reads from in, in can be nil if nothing is sent to the computer
+func NewSerialPort(in goio.Reader, out goio.Writer, quit chan bool) *SerialPort {
+	s := new(SerialPort)
+	s.in = in
+	s.out = out
+	s.received = make(chan byte, 256)
+	s.quit = quit
+	return s
+}
+
+// OpenSerialPort connects a port to filename, a terminal such as a pty is read and written
+// while a regular file is created and only gets the bytes sent by the computer
+func OpenSerialPort(filename string, quit chan bool) (*SerialPort, error) {
+	if info, err := os.Stat(filename); err == nil && !info.Mode().IsRegular() {
+		f, err := os.OpenFile(filename, os.O_RDWR, 0)
+		if err != nil {
+			return nil, err
+		}
+		return NewSerialPort(f, f, quit), nil
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewSerialPort(nil, f, quit), nil
+}
+
+// Run reads from the reader until it runs out or the quit channel is closed
+func (s *SerialPort) Run() {
+	if s.in == nil {
+		return
+	}
+
+	reader := bufio.NewReader(s.in)
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			if err != goio.EOF {
+				log.Println("error reading serial input", err)
+			}
+			return
+		}
+
+		select {
+		case <-s.quit:
+			log.Println("Stopping serial port")
+			return
+		case s.received <- b:
+		}
+	}
+}
+
+func (s *SerialPort) send(b byte) {
+	if _, err := s.out.Write([]byte{b}); err != nil {
+		log.Println("error writing serial output", err)
+	}
+}
+
+func (s *SerialPort) receive() (byte, bool) {
+	select {
+	case b := <-s.received:
+		return b, true
+	default:
+		return 0, false
+	}
+}
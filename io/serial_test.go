@@ -0,0 +1,75 @@
+package io
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/djhworld/simple-computer/components"
+)
+
+func TestSerialAdapterSendsLowByte(t *testing.T) {
+	ioBus := components.NewIOBus()
+	mainBus := components.NewBus(BUS_WIDTH)
+
+	var out bytes.Buffer
+	adapter := NewSerialAdapter()
+	adapter.Connect(ioBus, mainBus)
+	adapter.ConnectPort(NewSerialPort(nil, &out, nil))
+
+	selectIO(ioBus, mainBus, adapter, SERIAL_ADDRESS)
+	writeData(ioBus, mainBus, adapter, 0x0148)
+	writeData(ioBus, mainBus, adapter, 0x0069)
+
+	if out.String() != "Hi" {
+		t.Logf("Expected \"Hi\" to be sent but got %q", out.String())
+		t.FailNow()
+	}
+}
+
+func TestSerialAdapterReceivesBytesWithReadyBit(t *testing.T) {
+	ioBus := components.NewIOBus()
+	mainBus := components.NewBus(BUS_WIDTH)
+
+	port := NewSerialPort(strings.NewReader("ok"), &bytes.Buffer{}, nil)
+	port.Run()
+
+	adapter := NewSerialAdapter()
+	adapter.Connect(ioBus, mainBus)
+	adapter.ConnectPort(port)
+
+	selectIO(ioBus, mainBus, adapter, SERIAL_ADDRESS)
+	if !ioBus.IsInterruptLineRaised(SERIAL_INTERRUPT_LINE) {
+		t.Logf("Expected the interrupt line to be raised while a byte is waiting")
+		t.FailNow()
+	}
+
+	for _, expected := range []uint16{SERIAL_READY_BIT | 'o', SERIAL_READY_BIT | 'k', 0x0000} {
+		if actual := readData(ioBus, mainBus, adapter); actual != expected {
+			t.Logf("Expected to read 0x%04X but got 0x%04X", expected, actual)
+			t.FailNow()
+		}
+	}
+
+	if ioBus.IsInterruptLineRaised(SERIAL_INTERRUPT_LINE) {
+		t.Logf("Expected the interrupt line to be lowered once everything is read")
+		t.FailNow()
+	}
+}
+
+func TestSerialAdapterWithoutPortReadsZero(t *testing.T) {
+	ioBus := components.NewIOBus()
+	mainBus := components.NewBus(BUS_WIDTH)
+
+	adapter := NewSerialAdapter()
+	adapter.Connect(ioBus, mainBus)
+
+	selectIO(ioBus, mainBus, adapter, SERIAL_ADDRESS)
+	writeData(ioBus, mainBus, adapter, 0x0041)
+
+	mainBus.SetValue(0xFFFF)
+	if actual := readData(ioBus, mainBus, adapter); actual != 0x0000 {
+		t.Logf("Expected to read 0x0000 but got 0x%04X", actual)
+		t.FailNow()
+	}
+}
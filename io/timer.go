@@ -0,0 +1,302 @@
+package io
+
+import (
+	"github.com/djhworld/simple-computer/circuit"
+	"github.com/djhworld/simple-computer/components"
+)
+
+// the IO address of the timer
+const TIMER_ADDRESS = 0x0002
+
+// the status read with IN Data has the expired bit in bit 15 and the counter in bits 0 - 14
+const TIMER_EXPIRED_BIT = 0x8000
+
+// [cpu] -------> timer -------> interrupt controller
+//        load/read       raise
+//
+// OUT Addr 0x0002 selects the timer, after that OUT Data loads the reload count (bits 0 - 14,
+// bit 15 is ignored) into the reload and counter registers and clears the expired bit, 0 stops the timer.
+// The counter counts down once every cycle of the CPU (see Clocked). When it reaches 0 the expired
+// bit is set and the counter starts again from the reload count, so the timer expires every
+// reload count cycles. IN Data reads the expired bit and the counter, the expired bit is
+// cleared at the next cycle. The timer raises TIMER_INTERRUPT_LINE while the expired bit is set
+type Timer struct {
+	ioBus   *components.IOBus
+	mainBus *components.Bus
+
+	counterInBus    *components.Bus
+	reloadBus       *components.Bus
+	counterBus      *components.Bus
+	statusBus       *components.Bus
+	reloadRegister  components.Register
+	counterRegister components.Register
+	statusRegister  components.Register
+
+	timerActiveBit *components.Bit
+	expiredBit     *components.Bit
+	// set when the status has been read, the expired bit is cleared at the next tick
+	readBit *components.Bit
+
+	addressSelectAndGate  components.ANDGate8
+	addressSelectNOTGates [7]circuit.NOTGate
+
+	isAddressOutputModeGate components.ANDGate3
+	isDataOutputModeGate    components.ANDGate3
+	isDataInputModeGate     components.ANDGate3
+	dataModeNOTGates        [2]circuit.NOTGate
+	loadGate                circuit.ANDGate
+	statusEnableGate        circuit.ANDGate
+
+	// the counter register is loaded from the main bus, the reload register or the decrementer
+	loadEnabler        components.Enabler
+	reloadEnabler      components.Enabler
+	decrementEnabler   components.Enabler
+	counterInORers     [2]components.ORer
+	decrementer        components.Adder
+	counterIsZero      components.IsZero
+	decrementedIsZero  components.IsZero
+	runningNOTGate     circuit.NOTGate
+	decrementedNOTGate circuit.NOTGate
+	expireGate         circuit.ANDGate
+	decrementGate      circuit.ANDGate
+}
+
+func NewTimer() *Timer {
+	t := new(Timer)
+	return t
+}
+
+func (t *Timer) Connect(ioBus *components.IOBus, mainBus *components.Bus) {
+	t.ioBus = ioBus
+	t.mainBus = mainBus
+
+	t.counterInBus = components.NewBus(BUS_WIDTH)
+	t.reloadBus = components.NewBus(BUS_WIDTH)
+	t.counterBus = components.NewBus(BUS_WIDTH)
+	t.statusBus = components.NewBus(BUS_WIDTH)
+
+	// the reload and counter registers are always enabled, and we initialise them with value 0 so the timer is stopped
+	t.reloadRegister = *components.NewRegister("TRR", t.counterInBus, t.reloadBus)
+	t.reloadRegister.Enable()
+	t.counterRegister = *components.NewRegister("TCR", t.counterInBus, t.counterBus)
+	t.counterRegister.Enable()
+	t.setRegister(&t.reloadRegister)
+	t.setRegister(&t.counterRegister)
+	t.statusRegister = *components.NewRegister("TSR", t.statusBus, t.mainBus)
+
+	t.timerActiveBit = components.NewBit()
+	t.timerActiveBit.Update(false, true)
+	t.timerActiveBit.Update(false, false)
+	t.expiredBit = components.NewBit()
+	t.expiredBit.Update(false, true)
+	t.expiredBit.Update(false, false)
+	t.readBit = components.NewBit()
+	t.readBit.Update(false, true)
+	t.readBit.Update(false, false)
+
+	t.addressSelectAndGate = *components.NewANDGate8()
+	for n := range t.addressSelectNOTGates {
+		t.addressSelectNOTGates[n] = *circuit.NewNOTGate()
+	}
+
+	t.isAddressOutputModeGate = *components.NewANDGate3()
+	t.isDataOutputModeGate = *components.NewANDGate3()
+	t.isDataInputModeGate = *components.NewANDGate3()
+	for n := range t.dataModeNOTGates {
+		t.dataModeNOTGates[n] = *circuit.NewNOTGate()
+	}
+	t.loadGate = *circuit.NewANDGate()
+	t.statusEnableGate = *circuit.NewANDGate()
+
+	t.loadEnabler = *components.NewEnabler()
+	t.reloadEnabler = *components.NewEnabler()
+	t.decrementEnabler = *components.NewEnabler()
+	for n := range t.counterInORers {
+		t.counterInORers[n] = *components.NewORer()
+	}
+	t.decrementer = *components.NewAdder()
+	// adding 0xFFFF takes one off the counter
+	for n := BUS_WIDTH; n < BUS_WIDTH*2; n++ {
+		t.decrementer.SetInputWire(n, true)
+	}
+	t.counterIsZero = *components.NewIsZero()
+	t.decrementedIsZero = *components.NewIsZero()
+	t.runningNOTGate = *circuit.NewNOTGate()
+	t.decrementedNOTGate = *circuit.NewNOTGate()
+	t.expireGate = *circuit.NewANDGate()
+	t.decrementGate = *circuit.NewANDGate()
+}
+
+// TimerState is the reload count, the counter, the expired and read bits and whether the timer is selected
+type TimerState struct {
+	Reload   uint16
+	Counter  uint16
+	Expired  bool
+	Read     bool
+	Selected bool
+}
+
+func (t *Timer) State() TimerState {
+	return TimerState{t.reloadRegister.Value(), t.counterRegister.Value(), t.expiredBit.Get(), t.readBit.Get(), t.timerActiveBit.Get()}
+}
+
+func (t *Timer) SetState(s TimerState) {
+	t.counterInBus.SetValue(s.Reload)
+	t.setRegister(&t.reloadRegister)
+	t.counterInBus.SetValue(s.Counter)
+	t.setRegister(&t.counterRegister)
+
+	t.expiredBit.Update(s.Expired, true)
+	t.expiredBit.Update(s.Expired, false)
+	t.readBit.Update(s.Read, true)
+	t.readBit.Update(s.Read, false)
+	t.timerActiveBit.Update(s.Selected, true)
+	t.timerActiveBit.Update(s.Selected, false)
+}
+
+func (t *Timer) Update() {
+	t.updateActive()
+	t.updateLoad()
+	t.updateStatus()
+	t.ioBus.UpdateInterruptLine(TIMER_INTERRUPT_LINE, t.expiredBit.Get())
+}
+
+// Tick counts down one cycle
+func (t *Timer) Tick() {
+	// a read clears the expired bit before counting, so the timer can expire again in this cycle
+	t.expiredBit.Update(false, t.readBit.Get())
+	t.readBit.Update(false, true)
+	t.readBit.Update(false, false)
+
+	for n := 0; n < BUS_WIDTH; n++ {
+		t.counterIsZero.SetInputWire(n, t.counterBus.GetOutputWire(n))
+		t.decrementer.SetInputWire(n, t.counterBus.GetOutputWire(n))
+	}
+	t.counterIsZero.Update()
+	t.runningNOTGate.Update(t.counterIsZero.GetOutputWire(0))
+	t.decrementer.Update(false)
+
+	for n := 0; n < BUS_WIDTH; n++ {
+		t.decrementedIsZero.SetInputWire(n, t.decrementer.GetOutputWire(n))
+	}
+	t.decrementedIsZero.Update()
+	t.decrementedNOTGate.Update(t.decrementedIsZero.GetOutputWire(0))
+
+	t.expireGate.Update(t.runningNOTGate.Output(), t.decrementedIsZero.GetOutputWire(0))
+	t.decrementGate.Update(t.runningNOTGate.Output(), t.decrementedNOTGate.Output())
+	t.expiredBit.Update(true, t.expireGate.Output())
+
+	if t.runningNOTGate.Output() {
+		t.updateCounterIn(false, t.expireGate.Output(), t.decrementGate.Output())
+		t.setRegister(&t.counterRegister)
+	}
+}
+
+func (t *Timer) updateActive() {
+	// check if bus = 0x0002
+	for n := 0; n < 6; n++ {
+		t.addressSelectNOTGates[n].Update(t.mainBus.GetOutputWire(n + 8))
+	}
+	t.addressSelectNOTGates[6].Update(t.mainBus.GetOutputWire(15))
+	t.addressSelectAndGate.Update(
+		t.addressSelectNOTGates[0].Output(),
+		t.addressSelectNOTGates[1].Output(),
+		t.addressSelectNOTGates[2].Output(),
+		t.addressSelectNOTGates[3].Output(),
+		t.addressSelectNOTGates[4].Output(),
+		t.addressSelectNOTGates[5].Output(),
+		t.mainBus.GetOutputWire(14),
+		t.addressSelectNOTGates[6].Output(),
+	)
+
+	t.isAddressOutputModeGate.Update(
+		t.ioBus.IsSet(),
+		t.ioBus.IsAddressMode(),
+		t.ioBus.IsOutputMode(),
+	)
+
+	t.timerActiveBit.Update(t.addressSelectAndGate.Output(), t.isAddressOutputModeGate.Output())
+}
+
+func (t *Timer) updateLoad() {
+	t.dataModeNOTGates[0].Update(t.ioBus.GetOutputWire(components.DATA_OR_ADDRESS))
+	t.isDataOutputModeGate.Update(
+		t.ioBus.IsSet(),
+		t.dataModeNOTGates[0].Output(),
+		t.ioBus.IsOutputMode(),
+	)
+	t.loadGate.Update(t.timerActiveBit.Get(), t.isDataOutputModeGate.Output())
+
+	if t.loadGate.Output() {
+		t.updateCounterIn(true, false, false)
+		t.setRegister(&t.reloadRegister)
+		t.setRegister(&t.counterRegister)
+		t.expiredBit.Update(false, true)
+		t.expiredBit.Update(false, false)
+		t.readBit.Update(false, true)
+		t.readBit.Update(false, false)
+	}
+}
+
+func (t *Timer) updateStatus() {
+	// the expired bit replaces bit 15 of the counter, which is always 0
+	t.statusBus.SetInputWire(0, t.expiredBit.Get())
+	for n := 1; n < BUS_WIDTH; n++ {
+		t.statusBus.SetInputWire(n, t.counterBus.GetOutputWire(n))
+	}
+
+	t.dataModeNOTGates[1].Update(t.ioBus.GetOutputWire(components.MODE))
+	t.isDataInputModeGate.Update(
+		t.ioBus.IsEnable(),
+		t.dataModeNOTGates[0].Output(),
+		t.dataModeNOTGates[1].Output(),
+	)
+	t.statusEnableGate.Update(t.timerActiveBit.Get(), t.isDataInputModeGate.Output())
+
+	if t.statusEnableGate.Output() {
+		t.statusRegister.Set()
+		t.statusRegister.Enable()
+		t.statusRegister.Update()
+		t.statusRegister.Disable()
+		t.statusRegister.Unset()
+		t.statusRegister.Update()
+		t.readBit.Update(true, true)
+		t.readBit.Update(true, false)
+	}
+}
+
+// updateCounterIn puts the main bus, the reload register or the decremented counter on the counter
+// in bus, bit 15 is never set so the counter fits beside the expired bit in the status
+func (t *Timer) updateCounterIn(load, reload, decrement bool) {
+	for n := 1; n < BUS_WIDTH; n++ {
+		t.loadEnabler.SetInputWire(n, t.mainBus.GetOutputWire(n))
+		t.reloadEnabler.SetInputWire(n, t.reloadBus.GetOutputWire(n))
+		t.decrementEnabler.SetInputWire(n, t.decrementer.GetOutputWire(n))
+	}
+	t.loadEnabler.Update(load)
+	t.reloadEnabler.Update(reload)
+	t.decrementEnabler.Update(decrement)
+
+	for n := 0; n < BUS_WIDTH; n++ {
+		t.counterInORers[0].SetInputWire(n, t.loadEnabler.GetOutputWire(n))
+		t.counterInORers[0].SetInputWire(n+BUS_WIDTH, t.reloadEnabler.GetOutputWire(n))
+	}
+	t.counterInORers[0].Update()
+	for n := 0; n < BUS_WIDTH; n++ {
+		t.counterInORers[1].SetInputWire(n, t.counterInORers[0].GetOutputWire(n))
+		t.counterInORers[1].SetInputWire(n+BUS_WIDTH, t.decrementEnabler.GetOutputWire(n))
+	}
+	t.counterInORers[1].Update()
+
+	for n := 0; n < BUS_WIDTH; n++ {
+		t.counterInBus.SetInputWire(n, t.counterInORers[1].GetOutputWire(n))
+	}
+}
+
+// setRegister stores the counter in bus in r
+func (t *Timer) setRegister(r *components.Register) {
+	r.Set()
+	r.Update()
+	r.Unset()
+	r.Update()
+}
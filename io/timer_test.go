@@ -0,0 +1,147 @@
+package io
+
+import (
+	"testing"
+
+	"github.com/djhworld/simple-computer/components"
+)
+
+func TestTimerExpiresEveryReloadCountCycles(t *testing.T) {
+	ioBus := components.NewIOBus()
+	mainBus := components.NewBus(BUS_WIDTH)
+
+	timer := NewTimer()
+	timer.Connect(ioBus, mainBus)
+	loadTimer(ioBus, mainBus, timer, 0x0003)
+
+	for cycle := 1; cycle <= 9; cycle++ {
+		timer.Tick()
+		timer.Update()
+
+		expired := cycle%3 == 0
+		if timer.State().Expired != expired || ioBus.IsInterruptLineRaised(TIMER_INTERRUPT_LINE) != expired {
+			t.Logf("Expected the timer to be expired = %v at cycle %d but got %+v", expired, cycle, timer.State())
+			t.FailNow()
+		}
+		if timer.State().Counter != uint16(3-cycle%3) {
+			t.Logf("Expected the counter to be %d at cycle %d but got %+v", 3-cycle%3, cycle, timer.State())
+			t.FailNow()
+		}
+
+		if expired {
+			readTimer(ioBus, mainBus, timer)
+		}
+	}
+}
+
+func TestTimerPutsStatusOnBusAndClearsExpiredAtNextCycle(t *testing.T) {
+	ioBus := components.NewIOBus()
+	mainBus := components.NewBus(BUS_WIDTH)
+
+	timer := NewTimer()
+	timer.Connect(ioBus, mainBus)
+	loadTimer(ioBus, mainBus, timer, 0x0002)
+
+	timer.Tick()
+	if status := readTimer(ioBus, mainBus, timer); status != 0x0001 {
+		t.Logf("Expected status 0x0001 but got 0x%04X", status)
+		t.FailNow()
+	}
+
+	timer.Tick()
+	if status := readTimer(ioBus, mainBus, timer); status != TIMER_EXPIRED_BIT|0x0002 {
+		t.Logf("Expected status 0x8002 but got 0x%04X", status)
+		t.FailNow()
+	}
+
+	timer.Tick()
+	timer.Update()
+	if timer.State().Expired || ioBus.IsInterruptLineRaised(TIMER_INTERRUPT_LINE) {
+		t.Logf("Expected reading the status to clear the expired bit but got %+v", timer.State())
+		t.FailNow()
+	}
+}
+
+func TestTimerIsStoppedWithZeroAndIgnoresBit15(t *testing.T) {
+	ioBus := components.NewIOBus()
+	mainBus := components.NewBus(BUS_WIDTH)
+
+	timer := NewTimer()
+	timer.Connect(ioBus, mainBus)
+
+	timer.Tick()
+	timer.Update()
+	if timer.State() != (TimerState{}) || ioBus.IsInterruptLineRaised(TIMER_INTERRUPT_LINE) {
+		t.Logf("Expected the timer to power up stopped but got %+v", timer.State())
+		t.FailNow()
+	}
+
+	loadTimer(ioBus, mainBus, timer, 0x8005)
+	if timer.State().Reload != 0x0005 || timer.State().Counter != 0x0005 {
+		t.Logf("Expected bit 15 of the reload count to be ignored but got %+v", timer.State())
+		t.FailNow()
+	}
+
+	loadTimer(ioBus, mainBus, timer, 0x0000)
+	for cycle := 0; cycle < 10; cycle++ {
+		timer.Tick()
+	}
+	if timer.State().Counter != 0x0000 || timer.State().Expired {
+		t.Logf("Expected the stopped timer not to count but got %+v", timer.State())
+		t.FailNow()
+	}
+}
+
+func TestTimerIgnoresOtherAddresses(t *testing.T) {
+	ioBus := components.NewIOBus()
+	mainBus := components.NewBus(BUS_WIDTH)
+
+	timer := NewTimer()
+	timer.Connect(ioBus, mainBus)
+	loadTimer(ioBus, mainBus, timer, 0x0004)
+
+	selectIO(ioBus, mainBus, timer, 0x0003)
+	mainBus.SetValue(0x0009)
+	ioBus.Set()
+	ioBus.Update(true, false)
+	timer.Update()
+	ioBus.Unset()
+	timer.Update()
+
+	if timer.State().Reload != 0x0004 || timer.State().Selected {
+		t.Logf("Expected the timer not to be selected by 0x0003 but got %+v", timer.State())
+		t.FailNow()
+	}
+}
+
+func selectIO(ioBus *components.IOBus, mainBus *components.Bus, p Peripheral, address uint16) {
+	mainBus.SetValue(address)
+	ioBus.Set()
+	ioBus.Update(true, true)
+	p.Update()
+	ioBus.Unset()
+	p.Update()
+}
+
+func loadTimer(ioBus *components.IOBus, mainBus *components.Bus, timer *Timer, reload uint16) {
+	selectIO(ioBus, mainBus, timer, TIMER_ADDRESS)
+
+	mainBus.SetValue(reload)
+	ioBus.Set()
+	ioBus.Update(true, false)
+	timer.Update()
+	ioBus.Unset()
+	timer.Update()
+}
+
+func readTimer(ioBus *components.IOBus, mainBus *components.Bus, timer *Timer) uint16 {
+	selectIO(ioBus, mainBus, timer, TIMER_ADDRESS)
+
+	mainBus.SetValue(0x0000)
+	ioBus.Enable()
+	ioBus.Update(false, false)
+	timer.Update()
+	ioBus.Disable()
+	timer.Update()
+	return mainBus.Value()
+}
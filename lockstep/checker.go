@@ -0,0 +1,220 @@
+package lockstep
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/djhworld/simple-computer/behavioural"
+	"github.com/djhworld/simple-computer/components"
+	"github.com/djhworld/simple-computer/computer"
+	"github.com/djhworld/simple-computer/cpu"
+	"github.com/djhworld/simple-computer/io"
+	"github.com/djhworld/simple-computer/memory"
+)
+
+// State is what the checker compares after every instruction
+type State struct {
+	Registers [4]uint16
+	IAR       uint16
+	Carry     bool
+	ALarger   bool
+	Equal     bool
+	Zero      bool
+	// the RAM written by either CPU during the instruction
+	Memory map[uint16]uint16
+}
+
+func (s State) String() string {
+	flags := ""
+	for i, on := range []bool{s.Carry, s.ALarger, s.Equal, s.Zero} {
+		if on {
+			flags += string("CAEZ"[i])
+		} else {
+			flags += "-"
+		}
+	}
+
+	result := fmt.Sprintf("R0: 0x%04X  R1: 0x%04X  R2: 0x%04X  R3: 0x%04X  IAR: 0x%04X  FLAGS: %s",
+		s.Registers[0], s.Registers[1], s.Registers[2], s.Registers[3], s.IAR, flags)
+
+	addresses := make([]int, 0, len(s.Memory))
+	for address := range s.Memory {
+		addresses = append(addresses, int(address))
+	}
+	sort.Ints(addresses)
+	for _, address := range addresses {
+		result += fmt.Sprintf("  [0x%04X]: 0x%04X", address, s.Memory[uint16(address)])
+	}
+	return result
+}
+
+// Divergence is the first instruction after which the two CPUs are not in the same state
+type Divergence struct {
+	// the number of instructions that ran before this one
+	Instruction int
+	Address     uint16
+	Word        uint16
+	Gates       State
+	Reference   State
+}
+
+func (d *Divergence) Error() string {
+	return fmt.Sprintf("instruction %d at 0x%04X (0x%04X) diverged in %s\ngate level:  %s\nbehavioural: %s",
+		d.Instruction, d.Address, d.Word, strings.Join(d.Differences(), ", "), d.Gates, d.Reference)
+}
+
+// Differences returns the names of the registers and the RAM addresses that differ
+func (d *Divergence) Differences() []string {
+	differences := []string{}
+	for i := range d.Gates.Registers {
+		if d.Gates.Registers[i] != d.Reference.Registers[i] {
+			differences = append(differences, fmt.Sprintf("R%d", i))
+		}
+	}
+	if d.Gates.IAR != d.Reference.IAR {
+		differences = append(differences, "IAR")
+	}
+	if d.Gates.Carry != d.Reference.Carry || d.Gates.ALarger != d.Reference.ALarger ||
+		d.Gates.Equal != d.Reference.Equal || d.Gates.Zero != d.Reference.Zero {
+		differences = append(differences, "FLAGS")
+	}
+
+	// the same addresses are read from both CPUs
+	addresses := []int{}
+	for address, value := range d.Gates.Memory {
+		if d.Reference.Memory[address] != value {
+			addresses = append(addresses, int(address))
+		}
+	}
+	sort.Ints(addresses)
+	for _, address := range addresses {
+		differences = append(differences, fmt.Sprintf("[0x%04X]", address))
+	}
+	return differences
+}
+
+// Checker runs a program on the gate level cpu.CPU and on the behavioural CPU, which acts as the
+// reference interpreter of the instruction set, an instruction at a time and stops at the first
+// instruction the two do not agree on. Both CPUs have the same peripherals as the computer
+type Checker struct {
+	gates     *cpu.CPU
+	reference *behavioural.CPU
+
+	keyboards []*io.KeyboardAdapter
+
+	// the addresses written by either CPU during the current instruction
+	written map[uint16]bool
+
+	instructions int
+}
+
+// NewChecker loads the program into the code region of both CPUs and sets them up the same
+// way the computer does when it boots
+func NewChecker(program []uint16) *Checker {
+	c := new(Checker)
+	c.written = make(map[uint16]bool)
+
+	gatesBus := components.NewBus(cpu.BUS_WIDTH)
+	gatesMemory := memory.NewMemory64K(gatesBus)
+	c.gates = cpu.NewCPU(gatesBus, gatesMemory)
+	c.reference = behavioural.NewCPU(components.NewBus(cpu.BUS_WIDTH))
+
+	for _, p := range []cpu.Processor{c.gates, c.reference} {
+		keyboard := io.NewKeyboardAdapter()
+		p.ConnectPeripheral(keyboard)
+		p.ConnectPeripheral(io.NewDisplaydAdapter())
+		p.ConnectPeripheral(io.NewTimer())
+		p.ConnectPeripheral(io.NewDiskController())
+		p.ConnectPeripheral(io.NewSerialAdapter())
+		p.ConnectPeripheral(io.NewInterruptController())
+		c.keyboards = append(c.keyboards, keyboard)
+
+		for i, word := range program {
+			p.WriteMemory(computer.CODE_REGION_START+uint16(i), word)
+		}
+		// JMP back to the code region start if IAR reaches the end, like the computer
+		p.WriteMemory(0xFEFE, 0x0040)
+		p.WriteMemory(0xFEFF, computer.CODE_REGION_START)
+
+		p.SetIAR(computer.CODE_REGION_START)
+		p.SetSP(computer.STACK_START)
+	}
+
+	watch := func(address uint16) {
+		c.written[address] = true
+	}
+	gatesMemory.WatchWrites(watch)
+	c.reference.WatchWrites(watch)
+
+	return c
+}
+
+// PressKey puts a key code on the keyboard bus of both CPUs
+func (c *Checker) PressKey(keycode uint16) {
+	for _, keyboard := range c.keyboards {
+		keyboard.KeyboardInBus.SetValue(keycode)
+	}
+}
+
+// Instructions returns the number of instructions both CPUs have run
+func (c *Checker) Instructions() int {
+	return c.instructions
+}
+
+// Run runs n instructions, returns a *Divergence for the first instruction the CPUs disagree on
+func (c *Checker) Run(n int) error {
+	for i := 0; i < n; i++ {
+		if err := c.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Step runs one instruction on both CPUs, including any extended steps and interrupt entry,
+// and compares their state. Returns a *Divergence if they disagree
+func (c *Checker) Step() error {
+	address := c.gates.IAR()
+	word := c.gates.ReadMemory(address)
+
+	for written := range c.written {
+		delete(c.written, written)
+	}
+	runInstruction(c.gates)
+	runInstruction(c.reference)
+
+	gates := state(c.gates, c.written)
+	reference := state(c.reference, c.written)
+	if !reflect.DeepEqual(gates, reference) {
+		return &Divergence{c.instructions, address, word, gates, reference}
+	}
+
+	c.instructions++
+	return nil
+}
+
+func runInstruction(p cpu.Processor) {
+	for {
+		for i := 0; i < cpu.STEPS_PER_ROUND; i++ {
+			p.Step()
+		}
+		if !p.HasExtendedSteps() {
+			return
+		}
+	}
+}
+
+func state(p cpu.Processor, written map[uint16]bool) State {
+	s := State{IAR: p.IAR(), Memory: make(map[uint16]uint16)}
+	for i := range s.Registers {
+		s.Registers[i] = p.Register(i)
+	}
+	s.Carry, s.ALarger, s.Equal, s.Zero = p.Flags()
+
+	for address := range written {
+		s.Memory[address] = p.ReadMemory(address)
+	}
+	return s
+}
@@ -0,0 +1,89 @@
+package lockstep
+
+import (
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/djhworld/simple-computer/computer"
+)
+
+// the keys pressed while the programs run, so the programs that wait for input get past it
+var KEY_PRESSES = map[int]uint16{
+	400:  65,  // A
+	900:  66,  // B
+	1400: 257, // enter
+}
+
+func TestProgramsMatchGateLevelCPU(t *testing.T) {
+	files, err := filepath.Glob("../_programs/*.bin")
+	if err != nil || len(files) == 0 {
+		t.Logf("Could not find any programs: %v", err)
+		t.FailNow()
+	}
+
+	for _, file := range files {
+		bin, err := computer.ReadBinFile(file)
+		if err != nil {
+			t.Logf("Error reading %s: %v", file, err)
+			t.FailNow()
+		}
+
+		c := NewChecker(bin)
+		for c.Instructions() < 2000 {
+			if key, ok := KEY_PRESSES[c.Instructions()]; ok {
+				c.PressKey(key)
+			}
+			if err := c.Step(); err != nil {
+				t.Logf("%s: %v", file, err)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+func TestRandomProgramsMatchGateLevelCPU(t *testing.T) {
+	for seed := int64(1); seed <= 4; seed++ {
+		program := RandomProgram(rand.New(rand.NewSource(seed)), 200)
+
+		c := NewChecker(program)
+		if err := c.Run(500); err != nil {
+			t.Logf("seed %d: %v", seed, err)
+			t.FailNow()
+		}
+	}
+}
+
+func TestReportsFirstDivergence(t *testing.T) {
+	// DATA R0, 0x1000; DATA R1, 0x0001; ST R0, R1; ADD R1, R1
+	c := NewChecker([]uint16{0x0020, 0x1000, 0x0021, 0x0001, 0x0011, 0x0085})
+	if err := c.Run(2); err != nil {
+		t.Logf("Expected no divergence but got %v", err)
+		t.FailNow()
+	}
+
+	c.reference.SetRegister(1, 0x0002)
+	err := c.Run(2)
+	d, ok := err.(*Divergence)
+	if !ok {
+		t.Logf("Expected a divergence but got %v", err)
+		t.FailNow()
+	}
+
+	if d.Instruction != 2 || d.Address != 0x0504 || d.Word != 0x0011 {
+		t.Logf("Expected the ST at 0x0504 to diverge but got %v", d)
+		t.FailNow()
+	}
+
+	expected := []string{"R1", "[0x1000]"}
+	if differences := d.Differences(); strings.Join(differences, ",") != strings.Join(expected, ",") {
+		t.Logf("Expected the differences to be %v but got %v", expected, differences)
+		t.FailNow()
+	}
+
+	if !strings.Contains(d.Error(), "[0x1000]: 0x0001") || !strings.Contains(d.Error(), "[0x1000]: 0x0002") {
+		t.Logf("Expected both states in the report but got\n%v", d)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,105 @@
+package lockstep
+
+import (
+	"math/rand"
+
+	"github.com/djhworld/simple-computer/computer"
+)
+
+// the first word of every instruction with its register bits cleared, and the registers it uses:
+// 0 for none, 1 for register B in bits 14 - 15, 2 for register A and B in bits 12 - 15
+var randomInstructions = []struct {
+	word      uint16
+	registers int
+	// the second word is an address in the program or a random value
+	jump bool
+	data bool
+}{
+	{0x0000, 2, false, false}, // LD
+	{0x0010, 2, false, false}, // ST
+	{0x0020, 1, false, true},  // DATA
+	{0x0030, 1, false, false}, // JR
+	{0x0040, 0, true, false},  // JMP
+	{0x0050, 0, true, false},  // JMPF, the flags are chosen below
+	{0x0060, 0, false, false}, // CLF
+	{0x0070, 1, false, false}, // IN Data
+	{0x0074, 1, false, false}, // IN Addr
+	{0x0078, 1, false, false}, // OUT Data
+	{0x007C, 1, false, false}, // OUT Addr
+	{0x0080, 2, false, false}, // ADD
+	{0x0090, 2, false, false}, // SHR
+	{0x00A0, 2, false, false}, // SHL
+	{0x00B0, 2, false, false}, // NOT
+	{0x00C0, 2, false, false}, // AND
+	{0x00D0, 2, false, false}, // OR
+	{0x00E0, 2, false, false}, // XOR
+	{0x00F0, 2, false, false}, // CMP
+	{0x0100, 1, false, false}, // PUSH
+	{0x0110, 1, false, false}, // POP
+	{0x0120, 0, true, false},  // CALL
+	{0x0130, 0, false, false}, // RET
+	{0x0140, 0, false, false}, // EI
+	{0x0150, 0, false, false}, // DI
+	// IRET is left out, the saved IAR is only set when an interrupt is taken
+	{0x0170, 2, false, false}, // SUB
+	{0x0180, 2, false, false}, // MOV
+	{0x0190, 1, false, false}, // INC
+	{0x01A0, 1, false, false}, // DEC
+	// HALT is left out, it would stop the program
+}
+
+// RandomProgram generates a program of about length words made of random instructions for the
+// code region. Jumps and calls go to the start of a random instruction in the program and the
+// program jumps back to its start at the end, so it keeps running the random instructions
+func RandomProgram(r *rand.Rand, length int) []uint16 {
+	program := []uint16{}
+	starts := []uint16{}
+	jumps := []int{}
+
+	add := func(words ...uint16) {
+		starts = append(starts, computer.CODE_REGION_START+uint16(len(program)))
+		program = append(program, words...)
+	}
+
+	for len(program) < length {
+		instruction := randomInstructions[r.Intn(len(randomInstructions))]
+
+		word := instruction.word
+		switch instruction.registers {
+		case 1:
+			word |= uint16(r.Intn(4))
+		case 2:
+			word |= uint16(r.Intn(16))
+		}
+
+		switch {
+		case word == 0x0050:
+			add(word|uint16(1+r.Intn(15)), 0x0000)
+			jumps = append(jumps, len(program)-1)
+		case instruction.jump:
+			add(word, 0x0000)
+			jumps = append(jumps, len(program)-1)
+		case instruction.data:
+			add(word, uint16(r.Intn(0x10000)))
+		case word&0xFFFC == 0x0030:
+			// JR and RET go to an address in a register or on the stack, put an instruction start
+			// there first. The group is a single instruction start so nothing jumps into the middle
+			add(0x0020|word&0x3, 0x0000, word)
+			jumps = append(jumps, len(program)-2)
+		case word == 0x0130:
+			register := uint16(r.Intn(4))
+			add(0x0020|register, 0x0000, 0x0100|register, word)
+			jumps = append(jumps, len(program)-3)
+		default:
+			add(word)
+		}
+	}
+
+	// JMP back to the start
+	add(0x0040, computer.CODE_REGION_START)
+
+	for _, jump := range jumps {
+		program[jump] = starts[r.Intn(len(starts))]
+	}
+	return program
+}
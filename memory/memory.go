@@ -43,6 +43,11 @@ func (c *Cell) Update(set bool, enable bool) {
 	c.value.Update()
 }
 
+// Value returns the value stored in the cell
+func (c *Cell) Value() uint16 {
+	return c.value.Value()
+}
+
 type Memory64K struct {
 	AddressRegister components.Register
 	rowDecoder      components.Decoder8x256
@@ -51,6 +56,9 @@ type Memory64K struct {
 	set             circuit.Wire
 	enable          circuit.Wire
 	bus             *components.Bus
+
+	// called with the address of every cell that is set, see WatchWrites
+	watchWrites func(address uint16)
 }
 
 func NewMemory64K(bus *components.Bus) *Memory64K {
@@ -112,6 +120,37 @@ func (m *Memory64K) Update() {
 	var col int = m.colDecoder.Index()
 
 	m.data[row][col].Update(m.set.Get(), m.enable.Get())
+
+	if m.set.Get() && m.watchWrites != nil {
+		m.watchWrites(m.AddressRegister.Value())
+	}
+}
+
+// WatchWrites calls watch with the address of a cell whenever the cell is set, watch can be nil to stop watching
+func (m *Memory64K) WatchWrites(watch func(address uint16)) {
+	m.watchWrites = watch
+}
+
+// Peek returns the value stored at address without going through the address register
+// or the bus, so it does not disturb the state of the computer
+func (m *Memory64K) Peek(address uint16) uint16 {
+	row, col := CellIndex(address)
+	cell := &m.data[row][col]
+	// a cell that has never been selected has not settled yet, it powers up with all bits on
+	cell.Update(false, false)
+	return cell.Value()
+}
+
+// CellIndex returns the row and column of the cell that holds address in a 256x256 grid of
+// cells addressed through 8x256 decoders, like the RAM and the display RAM
+func CellIndex(address uint16) (row, col int) {
+	return decoderIndex(address >> 8), decoderIndex(address & 0xFF)
+}
+
+// the 8x256 decoders select with the high nibble of their input as the low nibble of the
+// index and the other way round, so row/col n is not simply the byte n
+func decoderIndex(b uint16) int {
+	return int((b&0x0F)<<4 | (b&0xF0)>>4)
 }
 
 func (m *Memory64K) String() string {
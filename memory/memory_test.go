@@ -91,6 +91,40 @@ func TestMemory64KDoesNotUpdateWhenSetFlagIsOff(t *testing.T) {
 	}
 }
 
+func TestMemory64KPeek(t *testing.T) {
+	bus := components.NewBus(BUS_WIDTH)
+	m := NewMemory64K(bus)
+
+	for _, address := range []uint16{0x0000, 0x00FF, 0x0100, 0x0500, 0xABCD, 0xFFFF} {
+		m.AddressRegister.Set()
+		bus.SetValue(address)
+		m.Update()
+
+		m.AddressRegister.Unset()
+		m.Update()
+
+		bus.SetValue(^address)
+		m.Set()
+		m.Update()
+
+		m.Unset()
+		m.Update()
+	}
+
+	bus.SetValue(0x0000)
+	for _, address := range []uint16{0x0000, 0x00FF, 0x0100, 0x0500, 0xABCD, 0xFFFF} {
+		if value := m.Peek(address); value != ^address {
+			t.Logf("Expected 0x%04X at address 0x%04X but got 0x%04X", ^address, address, value)
+			t.FailNow()
+		}
+	}
+
+	if mar := m.AddressRegister.Value(); mar != 0xFFFF {
+		t.Logf("Expected peek to leave the address register alone but got 0x%04X", mar)
+		t.FailNow()
+	}
+}
+
 func checkBus(b *components.Bus, expected uint16) bool {
 	var result uint16
 	for i := BUS_WIDTH - 1; i >= 0; i-- {